@@ -0,0 +1,159 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var errShortPacket = errors.New("binlog: packet too short")
+
+// reader 按 MySQL 协议的编码读取数据，越界后记录 errShortPacket 并返回零值
+type reader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (me *reader) remaining() int {
+	return len(me.data) - me.pos
+}
+
+func (me *reader) bytes(n int) []byte {
+	if me.err != nil || n < 0 || me.pos+n > len(me.data) {
+		me.err = errShortPacket
+		return make([]byte, max(n, 0))
+	}
+	b := me.data[me.pos : me.pos+n]
+	me.pos += n
+	return b
+}
+
+func (me *reader) skip(n int) {
+	me.bytes(n)
+}
+
+func (me *reader) rest() []byte {
+	return me.bytes(me.remaining())
+}
+
+func (me *reader) peek() byte {
+	if me.pos >= len(me.data) {
+		me.err = errShortPacket
+		return 0
+	}
+	return me.data[me.pos]
+}
+
+func (me *reader) uint8() uint8 {
+	return me.bytes(1)[0]
+}
+
+func (me *reader) uint16() uint16 {
+	return binary.LittleEndian.Uint16(me.bytes(2))
+}
+
+func (me *reader) uint24() uint32 {
+	b := me.bytes(3)
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+func (me *reader) uint32() uint32 {
+	return binary.LittleEndian.Uint32(me.bytes(4))
+}
+
+func (me *reader) uint48() uint64 {
+	b := me.bytes(6)
+	return uint64(binary.LittleEndian.Uint32(b)) | uint64(binary.LittleEndian.Uint16(b[4:]))<<32
+}
+
+func (me *reader) uint64() uint64 {
+	return binary.LittleEndian.Uint64(me.bytes(8))
+}
+
+// uintN 读取 n 字节的小端无符号整数
+func (me *reader) uintN(n int) uint64 {
+	var v uint64
+	for i, b := range me.bytes(n) {
+		v |= uint64(b) << (8 * i)
+	}
+	return v
+}
+
+func (me *reader) lenencInt() uint64 {
+	switch b := me.uint8(); b {
+	case 0xfc:
+		return uint64(me.uint16())
+	case 0xfd:
+		return uint64(me.uint24())
+	case 0xfe:
+		return me.uint64()
+	default:
+		return uint64(b)
+	}
+}
+
+func (me *reader) lenencBytes() []byte {
+	return me.bytes(int(me.lenencInt()))
+}
+
+func (me *reader) nulString() string {
+	if me.err != nil {
+		return ""
+	}
+	i := bytes.IndexByte(me.data[me.pos:], 0)
+	if i < 0 {
+		me.err = errShortPacket
+		return ""
+	}
+	s := string(me.data[me.pos : me.pos+i])
+	me.pos += i + 1
+	return s
+}
+
+type writer struct {
+	buf []byte
+}
+
+func (me *writer) raw(b []byte) {
+	me.buf = append(me.buf, b...)
+}
+
+func (me *writer) zero(n int) {
+	me.buf = append(me.buf, make([]byte, n)...)
+}
+
+func (me *writer) uint8(v uint8) {
+	me.buf = append(me.buf, v)
+}
+
+func (me *writer) uint16(v uint16) {
+	me.buf = binary.LittleEndian.AppendUint16(me.buf, v)
+}
+
+func (me *writer) uint32(v uint32) {
+	me.buf = binary.LittleEndian.AppendUint32(me.buf, v)
+}
+
+func (me *writer) nulString(s string) {
+	me.buf = append(append(me.buf, s...), 0)
+}
+
+func (me *writer) lenencInt(v uint64) {
+	switch {
+	case v < 0xfb:
+		me.buf = append(me.buf, byte(v))
+	case v < 1<<16:
+		me.buf = append(me.buf, 0xfc, byte(v), byte(v>>8))
+	case v < 1<<24:
+		me.buf = append(me.buf, 0xfd, byte(v), byte(v>>8), byte(v>>16))
+	default:
+		me.buf = append(me.buf, 0xfe)
+		me.buf = binary.LittleEndian.AppendUint64(me.buf, v)
+	}
+}
+
+func (me *writer) lenencBytes(b []byte) {
+	me.lenencInt(uint64(len(b)))
+	me.raw(b)
+}
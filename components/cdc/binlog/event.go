@@ -0,0 +1,489 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"strings"
+)
+
+type EventType uint8
+
+const (
+	QueryEvent             EventType = 2
+	RotateEvent            EventType = 4
+	FormatDescriptionEvent EventType = 15
+	XIDEvent               EventType = 16
+	TableMapEvent          EventType = 19
+	WriteRowsEventV1       EventType = 23
+	UpdateRowsEventV1      EventType = 24
+	DeleteRowsEventV1      EventType = 25
+	HeartbeatEvent         EventType = 27
+	WriteRowsEventV2       EventType = 30
+	UpdateRowsEventV2      EventType = 31
+	DeleteRowsEventV2      EventType = 32
+)
+
+const (
+	eventHeaderSize = 19
+
+	checksumAlgOff   = 0
+	checksumAlgCRC32 = 1
+	checksumSize     = 4
+)
+
+// Position 为 binlog 文件名与文件内偏移
+type Position struct {
+	File string `json:"file"`
+	Pos  uint32 `json:"pos"`
+}
+
+func (me Position) String() string {
+	return me.File + ":" + strconv.FormatUint(uint64(me.Pos), 10)
+}
+
+type EventHeader struct {
+	Timestamp uint32
+	Type      EventType
+	ServerID  uint32
+	EventSize uint32
+	// LogPos 为下一个事件的起始偏移，服务端在流开头发送的伪 Rotate 事件中为 0
+	LogPos uint32
+	Flags  uint16
+}
+
+// Event 的 Data 为 *Rotate、*FormatDescription、*TableMap、*Rows、*XID、*Query 之一，其他类型为 nil
+type Event struct {
+	Header EventHeader
+	Data   any
+}
+
+type (
+	Rotate struct {
+		Next Position
+	}
+	FormatDescription struct {
+		BinlogVersion uint16
+		ServerVersion string
+		ChecksumAlg   uint8
+		// PostHeaderLen[i] 为类型 i+1 事件的固定头长度
+		PostHeaderLen []byte
+	}
+	XID struct {
+		XID uint64
+	}
+	Query struct {
+		Schema string
+		Query  string
+	}
+	// TableMap 描述随后行事件中的表结构。ColumnNames、PrimaryKey 仅在 binlog_row_metadata=FULL 时由服务端提供
+	TableMap struct {
+		TableID     uint64
+		Schema      string
+		Table       string
+		ColumnTypes []byte
+		ColumnMeta  []uint16
+		Nullable    []bool
+		Unsigned    []bool
+		ColumnNames []string
+		PrimaryKey  []int
+		EnumValues  [][]string
+		SetValues   [][]string
+	}
+	// Rows 为行事件，更新事件的 Rows 依次为 更新前、更新后 成对出现，值的下标与表的列序号一致
+	Rows struct {
+		Type  EventType
+		Table *TableMap
+		Rows  [][]any
+	}
+)
+
+func (me *Rows) IsInsert() bool {
+	return me.Type == WriteRowsEventV1 || me.Type == WriteRowsEventV2
+}
+
+func (me *Rows) IsUpdate() bool {
+	return me.Type == UpdateRowsEventV1 || me.Type == UpdateRowsEventV2
+}
+
+func (me *Rows) IsDelete() bool {
+	return me.Type == DeleteRowsEventV1 || me.Type == DeleteRowsEventV2
+}
+
+// Streamer 解析 COM_BINLOG_DUMP 返回的事件流，不是并发安全的
+type Streamer struct {
+	conn        *Conn
+	pos         Position
+	checksumAlg uint8
+	fdeSeen     bool
+	tableIDSize int
+	tables      map[uint64]*TableMap
+}
+
+func newStreamer(conn *Conn, pos Position) *Streamer {
+	return &Streamer{
+		conn:        conn,
+		pos:         pos,
+		tableIDSize: 6,
+		tables:      map[uint64]*TableMap{},
+	}
+}
+
+// Position 返回最近一个已读事件结束处的位置
+func (me *Streamer) Position() Position {
+	return me.pos
+}
+
+// Next 阻塞读取下一个事件，连接关闭或出错时返回错误
+func (me *Streamer) Next() (*Event, error) {
+	data, err := me.conn.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errShortPacket
+	}
+	switch data[0] {
+	case 0x00:
+	case 0xff:
+		return nil, parseError(data)
+	case 0xfe:
+		return nil, errors.New("binlog: end of stream")
+	default:
+		return nil, fmt.Errorf("binlog: unexpected packet 0x%02x", data[0])
+	}
+	return me.parse(data[1:])
+}
+
+func (me *Streamer) parse(data []byte) (*Event, error) {
+	if len(data) < eventHeaderSize {
+		return nil, errShortPacket
+	}
+	r := &reader{data: data}
+	evt := &Event{Header: EventHeader{
+		Timestamp: r.uint32(),
+		Type:      EventType(r.uint8()),
+		ServerID:  r.uint32(),
+		EventSize: r.uint32(),
+		LogPos:    r.uint32(),
+		Flags:     r.uint16(),
+	}}
+	body := data[eventHeaderSize:]
+	if evt.Header.Type == FormatDescriptionEvent {
+		fde, err := parseFormatDescription(body)
+		if err != nil {
+			return nil, err
+		}
+		me.checksumAlg = fde.ChecksumAlg
+		me.fdeSeen = true
+		// 早期版本的 TABLE_MAP 固定头为 6 字节，表 ID 只占 4 字节
+		if int(TableMapEvent) <= len(fde.PostHeaderLen) && fde.PostHeaderLen[TableMapEvent-1] == 6 {
+			me.tableIDSize = 4
+		}
+		evt.Data = fde
+		return me.advance(evt), nil
+	}
+	if me.checksumAlg == checksumAlgCRC32 {
+		if !validChecksum(data) {
+			return nil, fmt.Errorf("binlog: checksum mismatch at %s", me.pos)
+		}
+		body = body[:len(body)-checksumSize]
+	} else if !me.fdeSeen && evt.Header.Type == RotateEvent && validChecksum(data) {
+		// 流开头的伪 Rotate 事件先于 FDE 到达，是否带校验和只能按内容判断
+		body = body[:len(body)-checksumSize]
+	}
+	var err error
+	switch evt.Header.Type {
+	case RotateEvent:
+		r := &reader{data: body}
+		pos := r.uint64()
+		evt.Data = &Rotate{Next: Position{File: string(r.rest()), Pos: uint32(pos)}}
+		err = r.err
+	case XIDEvent:
+		r := &reader{data: body}
+		evt.Data = &XID{XID: r.uint64()}
+		err = r.err
+	case QueryEvent:
+		evt.Data, err = parseQuery(body)
+	case TableMapEvent:
+		var tm *TableMap
+		if tm, err = me.parseTableMap(body); err == nil {
+			me.tables[tm.TableID] = tm
+			evt.Data = tm
+		}
+	case WriteRowsEventV1, UpdateRowsEventV1, DeleteRowsEventV1,
+		WriteRowsEventV2, UpdateRowsEventV2, DeleteRowsEventV2:
+		evt.Data, err = me.parseRows(evt.Header.Type, body)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("binlog: parse event %d at %s: %w", evt.Header.Type, me.pos, err)
+	}
+	if rotate, ok := evt.Data.(*Rotate); ok {
+		me.pos = rotate.Next
+		return evt, nil
+	}
+	return me.advance(evt), nil
+}
+
+func validChecksum(data []byte) bool {
+	if len(data) < eventHeaderSize+checksumSize {
+		return false
+	}
+	sum := binary.LittleEndian.Uint32(data[len(data)-checksumSize:])
+	return crc32.ChecksumIEEE(data[:len(data)-checksumSize]) == sum
+}
+
+func (me *Streamer) advance(evt *Event) *Event {
+	// 心跳等事件不计入位置，伪事件的 LogPos 为 0
+	if evt.Header.LogPos > 0 && evt.Header.Type != HeartbeatEvent {
+		me.pos.Pos = evt.Header.LogPos
+	}
+	return evt
+}
+
+func parseFormatDescription(body []byte) (*FormatDescription, error) {
+	r := &reader{data: body}
+	fde := &FormatDescription{
+		BinlogVersion: r.uint16(),
+		ServerVersion: strings.TrimRight(string(r.bytes(50)), "\x00"),
+	}
+	r.skip(4) // create timestamp
+	r.skip(1) // header length
+	if r.err != nil {
+		return nil, r.err
+	}
+	end := len(body)
+	// 5.6.1 起 FDE 末尾为 1 字节校验算法与 4 字节校验和
+	if versionAtLeast(fde.ServerVersion, 5, 6, 1) && r.remaining() >= checksumSize+1 {
+		fde.ChecksumAlg = body[len(body)-checksumSize-1]
+		end -= checksumSize + 1
+	}
+	fde.PostHeaderLen = append([]byte(nil), body[r.pos:end]...)
+	return fde, nil
+}
+
+func versionAtLeast(version string, want ...int) bool {
+	version, _, _ = strings.Cut(version, "-")
+	parts := strings.Split(version, ".")
+	for i, w := range want {
+		if i >= len(parts) {
+			return false
+		}
+		v, _ := strconv.Atoi(parts[i])
+		if v != w {
+			return v > w
+		}
+	}
+	return true
+}
+
+func parseQuery(body []byte) (*Query, error) {
+	r := &reader{data: body}
+	r.skip(4) // thread id
+	r.skip(4) // execution time
+	schemaLen := int(r.uint8())
+	r.skip(2) // error code
+	statusLen := int(r.uint16())
+	r.skip(statusLen)
+	q := &Query{Schema: string(r.bytes(schemaLen))}
+	r.skip(1)
+	q.Query = string(r.rest())
+	return q, r.err
+}
+
+func (me *Streamer) tableID(r *reader) uint64 {
+	if me.tableIDSize == 4 {
+		return uint64(r.uint32())
+	}
+	return r.uint48()
+}
+
+func (me *Streamer) parseTableMap(body []byte) (*TableMap, error) {
+	r := &reader{data: body}
+	tm := &TableMap{TableID: me.tableID(r)}
+	r.skip(2) // flags
+	tm.Schema = string(r.bytes(int(r.uint8())))
+	r.skip(1)
+	tm.Table = string(r.bytes(int(r.uint8())))
+	r.skip(1)
+	count := int(r.lenencInt())
+	tm.ColumnTypes = append([]byte(nil), r.bytes(count)...)
+	meta := &reader{data: r.lenencBytes()}
+	tm.ColumnMeta = make([]uint16, count)
+	for i, t := range tm.ColumnTypes {
+		switch t {
+		case TypeString, TypeNewDecimal:
+			tm.ColumnMeta[i] = uint16(meta.uint8())<<8 | uint16(meta.uint8())
+		case TypeVarString, TypeVarchar, TypeBit:
+			tm.ColumnMeta[i] = meta.uint16()
+		case TypeBlob, TypeDouble, TypeFloat, TypeGeometry, TypeJSON,
+			TypeTime2, TypeDatetime2, TypeTimestamp2:
+			tm.ColumnMeta[i] = uint16(meta.uint8())
+		}
+	}
+	if meta.err != nil {
+		return nil, meta.err
+	}
+	nullBitmap := r.bytes((count + 7) / 8)
+	tm.Nullable = make([]bool, count)
+	for i := range tm.Nullable {
+		tm.Nullable[i] = bitSet(nullBitmap, i)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	tm.Unsigned = make([]bool, count)
+	for r.remaining() > 0 {
+		typ := r.uint8()
+		value := &reader{data: r.lenencBytes()}
+		if r.err != nil {
+			return nil, r.err
+		}
+		tm.parseOptionalMetadata(typ, value)
+	}
+	return tm, nil
+}
+
+// parseOptionalMetadata 解析 binlog_row_metadata 提供的可选元数据
+func (me *TableMap) parseOptionalMetadata(typ uint8, r *reader) {
+	switch typ {
+	case 1: // SIGNEDNESS，按数值列的顺序排列
+		bitmap := r.rest()
+		n := 0
+		for i, t := range me.ColumnTypes {
+			if isNumericType(t) {
+				me.Unsigned[i] = bitSet(bitmap, n)
+				n++
+			}
+		}
+	case 4: // COLUMN_NAME
+		for r.remaining() > 0 {
+			me.ColumnNames = append(me.ColumnNames, string(r.lenencBytes()))
+		}
+	case 5, 6: // SET_STR_VALUE、ENUM_STR_VALUE，按 SET/ENUM 列的顺序排列
+		var values [][]string
+		for r.remaining() > 0 {
+			n := int(r.lenencInt())
+			items := make([]string, n)
+			for i := range items {
+				items[i] = string(r.lenencBytes())
+			}
+			values = append(values, items)
+		}
+		if typ == 5 {
+			me.SetValues = values
+		} else {
+			me.EnumValues = values
+		}
+	case 8: // SIMPLE_PRIMARY_KEY
+		for r.remaining() > 0 {
+			me.PrimaryKey = append(me.PrimaryKey, int(r.lenencInt()))
+		}
+	case 9: // PRIMARY_KEY_WITH_PREFIX
+		for r.remaining() > 0 {
+			me.PrimaryKey = append(me.PrimaryKey, int(r.lenencInt()))
+			r.lenencInt()
+		}
+	}
+}
+
+func bitSet(bitmap []byte, i int) bool {
+	return bitmap[i/8]&(1<<(uint(i)%8)) != 0
+}
+
+func (me *Streamer) parseRows(typ EventType, body []byte) (*Rows, error) {
+	r := &reader{data: body}
+	tableID := me.tableID(r)
+	r.skip(2) // flags
+	if typ >= WriteRowsEventV2 {
+		extraLen := int(r.uint16())
+		r.skip(extraLen - 2)
+	}
+	tm, ok := me.tables[tableID]
+	if !ok {
+		return nil, fmt.Errorf("table map %d not found", tableID)
+	}
+	rows := &Rows{Type: typ, Table: tm}
+	count := int(r.lenencInt())
+	present := r.bytes((count + 7) / 8)
+	presentAfter := present
+	if typ == UpdateRowsEventV1 || typ == UpdateRowsEventV2 {
+		presentAfter = r.bytes((count + 7) / 8)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	for r.remaining() > 0 {
+		row, err := tm.decodeRow(r, count, present)
+		if err != nil {
+			return nil, err
+		}
+		rows.Rows = append(rows.Rows, row)
+		if rows.IsUpdate() {
+			row, err := tm.decodeRow(r, count, presentAfter)
+			if err != nil {
+				return nil, err
+			}
+			rows.Rows = append(rows.Rows, row)
+		}
+	}
+	return rows, nil
+}
+
+func (me *TableMap) decodeRow(r *reader, count int, present []byte) ([]any, error) {
+	n := 0
+	for i := range count {
+		if bitSet(present, i) {
+			n++
+		}
+	}
+	nulls := r.bytes((n + 7) / 8)
+	row := make([]any, count)
+	k := 0
+	for i := range count {
+		if !bitSet(present, i) {
+			continue
+		}
+		isNull := bitSet(nulls, k)
+		k++
+		if isNull {
+			continue
+		}
+		v, err := me.decodeValue(r, i)
+		if err != nil {
+			return nil, fmt.Errorf("column %d: %w", i, err)
+		}
+		row[i] = v
+	}
+	return row, r.err
+}
+
+// enumValues 返回第 i 列在 ENUM/SET 可选元数据中的取值列表
+func (me *TableMap) enumValues(i int, set bool) []string {
+	n := 0
+	values := me.EnumValues
+	if set {
+		values = me.SetValues
+	}
+	for j := range i {
+		if t, _ := me.realType(j); (set && t == TypeSet) || (!set && t == TypeEnum) {
+			n++
+		}
+	}
+	if n < len(values) {
+		return values[n]
+	}
+	return nil
+}
+
+func (me *TableMap) realType(i int) (byte, uint16) {
+	t, meta := me.ColumnTypes[i], me.ColumnMeta[i]
+	if t == TypeString && meta >= 256 {
+		if b0 := byte(meta >> 8); b0 == TypeEnum || b0 == TypeSet {
+			return b0, meta & 0xff
+		}
+	}
+	return t, meta
+}
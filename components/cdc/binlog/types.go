@@ -0,0 +1,488 @@
+package binlog
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 列类型，取值与 MySQL 的 enum_field_types 一致
+const (
+	TypeDecimal    byte = 0
+	TypeTiny       byte = 1
+	TypeShort      byte = 2
+	TypeLong       byte = 3
+	TypeFloat      byte = 4
+	TypeDouble     byte = 5
+	TypeNull       byte = 6
+	TypeTimestamp  byte = 7
+	TypeLongLong   byte = 8
+	TypeInt24      byte = 9
+	TypeDate       byte = 10
+	TypeTime       byte = 11
+	TypeDatetime   byte = 12
+	TypeYear       byte = 13
+	TypeVarchar    byte = 15
+	TypeBit        byte = 16
+	TypeTimestamp2 byte = 17
+	TypeDatetime2  byte = 18
+	TypeTime2      byte = 19
+	TypeJSON       byte = 245
+	TypeNewDecimal byte = 246
+	TypeEnum       byte = 247
+	TypeSet        byte = 248
+	TypeTinyBlob   byte = 249
+	TypeMediumBlob byte = 250
+	TypeLongBlob   byte = 251
+	TypeBlob       byte = 252
+	TypeVarString  byte = 253
+	TypeString     byte = 254
+	TypeGeometry   byte = 255
+)
+
+func isNumericType(t byte) bool {
+	switch t {
+	case TypeTiny, TypeShort, TypeInt24, TypeLong, TypeLongLong,
+		TypeFloat, TypeDouble, TypeDecimal, TypeNewDecimal:
+		return true
+	}
+	return false
+}
+
+// decodeValue 解码第 i 列的值。整数为 int64（无符号列为 uint64），DECIMAL 为 string，
+// 字符串为 string，BLOB/TEXT 为 []byte，JSON 为 JSON 文本，日期时间为 UTC 的 time.Time，TIME 为 time.Duration，
+// ENUM、SET 在有可选元数据时为取值字符串，否则为序号与位图。
+func (me *TableMap) decodeValue(r *reader, i int) (any, error) {
+	t, meta := me.ColumnTypes[i], me.ColumnMeta[i]
+	unsigned := me.Unsigned[i]
+	switch t {
+	case TypeTiny:
+		return integer(r.uintN(1), 1, unsigned), r.err
+	case TypeShort:
+		return integer(r.uintN(2), 2, unsigned), r.err
+	case TypeInt24:
+		return integer(r.uintN(3), 3, unsigned), r.err
+	case TypeLong:
+		return integer(r.uintN(4), 4, unsigned), r.err
+	case TypeLongLong:
+		return integer(r.uintN(8), 8, unsigned), r.err
+	case TypeFloat:
+		return math.Float32frombits(r.uint32()), r.err
+	case TypeDouble:
+		return math.Float64frombits(r.uint64()), r.err
+	case TypeYear:
+		if v := r.uint8(); v != 0 {
+			return int64(v) + 1900, r.err
+		}
+		return int64(0), r.err
+	case TypeNewDecimal:
+		return decodeDecimal(r, int(meta>>8), int(meta&0xff)), r.err
+	case TypeVarchar, TypeVarString:
+		return decodeString(r, int(meta)), r.err
+	case TypeString:
+		length := int(meta)
+		if meta >= 256 {
+			b0, b1 := byte(meta>>8), byte(meta)
+			if b0&0x30 != 0x30 {
+				length = int(b1) | int((b0&0x30)^0x30)<<4
+				t = b0 | 0x30
+			} else {
+				length = int(b1)
+				t = b0
+			}
+		}
+		switch t {
+		case TypeEnum:
+			index := r.uintN(length)
+			if values := me.enumValues(i, false); index > 0 && int(index) <= len(values) {
+				return values[index-1], r.err
+			}
+			return int64(index), r.err
+		case TypeSet:
+			bits := r.uintN(length)
+			if values := me.enumValues(i, true); values != nil {
+				var items []string
+				for j, v := range values {
+					if bits&(1<<uint(j)) != 0 {
+						items = append(items, v)
+					}
+				}
+				return strings.Join(items, ","), r.err
+			}
+			return bits, r.err
+		}
+		return decodeString(r, length), r.err
+	case TypeBlob, TypeGeometry:
+		return append([]byte(nil), r.bytes(int(r.uintN(int(meta))))...), r.err
+	case TypeJSON:
+		data := r.bytes(int(r.uintN(int(meta))))
+		if r.err != nil {
+			return nil, r.err
+		}
+		return decodeJSON(data)
+	case TypeBit:
+		nbits := int(meta>>8)*8 + int(meta&0xff)
+		var v uint64
+		for _, b := range r.bytes((nbits + 7) / 8) {
+			v = v<<8 | uint64(b)
+		}
+		return v, r.err
+	case TypeDate:
+		v := r.uint24()
+		return date(int(v>>9), int(v>>5&15), int(v&31), 0, 0, 0, 0), r.err
+	case TypeDatetime:
+		v := r.uint64()
+		d, t := v/1000000, v%1000000
+		return date(int(d/10000), int(d/100%100), int(d%100), int(t/10000), int(t/100%100), int(t%100), 0), r.err
+	case TypeTimestamp:
+		return time.Unix(int64(r.uint32()), 0).UTC(), r.err
+	case TypeTime:
+		v := int64(r.uintN(3))
+		if v&0x800000 != 0 {
+			v -= 1 << 24
+		}
+		sign := time.Duration(1)
+		if v < 0 {
+			sign, v = -1, -v
+		}
+		return sign * (time.Duration(v/10000)*time.Hour + time.Duration(v/100%100)*time.Minute + time.Duration(v%100)*time.Second), r.err
+	case TypeTimestamp2:
+		sec := int64(binary.BigEndian.Uint32(r.bytes(4)))
+		usec := readFrac(r, int(meta))
+		return time.Unix(sec, usec*1000).UTC(), r.err
+	case TypeDatetime2:
+		v := int64(bigEndian(r.bytes(5))) - 0x8000000000
+		usec := readFrac(r, int(meta))
+		ymd, hms := v>>17, v%(1<<17)
+		ym := ymd >> 5
+		return date(int(ym/13), int(ym%13), int(ymd%32), int(hms>>12), int(hms>>6%64), int(hms%64), usec), r.err
+	case TypeTime2:
+		return decodeTime2(r, int(meta)), r.err
+	case TypeNull:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported column type %d", t)
+}
+
+func integer(v uint64, size int, unsigned bool) any {
+	if unsigned {
+		return v
+	}
+	shift := 64 - 8*size
+	return int64(v<<shift) >> shift
+}
+
+func decodeString(r *reader, maxLength int) string {
+	var n int
+	if maxLength < 256 {
+		n = int(r.uint8())
+	} else {
+		n = int(r.uint16())
+	}
+	return string(r.bytes(n))
+}
+
+func bigEndian(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+// readFrac 读取 fsp 精度的小数秒，返回微秒
+func readFrac(r *reader, fsp int) int64 {
+	switch n := (fsp + 1) / 2; n {
+	case 1:
+		return int64(r.uint8()) * 10000
+	case 2:
+		return int64(bigEndian(r.bytes(2))) * 100
+	case 3:
+		return int64(bigEndian(r.bytes(3)))
+	}
+	return 0
+}
+
+// date 返回 UTC 时间，0000-00-00 等无效日期返回零值
+func date(year, month, day, hour, minute, second int, usec int64) time.Time {
+	if month == 0 || day == 0 {
+		return time.Time{}
+	}
+	return time.Date(year, time.Month(month), day, hour, minute, second, int(usec)*1000, time.UTC)
+}
+
+// decodeTime2 先还原带符号的打包值 (hms<<24 + 微秒)，与 MySQL 的 TIME_from_longlong_time_packed 一样
+// 取绝对值后再拆分时分秒与小数部分
+func decodeTime2(r *reader, fsp int) time.Duration {
+	var packed int64
+	switch (fsp + 1) / 2 {
+	case 1:
+		intPart := int64(bigEndian(r.bytes(3))) - 0x800000
+		frac := int64(r.uint8())
+		if intPart < 0 && frac > 0 {
+			intPart++
+			frac -= 0x100
+		}
+		packed = intPart<<24 + frac*10000
+	case 2:
+		intPart := int64(bigEndian(r.bytes(3))) - 0x800000
+		frac := int64(bigEndian(r.bytes(2)))
+		if intPart < 0 && frac > 0 {
+			intPart++
+			frac -= 0x10000
+		}
+		packed = intPart<<24 + frac*100
+	case 3:
+		packed = int64(bigEndian(r.bytes(6))) - 0x800000000000
+	default:
+		packed = (int64(bigEndian(r.bytes(3))) - 0x800000) << 24
+	}
+	sign := time.Duration(1)
+	if packed < 0 {
+		sign, packed = -1, -packed
+	}
+	hms, frac := packed>>24, packed%(1<<24)
+	d := time.Duration(hms>>12%(1<<10))*time.Hour +
+		time.Duration(hms>>6%(1<<6))*time.Minute +
+		time.Duration(hms%(1<<6))*time.Second +
+		time.Duration(frac)*time.Microsecond
+	return sign * d
+}
+
+var dig2bytes = [10]int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
+
+// decodeDecimal 解码 DECIMAL 的二进制格式，每 9 位十进制数占 4 字节，符号位取反存储在首字节最高位
+func decodeDecimal(r *reader, precision, scale int) string {
+	intg := precision - scale
+	intg0, intg0x := intg/9, intg%9
+	frac0, frac0x := scale/9, scale%9
+	size := intg0*4 + dig2bytes[intg0x] + frac0*4 + dig2bytes[frac0x]
+	data := append([]byte(nil), r.bytes(size)...)
+	if r.err != nil || size == 0 {
+		return ""
+	}
+	negative := data[0]&0x80 == 0
+	data[0] ^= 0x80
+	if negative {
+		for i := range data {
+			data[i] ^= 0xff
+		}
+	}
+	pos := 0
+	next := func(n int) uint64 {
+		v := bigEndian(data[pos : pos+n])
+		pos += n
+		return v
+	}
+	var digits strings.Builder
+	if intg0x > 0 {
+		digits.WriteString(strconv.FormatUint(next(dig2bytes[intg0x]), 10))
+	}
+	for range intg0 {
+		fmt.Fprintf(&digits, "%09d", next(4))
+	}
+	var sb strings.Builder
+	if negative {
+		sb.WriteByte('-')
+	}
+	sb.WriteString(cmp.Or(strings.TrimLeft(digits.String(), "0"), "0"))
+	if scale > 0 {
+		sb.WriteByte('.')
+		for range frac0 {
+			fmt.Fprintf(&sb, "%09d", next(4))
+		}
+		if frac0x > 0 {
+			fmt.Fprintf(&sb, "%0*d", frac0x, next(dig2bytes[frac0x]))
+		}
+	}
+	return sb.String()
+}
+
+const (
+	jsonSmallObject = 0x00
+	jsonLargeObject = 0x01
+	jsonSmallArray  = 0x02
+	jsonLargeArray  = 0x03
+	jsonLiteral     = 0x04
+	jsonInt16       = 0x05
+	jsonUint16      = 0x06
+	jsonInt32       = 0x07
+	jsonUint32      = 0x08
+	jsonInt64       = 0x09
+	jsonUint64      = 0x0a
+	jsonDouble      = 0x0b
+	jsonString      = 0x0c
+	jsonOpaque      = 0x0f
+)
+
+var errInvalidJSON = errors.New("invalid binary json")
+
+// decodeJSON 将 MySQL 的二进制 JSON 转为 JSON 文本
+func decodeJSON(data []byte) (string, error) {
+	if len(data) == 0 {
+		return "null", nil
+	}
+	v, err := decodeJSONValue(data[0], data[1:])
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func decodeJSONValue(t byte, data []byte) (any, error) {
+	switch t {
+	case jsonSmallObject, jsonLargeObject, jsonSmallArray, jsonLargeArray:
+		return decodeJSONComposite(t, data)
+	case jsonString:
+		n, size := jsonVarLen(data)
+		if size == 0 || size+n > len(data) {
+			return nil, errInvalidJSON
+		}
+		return string(data[size : size+n]), nil
+	case jsonOpaque:
+		if len(data) < 1 {
+			return nil, errInvalidJSON
+		}
+		n, size := jsonVarLen(data[1:])
+		if size == 0 || 1+size+n > len(data) {
+			return nil, errInvalidJSON
+		}
+		raw := data[1+size : 1+size+n]
+		if data[0] == TypeNewDecimal && len(raw) >= 2 {
+			return json.Number(decodeDecimal(&reader{data: raw[2:]}, int(raw[0]), int(raw[1]))), nil
+		}
+		return fmt.Sprintf("base64:type%d:%s", data[0], base64.StdEncoding.EncodeToString(raw)), nil
+	}
+	return decodeJSONScalar(t, data)
+}
+
+func decodeJSONScalar(t byte, data []byte) (any, error) {
+	sizes := map[byte]int{
+		jsonLiteral: 1, jsonInt16: 2, jsonUint16: 2, jsonInt32: 4, jsonUint32: 4,
+		jsonInt64: 8, jsonUint64: 8, jsonDouble: 8,
+	}
+	size, ok := sizes[t]
+	if !ok {
+		return nil, fmt.Errorf("unsupported json type %d", t)
+	}
+	if len(data) < size {
+		return nil, errInvalidJSON
+	}
+	switch t {
+	case jsonLiteral:
+		switch data[0] {
+		case 0x01:
+			return true, nil
+		case 0x02:
+			return false, nil
+		}
+		return nil, nil
+	case jsonInt16:
+		return int64(int16(binary.LittleEndian.Uint16(data))), nil
+	case jsonUint16:
+		return uint64(binary.LittleEndian.Uint16(data)), nil
+	case jsonInt32:
+		return int64(int32(binary.LittleEndian.Uint32(data))), nil
+	case jsonUint32:
+		return uint64(binary.LittleEndian.Uint32(data)), nil
+	case jsonInt64:
+		return int64(binary.LittleEndian.Uint64(data)), nil
+	case jsonUint64:
+		return binary.LittleEndian.Uint64(data), nil
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(data)), nil
+}
+
+func decodeJSONComposite(t byte, data []byte) (any, error) {
+	large := t == jsonLargeObject || t == jsonLargeArray
+	object := t == jsonSmallObject || t == jsonLargeObject
+	offsetSize := 2
+	if large {
+		offsetSize = 4
+	}
+	readN := func(pos int) (int, bool) {
+		if pos+offsetSize > len(data) {
+			return 0, false
+		}
+		if large {
+			return int(binary.LittleEndian.Uint32(data[pos:])), true
+		}
+		return int(binary.LittleEndian.Uint16(data[pos:])), true
+	}
+	count, ok := readN(0)
+	if !ok {
+		return nil, errInvalidJSON
+	}
+	keyEntrySize := offsetSize + 2
+	valueEntrySize := 1 + offsetSize
+	header := 2 * offsetSize
+	valueEntries := header
+	if object {
+		valueEntries += count * keyEntrySize
+	}
+	values := make([]any, count)
+	keys := make([]string, count)
+	for i := range count {
+		if object {
+			entry := header + i*keyEntrySize
+			keyOffset, ok := readN(entry)
+			if !ok || entry+offsetSize+2 > len(data) {
+				return nil, errInvalidJSON
+			}
+			keyLen := int(binary.LittleEndian.Uint16(data[entry+offsetSize:]))
+			if keyOffset+keyLen > len(data) {
+				return nil, errInvalidJSON
+			}
+			keys[i] = string(data[keyOffset : keyOffset+keyLen])
+		}
+		entry := valueEntries + i*valueEntrySize
+		if entry+valueEntrySize > len(data) {
+			return nil, errInvalidJSON
+		}
+		vt := data[entry]
+		var err error
+		switch {
+		case vt == jsonLiteral || vt == jsonInt16 || vt == jsonUint16,
+			large && (vt == jsonInt32 || vt == jsonUint32):
+			// 小值内联在 value entry 中
+			values[i], err = decodeJSONScalar(vt, data[entry+1:entry+valueEntrySize])
+		default:
+			offset, _ := readN(entry + 1)
+			if offset >= len(data) {
+				return nil, errInvalidJSON
+			}
+			values[i], err = decodeJSONValue(vt, data[offset:])
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !object {
+		return values, nil
+	}
+	reply := make(map[string]any, count)
+	for i, k := range keys {
+		reply[k] = values[i]
+	}
+	return reply, nil
+}
+
+// jsonVarLen 读取每字节 7 位的变长整数，返回值与占用的字节数，数据无效时字节数为 0
+func jsonVarLen(data []byte) (int, int) {
+	var v int
+	for i := 0; i < len(data) && i < 5; i++ {
+		v |= int(data[i]&0x7f) << (7 * i)
+		if data[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
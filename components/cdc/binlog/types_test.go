@@ -0,0 +1,133 @@
+package binlog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecodeDecimal(t *testing.T) {
+	cases := []struct {
+		data      []byte
+		precision int
+		scale     int
+		want      string
+	}{
+		// 取自 MySQL 文档 strings/decimal.cc 中 DECIMAL(14,4) 的示例
+		{[]byte{0x81, 0x0D, 0xFB, 0x38, 0xD2, 0x04, 0xD2}, 14, 4, "1234567890.1234"},
+		{[]byte{0x7E, 0xF2, 0x04, 0xC7, 0x2D, 0xFB, 0x2D}, 14, 4, "-1234567890.1234"},
+		{[]byte{0x80, 0x00, 0x00}, 5, 0, "0"},
+	}
+	for _, c := range cases {
+		r := &reader{data: c.data}
+		if got := decodeDecimal(r, c.precision, c.scale); got != c.want || r.err != nil {
+			t.Errorf("decodeDecimal(%x) = %q, %v, want %q", c.data, got, r.err, c.want)
+		}
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	// {"a":1}：small object，1 个成员，key 偏移 11，值为内联的 int16
+	data := []byte{0x00, 0x01, 0x00, 0x0C, 0x00, 0x0B, 0x00, 0x01, 0x00, 0x05, 0x01, 0x00, 'a'}
+	got, err := decodeJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != `{"a":1}` {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestDecodeDatetime2(t *testing.T) {
+	tm := &TableMap{
+		ColumnTypes: []byte{TypeDatetime2},
+		ColumnMeta:  []uint16{0},
+		Unsigned:    []bool{false},
+	}
+	// 2024-02-29 13:45:30
+	ymd := int64((2024*13+2))<<5 | 29
+	hms := int64(13)<<12 | 45<<6 | 30
+	v := uint64(ymd<<17|hms) + 0x8000000000
+	r := &reader{data: []byte{byte(v >> 32), byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}}
+	got, err := tm.decodeValue(r, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2024, 2, 29, 13, 45, 30, 0, time.UTC)
+	if !got.(time.Time).Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+// packTime2 按 MySQL 的 my_time_packed_to_binary 编码 TIME(fsp)
+func packTime2(d time.Duration, fsp int) []byte {
+	neg := d < 0
+	if neg {
+		d = -d
+	}
+	h, m, s := int64(d/time.Hour), int64(d/time.Minute%60), int64(d/time.Second%60)
+	packed := (h<<12|m<<6|s)<<24 + int64(d%time.Second/time.Microsecond)
+	if neg {
+		packed = -packed
+	}
+	be := func(v int64, n int) []byte {
+		b := make([]byte, n)
+		for i := n - 1; i >= 0; i-- {
+			b[i] = byte(v)
+			v >>= 8
+		}
+		return b
+	}
+	intPart, frac := packed>>24, packed%(1<<24)
+	switch (fsp + 1) / 2 {
+	case 1:
+		return append(be(0x800000+intPart, 3), byte(frac/10000))
+	case 2:
+		return append(be(0x800000+intPart, 3), be(frac/100, 2)...)
+	case 3:
+		return be(packed+0x800000000000, 6)
+	}
+	return be(0x800000+intPart, 3)
+}
+
+func TestDecodeTime2(t *testing.T) {
+	// TIME(6) '-00:00:00.500000' 的打包值为 -500000，加上偏移 0x800000000000 后存储
+	r := &reader{data: []byte{0x7F, 0xFF, 0xFF, 0xF8, 0x5E, 0xE0}}
+	if got := decodeTime2(r, 6); got != -500*time.Millisecond || r.err != nil {
+		t.Fatalf("decodeTime2 = %v, %v, want -500ms", got, r.err)
+	}
+
+	values := []time.Duration{
+		0,
+		time.Second,
+		12*time.Hour + 34*time.Minute + 56*time.Second,
+		838*time.Hour + 59*time.Minute + 59*time.Second,
+	}
+	fracs := map[int]time.Duration{
+		0: 0,
+		1: 500 * time.Millisecond,
+		2: 120 * time.Millisecond,
+		3: 123 * time.Millisecond,
+		4: 123400 * time.Microsecond,
+		5: 123450 * time.Microsecond,
+		6: 123456 * time.Microsecond,
+	}
+	for fsp := 0; fsp <= 6; fsp++ {
+		for _, v := range values {
+			for _, want := range []time.Duration{v + fracs[fsp], -(v + fracs[fsp])} {
+				data := packTime2(want, fsp)
+				r := &reader{data: data}
+				if got := decodeTime2(r, fsp); got != want || r.err != nil {
+					t.Errorf("fsp %d: decodeTime2(%x) = %v, %v, want %v", fsp, data, got, r.err, want)
+				}
+			}
+		}
+		// 只有小数部分的负值
+		if fsp > 0 {
+			want := -fracs[fsp]
+			data := packTime2(want, fsp)
+			if got := decodeTime2(&reader{data: data}, fsp); got != want {
+				t.Errorf("fsp %d: decodeTime2(%x) = %v, want %v", fsp, data, got, want)
+			}
+		}
+	}
+}
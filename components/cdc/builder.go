@@ -0,0 +1,47 @@
+package cdc
+
+import (
+	"github.com/pkg/errors"
+	"github.com/puper/leo/components/cdc/config"
+	"github.com/puper/leo/components/db"
+	"github.com/puper/leo/engine"
+)
+
+func Builder(cfg *config.Config, configurers ...func(*CDC) error) engine.Builder {
+	return func() (any, error) {
+		me := New(cfg)
+		for _, configurer := range configurers {
+			if err := configurer(me); err != nil {
+				return nil, errors.WithMessage(err, "cdc.configurer")
+			}
+		}
+		if err := me.Start(); err != nil {
+			return nil, errors.WithMessage(err, "cdc.Start")
+		}
+		return me, nil
+	}
+}
+
+// WithDb 提供 Server 的连接串，并在 Checkpoint 为 db 时保存检查点
+func WithDb(f func() *db.Db) func(*CDC) error {
+	return func(me *CDC) error {
+		me.db = f()
+		return nil
+	}
+}
+
+// WithHandler 添加变更的处理方，多个 Handler 按添加顺序依次调用
+func WithHandler(h Handler) func(*CDC) error {
+	return func(me *CDC) error {
+		me.handlers = append(me.handlers, h)
+		return nil
+	}
+}
+
+// WithCheckpointer 替换由配置创建的 Checkpointer
+func WithCheckpointer(c Checkpointer) func(*CDC) error {
+	return func(me *CDC) error {
+		me.checkpointer = c
+		return nil
+	}
+}
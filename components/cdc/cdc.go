@@ -0,0 +1,444 @@
+// Package cdc 通过 MySQL 复制协议读取行格式 binlog，将配置的表的行变更解码为 Change 交给 Handler 处理。
+// 检查点在事务提交后保存，重连或重启后从检查点继续，投递语义为至少一次。
+// 服务端需开启 binlog_format=ROW；binlog_row_metadata=FULL 时列名取自 binlog，否则查询 information_schema，
+// 后者在表结构变更后处理变更前的旧事件时可能与实际列不符。
+package cdc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/puper/leo/components/cdc/binlog"
+	"github.com/puper/leo/components/cdc/config"
+	"github.com/puper/leo/components/db"
+)
+
+const (
+	defaultName               = "cdc"
+	defaultServerID           = 1001
+	defaultCheckpointInterval = time.Second
+	defaultHeartbeatPeriod    = 30 * time.Second
+	defaultRetryInterval      = 5 * time.Second
+	saveTimeout               = 5 * time.Second
+)
+
+type CDC struct {
+	config       *config.Config
+	db           *db.Db
+	handlers     handlers
+	checkpointer Checkpointer
+	options      binlog.Options
+	tables       []string
+
+	// 以下字段只在 run 所在的 goroutine 中访问
+	meta    *binlog.Conn
+	columns map[string]*columns
+	saved   binlog.Position
+	savedAt time.Time
+
+	mu  sync.RWMutex
+	pos binlog.Position
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type columns struct {
+	names      []string
+	primaryKey []string
+}
+
+func New(cfg *config.Config) *CDC {
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &CDC{
+		config:  cfg,
+		columns: map[string]*columns{},
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+func (me *CDC) Start() error {
+	if len(me.handlers) == 0 {
+		return errors.New("handler is nil")
+	}
+	dsn := me.config.DSN
+	if dsn == "" {
+		if me.db == nil {
+			return errors.New("db is nil")
+		}
+		server, ok := me.db.ServerConfig(me.config.Server)
+		if !ok {
+			return fmt.Errorf("server `%s`: %w", me.config.Server, db.ErrServerNotFound)
+		}
+		dsn = server.Master
+	}
+	c, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return err
+	}
+	if c.Net != "tcp" {
+		return fmt.Errorf("unsupported network %s", c.Net)
+	}
+	me.options = binlog.Options{
+		Addr:     c.Addr,
+		User:     c.User,
+		Password: c.Passwd,
+		DB:       c.DBName,
+		Timeout:  c.Timeout,
+	}
+	for _, table := range me.config.Tables {
+		if _, err := path.Match(table, ""); err != nil {
+			return fmt.Errorf("table pattern %s: %w", table, err)
+		}
+		if !strings.Contains(table, ".") {
+			table = c.DBName + "." + table
+		}
+		me.tables = append(me.tables, table)
+	}
+	if me.checkpointer == nil {
+		if me.checkpointer, err = me.newCheckpointer(); err != nil {
+			return err
+		}
+	}
+	me.wg.Add(1)
+	go me.run()
+	return nil
+}
+
+func (me *CDC) newCheckpointer() (Checkpointer, error) {
+	kind := me.config.Checkpoint
+	if kind == "" {
+		kind = "file"
+		if me.db != nil {
+			kind = "db"
+		}
+	}
+	switch kind {
+	case "file":
+		file := me.config.CheckpointPath
+		if file == "" {
+			file = me.name() + ".checkpoint.json"
+		}
+		return NewFileCheckpointer(file), nil
+	case "db":
+		if me.db == nil {
+			return nil, errors.New("db is nil")
+		}
+		server := me.config.CheckpointServer
+		if server == "" {
+			server = me.config.Server
+		}
+		checkpointer, err := NewDbCheckpointer(me.db, server, me.config.CheckpointTable, me.name())
+		if err != nil {
+			return nil, err
+		}
+		return checkpointer, nil
+	}
+	return nil, fmt.Errorf("unknown checkpoint %s", kind)
+}
+
+// Close 停止读取并保存最后处理完的位置
+func (me *CDC) Close() error {
+	me.cancel()
+	me.wg.Wait()
+	return me.save(true)
+}
+
+// Position 返回最后一个处理完的事务结束处的位置
+func (me *CDC) Position() binlog.Position {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	return me.pos
+}
+
+func (me *CDC) run() {
+	defer me.wg.Done()
+	defer me.closeMeta()
+	for {
+		err := me.stream()
+		if me.ctx.Err() != nil {
+			return
+		}
+		log.Printf("cdc: stream failed: %v", err)
+		select {
+		case <-me.ctx.Done():
+			return
+		case <-time.After(me.retryInterval()):
+		}
+	}
+}
+
+func (me *CDC) stream() error {
+	conn, err := binlog.Dial(me.ctx, me.options)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// Next 阻塞在读取上，Close 时通过关闭连接使其返回
+	stop := context.AfterFunc(me.ctx, func() {
+		conn.Close()
+	})
+	defer stop()
+	pos, err := me.startPosition(conn)
+	if err != nil {
+		return err
+	}
+	heartbeat := me.heartbeatPeriod()
+	if err := conn.Exec(fmt.Sprintf("SET @master_heartbeat_period = %d", heartbeat.Nanoseconds())); err != nil {
+		return err
+	}
+	streamer, err := conn.Dump(pos, me.serverID())
+	if err != nil {
+		return err
+	}
+	log.Printf("cdc: streaming from %s", pos)
+	for {
+		conn.SetReadDeadline(time.Now().Add(3 * heartbeat))
+		evt, err := streamer.Next()
+		if err != nil {
+			return err
+		}
+		switch data := evt.Data.(type) {
+		case *binlog.Rows:
+			if err := me.handleRows(data, evt.Header, streamer.Position()); err != nil {
+				return err
+			}
+		case *binlog.XID:
+			me.commit(streamer.Position())
+		case *binlog.Query:
+			// BEGIN 之外的语句是 DDL 或非事务表的 COMMIT，其后的位置都位于事务边界
+			if data.Query != "BEGIN" {
+				if data.Query != "COMMIT" {
+					clear(me.columns)
+				}
+				me.commit(streamer.Position())
+			}
+		case *binlog.Rotate:
+			me.commit(streamer.Position())
+		}
+		if err := me.save(false); err != nil {
+			log.Printf("cdc: save checkpoint failed: %v", err)
+		}
+	}
+}
+
+func (me *CDC) startPosition(conn *binlog.Conn) (binlog.Position, error) {
+	if pos := me.Position(); pos.File != "" {
+		return pos, nil
+	}
+	ctx, cancel := context.WithTimeout(me.ctx, saveTimeout)
+	defer cancel()
+	saved, err := me.checkpointer.Load(ctx)
+	if err != nil {
+		return binlog.Position{}, fmt.Errorf("load checkpoint: %w", err)
+	}
+	var pos binlog.Position
+	switch {
+	case saved != nil:
+		pos = *saved
+		me.saved = pos
+	case me.config.StartFile != "":
+		pos = binlog.Position{File: me.config.StartFile, Pos: max(me.config.StartPos, 4)}
+	default:
+		if pos, err = conn.MasterStatus(); err != nil {
+			return binlog.Position{}, err
+		}
+	}
+	me.commit(pos)
+	return pos, nil
+}
+
+func (me *CDC) commit(pos binlog.Position) {
+	me.mu.Lock()
+	me.pos = pos
+	me.mu.Unlock()
+}
+
+// save 保存检查点，force 为 false 时最多每 CheckpointInterval 保存一次
+func (me *CDC) save(force bool) error {
+	pos := me.Position()
+	if pos == me.saved || (!force && time.Since(me.savedAt) < me.checkpointInterval()) {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), saveTimeout)
+	defer cancel()
+	if err := me.checkpointer.Save(ctx, pos); err != nil {
+		return err
+	}
+	me.saved, me.savedAt = pos, time.Now()
+	return nil
+}
+
+func (me *CDC) match(schema, table string) bool {
+	if len(me.tables) == 0 {
+		return true
+	}
+	name := schema + "." + table
+	for _, pattern := range me.tables {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (me *CDC) handleRows(rows *binlog.Rows, header binlog.EventHeader, pos binlog.Position) error {
+	tm := rows.Table
+	if !me.match(tm.Schema, tm.Table) {
+		return nil
+	}
+	cols, err := me.columnsOf(tm)
+	if err != nil {
+		return fmt.Errorf("columns of %s.%s: %w", tm.Schema, tm.Table, err)
+	}
+	step := 1
+	if rows.IsUpdate() {
+		step = 2
+	}
+	for i := 0; i+step <= len(rows.Rows); i += step {
+		c := &Change{
+			Schema:     tm.Schema,
+			Table:      tm.Table,
+			PrimaryKey: cols.primaryKey,
+			Position:   pos,
+			Index:      i / step,
+			Timestamp:  time.Unix(int64(header.Timestamp), 0),
+		}
+		switch {
+		case rows.IsInsert():
+			c.Type = Insert
+			c.After = cols.row(rows.Rows[i])
+		case rows.IsUpdate():
+			c.Type = Update
+			c.Before = cols.row(rows.Rows[i])
+			c.After = cols.row(rows.Rows[i+1])
+		default:
+			c.Type = Delete
+			c.Before = cols.row(rows.Rows[i])
+		}
+		if err := me.handlers.Handle(me.ctx, c); err != nil {
+			return fmt.Errorf("handle %s: %w", c.ID(), err)
+		}
+	}
+	return nil
+}
+
+func (me *columns) row(values []any) map[string]any {
+	row := make(map[string]any, len(values))
+	for i, v := range values {
+		if i < len(me.names) {
+			row[me.names[i]] = v
+		} else {
+			row[fmt.Sprintf("@%d", i+1)] = v
+		}
+	}
+	return row
+}
+
+// columnsOf 返回表的列名与主键，优先使用 TABLE_MAP 中的可选元数据，否则查询 information_schema 并缓存到下一个 DDL
+func (me *CDC) columnsOf(tm *binlog.TableMap) (*columns, error) {
+	if len(tm.ColumnNames) == len(tm.ColumnTypes) {
+		cols := &columns{names: tm.ColumnNames}
+		for _, i := range tm.PrimaryKey {
+			if i < len(cols.names) {
+				cols.primaryKey = append(cols.primaryKey, cols.names[i])
+			}
+		}
+		return cols, nil
+	}
+	key := tm.Schema + "." + tm.Table
+	if cols, ok := me.columns[key]; ok && len(cols.names) == len(tm.ColumnTypes) {
+		return cols, nil
+	}
+	cols, err := me.queryColumns(tm.Schema, tm.Table)
+	if err != nil {
+		return nil, err
+	}
+	if len(cols.names) != len(tm.ColumnTypes) {
+		log.Printf("cdc: %s has %d columns in information_schema but %d in binlog", key, len(cols.names), len(tm.ColumnTypes))
+	}
+	me.columns[key] = cols
+	return cols, nil
+}
+
+var quoter = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+func (me *CDC) queryColumns(schema, table string) (*columns, error) {
+	if me.meta == nil {
+		conn, err := binlog.Dial(me.ctx, me.options)
+		if err != nil {
+			return nil, err
+		}
+		me.meta = conn
+	}
+	result, err := me.meta.Query(fmt.Sprintf(
+		"SELECT COLUMN_NAME, COLUMN_KEY FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = '%s' AND TABLE_NAME = '%s' ORDER BY ORDINAL_POSITION",
+		quoter.Replace(schema), quoter.Replace(table),
+	))
+	if err != nil {
+		me.closeMeta()
+		return nil, err
+	}
+	cols := &columns{}
+	for _, row := range result.Rows {
+		cols.names = append(cols.names, row[0].String)
+		if row[1].String == "PRI" {
+			cols.primaryKey = append(cols.primaryKey, row[0].String)
+		}
+	}
+	return cols, nil
+}
+
+func (me *CDC) closeMeta() {
+	if me.meta != nil {
+		me.meta.Close()
+		me.meta = nil
+	}
+}
+
+func (me *CDC) name() string {
+	if me.config.Name != "" {
+		return me.config.Name
+	}
+	return defaultName
+}
+
+func (me *CDC) serverID() uint32 {
+	if me.config.ServerID > 0 {
+		return me.config.ServerID
+	}
+	return defaultServerID
+}
+
+func (me *CDC) checkpointInterval() time.Duration {
+	if me.config.CheckpointInterval > 0 {
+		return me.config.CheckpointInterval
+	}
+	return defaultCheckpointInterval
+}
+
+func (me *CDC) heartbeatPeriod() time.Duration {
+	if me.config.HeartbeatPeriod > 0 {
+		return me.config.HeartbeatPeriod
+	}
+	return defaultHeartbeatPeriod
+}
+
+func (me *CDC) retryInterval() time.Duration {
+	if me.config.RetryInterval > 0 {
+		return me.config.RetryInterval
+	}
+	return defaultRetryInterval
+}
@@ -0,0 +1,227 @@
+package cdc
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/puper/leo/components/cdc/binlog"
+	"github.com/puper/leo/components/cdc/cdctest"
+	"github.com/puper/leo/components/cdc/config"
+	"github.com/puper/leo/components/db"
+	dbconfig "github.com/puper/leo/components/db/config"
+)
+
+type collector struct {
+	mu      sync.Mutex
+	changes []*Change
+	fail    int
+}
+
+func (me *collector) Handle(ctx context.Context, c *Change) error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if me.fail > 0 {
+		me.fail--
+		return errors.New("handler failed")
+	}
+	me.changes = append(me.changes, c)
+	return nil
+}
+
+func (me *collector) snapshot() []*Change {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	return append([]*Change(nil), me.changes...)
+}
+
+func waitPosition(t *testing.T, c *CDC, want binlog.Position) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Position() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("position %s, want %s", c.Position(), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func startCDC(t *testing.T, server *cdctest.Server, cfg *config.Config, h Handler) *CDC {
+	t.Helper()
+	cfg.DSN = server.DSN()
+	if cfg.CheckpointPath == "" {
+		cfg.CheckpointPath = filepath.Join(t.TempDir(), "checkpoint.json")
+	}
+	cfg.RetryInterval = 10 * time.Millisecond
+	c := New(cfg)
+	c.handlers = append(c.handlers, h)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+var userColumns = []cdctest.Column{
+	{Name: "id", Type: cdctest.Int, PrimaryKey: true},
+	{Name: "name", Type: cdctest.String},
+	{Name: "score", Type: cdctest.Float},
+	{Name: "created_at", Type: cdctest.Time},
+}
+
+func TestStream(t *testing.T) {
+	server := cdctest.NewServer()
+	defer server.Close()
+	server.CreateTable("app", "users", userColumns...)
+	server.CreateTable("app", "logs", cdctest.Column{Name: "id", Type: cdctest.Int})
+
+	h := &collector{}
+	c := startCDC(t, server, &config.Config{Tables: []string{"app.users"}}, h)
+	defer c.Close()
+	waitPosition(t, c, server.Position())
+
+	created := time.Date(2024, 5, 1, 8, 0, 0, 123456000, time.UTC)
+	server.Insert("app", "users", []any{1, "alice", 1.5, created}, []any{2, "bob", nil, created})
+	server.Insert("app", "logs", []any{1})
+	server.Update("app", "users", []any{1, "alice", 1.5, created}, []any{1, "alice", 2.5, created})
+	server.Delete("app", "users", []any{2, "bob", nil, created})
+	waitPosition(t, c, server.Position())
+
+	changes := h.snapshot()
+	if len(changes) != 4 {
+		t.Fatalf("got %d changes, want 4", len(changes))
+	}
+	if changes[0].Type != Insert || changes[0].After["name"] != "alice" || changes[0].After["id"] != int64(1) {
+		t.Fatalf("unexpected insert %+v", changes[0])
+	}
+	if !changes[0].After["created_at"].(time.Time).Equal(created) {
+		t.Fatalf("created_at = %v", changes[0].After["created_at"])
+	}
+	if changes[1].After["score"] != nil || changes[1].Index != 1 || changes[1].ID() == changes[0].ID() {
+		t.Fatalf("unexpected second insert %+v", changes[1])
+	}
+	if changes[2].Type != Update || changes[2].Before["score"] != 1.5 || changes[2].After["score"] != 2.5 {
+		t.Fatalf("unexpected update %+v", changes[2])
+	}
+	if changes[3].Type != Delete || changes[3].Key() != "2" || changes[3].Subject() != "app.users.delete" {
+		t.Fatalf("unexpected delete %+v", changes[3])
+	}
+
+	var user struct {
+		ID        int
+		Name      string
+		Score     float64
+		CreatedAt time.Time
+	}
+	if err := changes[2].Scan(&user); err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != 1 || user.Name != "alice" || user.Score != 2.5 || !user.CreatedAt.Equal(created) {
+		t.Fatalf("unexpected scan result %+v", user)
+	}
+}
+
+func TestResumeFromCheckpoint(t *testing.T) {
+	server := cdctest.NewServer()
+	defer server.Close()
+	server.CreateTable("app", "users", userColumns...)
+	cfg := &config.Config{CheckpointPath: filepath.Join(t.TempDir(), "checkpoint.json")}
+
+	h := &collector{}
+	c := startCDC(t, server, cfg, h)
+	waitPosition(t, c, server.Position())
+	server.Insert("app", "users", []any{1, "alice", 1.0, time.Now()})
+	waitPosition(t, c, server.Position())
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	server.Insert("app", "users", []any{2, "bob", 2.0, time.Now()})
+	server.Rotate()
+	server.Insert("app", "users", []any{3, "carol", 3.0, time.Now()})
+
+	h2 := &collector{}
+	c2 := startCDC(t, server, cfg, h2)
+	defer c2.Close()
+	waitPosition(t, c2, server.Position())
+	changes := h2.snapshot()
+	if len(changes) != 2 || changes[0].Key() != "2" || changes[1].Key() != "3" {
+		t.Fatalf("unexpected changes after resume: %d", len(changes))
+	}
+}
+
+func TestRedeliverAfterFailure(t *testing.T) {
+	server := cdctest.NewServer()
+	defer server.Close()
+	server.CreateTable("app", "users", userColumns...)
+
+	h := &collector{}
+	c := startCDC(t, server, &config.Config{}, h)
+	defer c.Close()
+	waitPosition(t, c, server.Position())
+
+	h.mu.Lock()
+	h.fail = 1
+	h.mu.Unlock()
+	server.Insert("app", "users", []any{1, "alice", 1.0, time.Now()})
+	waitPosition(t, c, server.Position())
+	server.CloseClientConnections()
+	server.Insert("app", "users", []any{2, "bob", 2.0, time.Now()})
+	waitPosition(t, c, server.Position())
+
+	changes := h.snapshot()
+	if len(changes) != 2 || changes[0].Key() != "1" || changes[1].Key() != "2" {
+		t.Fatalf("unexpected changes: %d", len(changes))
+	}
+}
+
+func TestColumnsFromInformationSchema(t *testing.T) {
+	server := cdctest.NewServer()
+	defer server.Close()
+	server.MinimalMetadata = true
+	server.CreateTable("app", "users", userColumns[:2]...)
+
+	h := &collector{}
+	c := startCDC(t, server, &config.Config{Tables: []string{"app.u*"}}, h)
+	defer c.Close()
+	waitPosition(t, c, server.Position())
+
+	server.Insert("app", "users", []any{1, "alice"})
+	server.CreateTable("app", "users", userColumns[:3]...)
+	server.Insert("app", "users", []any{2, "bob", 2.0})
+	waitPosition(t, c, server.Position())
+
+	changes := h.snapshot()
+	if len(changes) != 2 {
+		t.Fatalf("got %d changes, want 2", len(changes))
+	}
+	if changes[0].After["name"] != "alice" || changes[0].PrimaryKey[0] != "id" {
+		t.Fatalf("unexpected change %+v", changes[0])
+	}
+	if changes[1].After["score"] != 2.0 {
+		t.Fatalf("columns not refreshed after DDL: %+v", changes[1])
+	}
+}
+
+func TestDbCheckpointerUnknownServer(t *testing.T) {
+	d, err := db.New(&dbconfig.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := New(&config.Config{DSN: "root@tcp(127.0.0.1:3306)/app", Checkpoint: "db", CheckpointServer: "main"})
+	c.db = d
+	c.handlers = append(c.handlers, &collector{})
+	if err := c.Start(); !errors.Is(err, db.ErrServerNotFound) {
+		t.Fatalf("expected ErrServerNotFound, got %v", err)
+	}
+	// server 在运行时被移除时返回错误而不是 panic
+	checkpointer := &DbCheckpointer{db: d, server: "main", table: defaultCheckpointTable, name: "app"}
+	if _, err := checkpointer.Load(context.Background()); !errors.Is(err, db.ErrServerNotFound) {
+		t.Fatalf("expected ErrServerNotFound, got %v", err)
+	}
+	if err := checkpointer.Save(context.Background(), binlog.Position{}); !errors.Is(err, db.ErrServerNotFound) {
+		t.Fatalf("expected ErrServerNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,714 @@
+// Package cdctest 提供一个说 MySQL 协议的替身服务端，用于在没有真实 MySQL 的环境中测试 cdc 组件。
+// 它只实现 cdc 用到的部分：握手 (接受任意密码)、SET、SHOW BINARY LOG STATUS、
+// information_schema.COLUMNS 查询、注册从库与 COM_BINLOG_DUMP，binlog 由 Insert、Update、Delete 等方法写入内存。
+package cdctest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/puper/leo/components/cdc/binlog"
+)
+
+const (
+	serverVersion = "8.0.36-cdctest"
+	serverID      = 1
+
+	eventHeaderSize = 19
+	checksumSize    = 4
+
+	typeQuery             = 2
+	typeRotate            = 4
+	typeFormatDescription = 15
+	typeXID               = 16
+	typeTableMap          = 19
+	typeHeartbeat         = 27
+	typeWriteRows         = 30
+	typeUpdateRows        = 31
+	typeDeleteRows        = 32
+
+	artificialFlag = 0x20
+)
+
+type ColumnType int
+
+const (
+	Int ColumnType = iota
+	String
+	Float
+	Bytes
+	Time
+)
+
+type Column struct {
+	Name       string
+	Type       ColumnType
+	PrimaryKey bool
+}
+
+type table struct {
+	id      uint64
+	schema  string
+	name    string
+	columns []Column
+}
+
+type event struct {
+	start uint32
+	data  []byte
+}
+
+type file struct {
+	name   string
+	size   uint32
+	events []event
+}
+
+// Server 是内存中的 binlog 与复制协议服务端，方法是并发安全的
+type Server struct {
+	// MinimalMetadata 为 true 时 TABLE_MAP 不带列名与主键，模拟 binlog_row_metadata=MINIMAL，应在写入前设置
+	MinimalMetadata bool
+
+	ln      net.Listener
+	mu      sync.Mutex
+	files   []*file
+	tables  map[string]*table
+	tableID uint64
+	// changed 在写入新事件时关闭并替换，用于唤醒正在等待的 dump 连接
+	changed chan struct{}
+	conns   map[net.Conn]struct{}
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// NewServer 在 127.0.0.1 的随机端口上启动服务端，失败时 panic
+func NewServer() *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("cdctest: listen: %v", err))
+	}
+	me := &Server{
+		ln:      ln,
+		tables:  map[string]*table{},
+		changed: make(chan struct{}),
+		conns:   map[net.Conn]struct{}{},
+	}
+	me.newFile()
+	me.wg.Add(1)
+	go me.serve()
+	return me
+}
+
+func (me *Server) Addr() string {
+	return me.ln.Addr().String()
+}
+
+// DSN 返回 go-sql-driver/mysql 格式的连接串
+func (me *Server) DSN() string {
+	return fmt.Sprintf("root:cdctest@tcp(%s)/", me.Addr())
+}
+
+// Position 返回当前 binlog 的末尾位置
+func (me *Server) Position() binlog.Position {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	f := me.files[len(me.files)-1]
+	return binlog.Position{File: f.name, Pos: f.size}
+}
+
+// Close 关闭监听与所有连接
+func (me *Server) Close() {
+	me.mu.Lock()
+	me.closed = true
+	me.ln.Close()
+	for conn := range me.conns {
+		conn.Close()
+	}
+	close(me.changed)
+	me.changed = make(chan struct{})
+	me.mu.Unlock()
+	me.wg.Wait()
+}
+
+// CloseClientConnections 断开当前所有客户端连接，用于测试重连
+func (me *Server) CloseClientConnections() {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	for conn := range me.conns {
+		conn.Close()
+	}
+}
+
+// CreateTable 登记表结构并写入一条 DDL 查询事件，表已存在时替换其结构 (模拟 ALTER TABLE)
+func (me *Server) CreateTable(schema, name string, columns ...Column) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	key := schema + "." + name
+	t, ok := me.tables[key]
+	if !ok {
+		me.tableID++
+		t = &table{id: me.tableID, schema: schema, name: name}
+		me.tables[key] = t
+	}
+	t.columns = columns
+	var defs []string
+	for _, c := range columns {
+		defs = append(defs, "`"+c.Name+"` "+c.Type.sqlType())
+	}
+	me.appendEvent(typeQuery, queryBody(schema, fmt.Sprintf("CREATE TABLE `%s` (%s)", name, strings.Join(defs, ", "))))
+}
+
+// Insert 写入一个插入 rows 的事务，每行的值与 CreateTable 的列一一对应
+func (me *Server) Insert(schema, name string, rows ...[]any) {
+	me.writeRows(typeWriteRows, schema, name, rows)
+}
+
+// Update 写入一个更新事务，rows 依次为 更新前、更新后 成对出现
+func (me *Server) Update(schema, name string, rows ...[]any) {
+	if len(rows)%2 != 0 {
+		panic("cdctest: update rows must be before/after pairs")
+	}
+	me.writeRows(typeUpdateRows, schema, name, rows)
+}
+
+func (me *Server) Delete(schema, name string, rows ...[]any) {
+	me.writeRows(typeDeleteRows, schema, name, rows)
+}
+
+// Rotate 切换到新的 binlog 文件
+func (me *Server) Rotate() {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	next := fmt.Sprintf("binlog.%06d", len(me.files)+1)
+	body := binary.LittleEndian.AppendUint64(nil, 4)
+	me.appendEvent(typeRotate, append(body, next...))
+	me.newFile()
+}
+
+func (me *Server) writeRows(typ byte, schema, name string, rows [][]any) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	t, ok := me.tables[schema+"."+name]
+	if !ok {
+		panic(fmt.Sprintf("cdctest: table %s.%s not found", schema, name))
+	}
+	me.appendEvent(typeQuery, queryBody(schema, "BEGIN"))
+	me.appendEvent(typeTableMap, me.tableMapBody(t))
+	me.appendEvent(typ, rowsBody(t, typ, rows))
+	me.appendEvent(typeXID, binary.LittleEndian.AppendUint64(nil, uint64(time.Now().UnixNano())))
+}
+
+func (me *Server) newFile() {
+	f := &file{name: fmt.Sprintf("binlog.%06d", len(me.files)+1), size: 4}
+	me.files = append(me.files, f)
+	var body []byte
+	body = binary.LittleEndian.AppendUint16(body, 4)
+	version := make([]byte, 50)
+	copy(version, serverVersion)
+	body = append(body, version...)
+	body = binary.LittleEndian.AppendUint32(body, uint32(time.Now().Unix()))
+	body = append(body, eventHeaderSize)
+	postHeaderLen := make([]byte, 40)
+	postHeaderLen[typeQuery-1] = 13
+	postHeaderLen[typeRotate-1] = 8
+	postHeaderLen[typeTableMap-1] = 8
+	postHeaderLen[typeWriteRows-1] = 10
+	postHeaderLen[typeUpdateRows-1] = 10
+	postHeaderLen[typeDeleteRows-1] = 10
+	body = append(body, postHeaderLen...)
+	body = append(body, 1) // CRC32
+	me.appendEvent(typeFormatDescription, body)
+}
+
+// appendEvent 编码事件并追加到当前文件，调用方持有锁
+func (me *Server) appendEvent(typ byte, body []byte) {
+	f := me.files[len(me.files)-1]
+	size := uint32(eventHeaderSize + len(body) + checksumSize)
+	data := encodeEvent(typ, f.size+size, 0, body)
+	f.events = append(f.events, event{start: f.size, data: data})
+	f.size += size
+	close(me.changed)
+	me.changed = make(chan struct{})
+}
+
+func encodeEvent(typ byte, logPos uint32, flags uint16, body []byte) []byte {
+	var data []byte
+	data = binary.LittleEndian.AppendUint32(data, uint32(time.Now().Unix()))
+	data = append(data, typ)
+	data = binary.LittleEndian.AppendUint32(data, serverID)
+	data = binary.LittleEndian.AppendUint32(data, uint32(eventHeaderSize+len(body)+checksumSize))
+	data = binary.LittleEndian.AppendUint32(data, logPos)
+	data = binary.LittleEndian.AppendUint16(data, flags)
+	data = append(data, body...)
+	return binary.LittleEndian.AppendUint32(data, crc32.ChecksumIEEE(data))
+}
+
+func queryBody(schema, query string) []byte {
+	var body []byte
+	body = binary.LittleEndian.AppendUint32(body, 1) // thread id
+	body = binary.LittleEndian.AppendUint32(body, 0) // execution time
+	body = append(body, byte(len(schema)))
+	body = binary.LittleEndian.AppendUint16(body, 0) // error code
+	body = binary.LittleEndian.AppendUint16(body, 0) // status vars
+	body = append(body, schema...)
+	body = append(body, 0)
+	return append(body, query...)
+}
+
+func (me ColumnType) sqlType() string {
+	switch me {
+	case String:
+		return "VARCHAR(255)"
+	case Float:
+		return "DOUBLE"
+	case Bytes:
+		return "BLOB"
+	case Time:
+		return "DATETIME(6)"
+	}
+	return "BIGINT"
+}
+
+func (me ColumnType) binlogType() byte {
+	switch me {
+	case String:
+		return binlog.TypeVarchar
+	case Float:
+		return binlog.TypeDouble
+	case Bytes:
+		return binlog.TypeBlob
+	case Time:
+		return binlog.TypeDatetime2
+	}
+	return binlog.TypeLongLong
+}
+
+func (me *Server) tableMapBody(t *table) []byte {
+	var body []byte
+	body = appendUint48(body, t.id)
+	body = binary.LittleEndian.AppendUint16(body, 0)
+	body = append(body, byte(len(t.schema)))
+	body = append(append(body, t.schema...), 0)
+	body = append(body, byte(len(t.name)))
+	body = append(append(body, t.name...), 0)
+	body = appendLenencInt(body, uint64(len(t.columns)))
+	var meta []byte
+	numeric := 0
+	for _, c := range t.columns {
+		body = append(body, c.Type.binlogType())
+		switch c.Type {
+		case String:
+			meta = binary.LittleEndian.AppendUint16(meta, 1020) // VARCHAR(255) utf8mb4
+		case Float:
+			meta = append(meta, 8)
+			numeric++
+		case Bytes:
+			meta = append(meta, 2)
+		case Time:
+			meta = append(meta, 6)
+		default:
+			numeric++
+		}
+	}
+	body = appendLenencBytes(body, meta)
+	nullable := make([]byte, (len(t.columns)+7)/8)
+	for i := range nullable {
+		nullable[i] = 0xff
+	}
+	body = append(body, nullable...)
+	if me.MinimalMetadata {
+		return body
+	}
+	// SIGNEDNESS，全部为有符号
+	body = append(body, 1)
+	body = appendLenencBytes(body, make([]byte, (numeric+7)/8))
+	var names, primaryKey []byte
+	for i, c := range t.columns {
+		names = appendLenencBytes(names, []byte(c.Name))
+		if c.PrimaryKey {
+			primaryKey = appendLenencInt(primaryKey, uint64(i))
+		}
+	}
+	body = append(body, 4)
+	body = appendLenencBytes(body, names)
+	if len(primaryKey) > 0 {
+		body = append(body, 8)
+		body = appendLenencBytes(body, primaryKey)
+	}
+	return body
+}
+
+func rowsBody(t *table, typ byte, rows [][]any) []byte {
+	var body []byte
+	body = appendUint48(body, t.id)
+	body = binary.LittleEndian.AppendUint16(body, 1) // STMT_END_F
+	body = binary.LittleEndian.AppendUint16(body, 2) // extra data length
+	body = appendLenencInt(body, uint64(len(t.columns)))
+	present := make([]byte, (len(t.columns)+7)/8)
+	for i := range t.columns {
+		present[i/8] |= 1 << (i % 8)
+	}
+	body = append(body, present...)
+	if typ == typeUpdateRows {
+		body = append(body, present...)
+	}
+	for _, row := range rows {
+		if len(row) != len(t.columns) {
+			panic(fmt.Sprintf("cdctest: %s.%s has %d columns, got %d values", t.schema, t.name, len(t.columns), len(row)))
+		}
+		nulls := make([]byte, (len(row)+7)/8)
+		var values []byte
+		for i, v := range row {
+			if v == nil {
+				nulls[i/8] |= 1 << (i % 8)
+				continue
+			}
+			values = appendValue(values, t.columns[i].Type, v)
+		}
+		body = append(append(body, nulls...), values...)
+	}
+	return body
+}
+
+func appendValue(b []byte, typ ColumnType, v any) []byte {
+	switch typ {
+	case String:
+		s := fmt.Sprint(v)
+		b = binary.LittleEndian.AppendUint16(b, uint16(len(s)))
+		return append(b, s...)
+	case Float:
+		f, _ := strconv.ParseFloat(fmt.Sprint(v), 64)
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(f))
+	case Bytes:
+		data, ok := v.([]byte)
+		if !ok {
+			data = []byte(fmt.Sprint(v))
+		}
+		b = binary.LittleEndian.AppendUint16(b, uint16(len(data)))
+		return append(b, data...)
+	case Time:
+		t := v.(time.Time).UTC()
+		ymd := int64((t.Year()*13+int(t.Month())))<<5 | int64(t.Day())
+		hms := int64(t.Hour())<<12 | int64(t.Minute())<<6 | int64(t.Second())
+		packed := uint64(ymd<<17|hms) + 0x8000000000
+		usec := uint32(t.Nanosecond() / 1000)
+		return append(b,
+			byte(packed>>32), byte(packed>>24), byte(packed>>16), byte(packed>>8), byte(packed),
+			byte(usec>>16), byte(usec>>8), byte(usec))
+	}
+	n, _ := strconv.ParseInt(fmt.Sprint(v), 10, 64)
+	return binary.LittleEndian.AppendUint64(b, uint64(n))
+}
+
+func appendUint48(b []byte, v uint64) []byte {
+	b = binary.LittleEndian.AppendUint32(b, uint32(v))
+	return binary.LittleEndian.AppendUint16(b, uint16(v>>32))
+}
+
+func appendLenencInt(b []byte, v uint64) []byte {
+	switch {
+	case v < 0xfb:
+		return append(b, byte(v))
+	case v < 1<<16:
+		return append(b, 0xfc, byte(v), byte(v>>8))
+	case v < 1<<24:
+		return append(b, 0xfd, byte(v), byte(v>>8), byte(v>>16))
+	}
+	return binary.LittleEndian.AppendUint64(append(b, 0xfe), v)
+}
+
+func appendLenencBytes(b, data []byte) []byte {
+	return append(appendLenencInt(b, uint64(len(data))), data...)
+}
+
+func (me *Server) serve() {
+	defer me.wg.Done()
+	for {
+		conn, err := me.ln.Accept()
+		if err != nil {
+			return
+		}
+		me.mu.Lock()
+		if me.closed {
+			me.mu.Unlock()
+			conn.Close()
+			return
+		}
+		me.conns[conn] = struct{}{}
+		me.mu.Unlock()
+		me.wg.Add(1)
+		go func() {
+			defer me.wg.Done()
+			defer func() {
+				me.mu.Lock()
+				delete(me.conns, conn)
+				me.mu.Unlock()
+				conn.Close()
+			}()
+			(&session{server: me, conn: conn}).run()
+		}()
+	}
+}
+
+type session struct {
+	server    *Server
+	conn      net.Conn
+	seq       uint8
+	heartbeat time.Duration
+}
+
+var (
+	heartbeatRe = regexp.MustCompile(`(?i)@master_heartbeat_period\s*=\s*(\d+)`)
+	columnsRe   = regexp.MustCompile(`(?i)TABLE_SCHEMA\s*=\s*'((?:[^'\\]|\\.)*)'\s+AND\s+TABLE_NAME\s*=\s*'((?:[^'\\]|\\.)*)'`)
+)
+
+func (me *session) run() {
+	if err := me.handshake(); err != nil {
+		return
+	}
+	for {
+		data, err := me.readPacket()
+		if err != nil || len(data) == 0 {
+			return
+		}
+		switch data[0] {
+		case 0x01: // COM_QUIT
+			return
+		case 0x03:
+			err = me.query(string(data[1:]))
+		case 0x0e, 0x15: // COM_PING、COM_REGISTER_SLAVE
+			err = me.writeOK()
+		case 0x12:
+			err = me.dump(data[1:])
+		default:
+			err = me.writeError(1047, "08S01", "Unknown command")
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (me *session) handshake() error {
+	var b []byte
+	b = append(b, 10)
+	b = append(append(b, serverVersion...), 0)
+	b = binary.LittleEndian.AppendUint32(b, 1)
+	b = append(b, "12345678"...)
+	b = append(b, 0)
+	capability := uint32(1 | 1<<2 | 1<<3 | 1<<9 | 1<<13 | 1<<15 | 1<<17 | 1<<19 | 1<<21)
+	b = binary.LittleEndian.AppendUint16(b, uint16(capability))
+	b = append(b, 45)
+	b = binary.LittleEndian.AppendUint16(b, 2)
+	b = binary.LittleEndian.AppendUint16(b, uint16(capability>>16))
+	b = append(b, 21)
+	b = append(b, make([]byte, 10)...)
+	b = append(b, "123456789012"...)
+	b = append(b, 0)
+	b = append(append(b, "mysql_native_password"...), 0)
+	me.seq = 0
+	if err := me.writePacket(b); err != nil {
+		return err
+	}
+	if _, err := me.readPacket(); err != nil {
+		return err
+	}
+	return me.writeOK()
+}
+
+func (me *session) query(q string) error {
+	upper := strings.ToUpper(strings.TrimSpace(q))
+	switch {
+	case strings.HasPrefix(upper, "SET "):
+		if m := heartbeatRe.FindStringSubmatch(q); m != nil {
+			n, _ := strconv.ParseInt(m[1], 10, 64)
+			me.heartbeat = time.Duration(n)
+		}
+		return me.writeOK()
+	case upper == "SHOW BINARY LOG STATUS" || upper == "SHOW MASTER STATUS":
+		pos := me.server.Position()
+		return me.writeResult(
+			[]string{"File", "Position", "Binlog_Do_DB", "Binlog_Ignore_DB", "Executed_Gtid_Set"},
+			[][]string{{pos.File, strconv.FormatUint(uint64(pos.Pos), 10), "", "", ""}},
+		)
+	case strings.Contains(upper, "INFORMATION_SCHEMA.COLUMNS"):
+		m := columnsRe.FindStringSubmatch(q)
+		if m == nil {
+			break
+		}
+		me.server.mu.Lock()
+		t := me.server.tables[unescape(m[1])+"."+unescape(m[2])]
+		var rows [][]string
+		if t != nil {
+			for _, c := range t.columns {
+				key := ""
+				if c.PrimaryKey {
+					key = "PRI"
+				}
+				rows = append(rows, []string{c.Name, key})
+			}
+		}
+		me.server.mu.Unlock()
+		return me.writeResult([]string{"COLUMN_NAME", "COLUMN_KEY"}, rows)
+	}
+	return me.writeError(1064, "42000", "cdctest: unsupported query: "+q)
+}
+
+func unescape(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\'`, `'`).Replace(s)
+}
+
+// dump 按 MySQL 的顺序发送伪 Rotate、起始文件的 FDE 与其后的事件，之后等待新事件，空闲时发送心跳
+func (me *session) dump(data []byte) error {
+	if len(data) < 10 {
+		return me.writeError(1236, "HY000", "malformed COM_BINLOG_DUMP")
+	}
+	pos := binary.LittleEndian.Uint32(data)
+	name := string(data[10:])
+	s := me.server
+	s.mu.Lock()
+	index := -1
+	for i, f := range s.files {
+		if f.name == name {
+			index = i
+		}
+	}
+	if index < 0 {
+		s.mu.Unlock()
+		return me.writeError(1236, "HY000", "Could not find first log file name in binary log index file")
+	}
+	fde := s.files[index].events[0].data
+	s.mu.Unlock()
+	if pos < 4 {
+		pos = 4
+	}
+	rotate := binary.LittleEndian.AppendUint64(nil, uint64(pos))
+	if err := me.writeEvent(encodeEvent(typeRotate, 0, artificialFlag, append(rotate, name...))); err != nil {
+		return err
+	}
+	if pos > 4 {
+		// 从文件中间开始时重发的 FDE 的 log_pos 为 0，不影响客户端的位置
+		fde = append([]byte(nil), fde...)
+		binary.LittleEndian.PutUint32(fde[13:], 0)
+		binary.LittleEndian.PutUint32(fde[len(fde)-checksumSize:], crc32.ChecksumIEEE(fde[:len(fde)-checksumSize]))
+	}
+	if err := me.writeEvent(fde); err != nil {
+		return err
+	}
+	next := 1
+	for {
+		s.mu.Lock()
+		f := s.files[index]
+		var pending [][]byte
+		for ; next < len(f.events); next++ {
+			if f.events[next].start >= pos {
+				pending = append(pending, f.events[next].data)
+			}
+		}
+		changed := s.changed
+		closed := s.closed
+		rotated := index+1 < len(s.files)
+		s.mu.Unlock()
+		if closed {
+			return io.EOF
+		}
+		for _, evt := range pending {
+			if err := me.writeEvent(evt); err != nil {
+				return err
+			}
+		}
+		if rotated {
+			index, next, pos = index+1, 0, 4
+			continue
+		}
+		var timeout <-chan time.Time
+		if me.heartbeat > 0 {
+			timeout = time.After(me.heartbeat)
+		}
+		select {
+		case <-changed:
+		case <-timeout:
+			end := s.Position()
+			if err := me.writeEvent(encodeEvent(typeHeartbeat, end.Pos, artificialFlag, []byte(end.File))); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (me *session) writeEvent(data []byte) error {
+	return me.writePacket(append([]byte{0x00}, data...))
+}
+
+func (me *session) readPacket() ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(me.conn, header[:]); err != nil {
+		return nil, err
+	}
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	me.seq = header[3] + 1
+	data := make([]byte, length)
+	_, err := io.ReadFull(me.conn, data)
+	return data, err
+}
+
+func (me *session) writePacket(payload []byte) error {
+	n := len(payload)
+	header := []byte{byte(n), byte(n >> 8), byte(n >> 16), me.seq}
+	me.seq++
+	_, err := me.conn.Write(append(header, payload...))
+	return err
+}
+
+func (me *session) writeOK() error {
+	return me.writePacket([]byte{0x00, 0, 0, 2, 0, 0, 0})
+}
+
+func (me *session) writeError(code uint16, state, message string) error {
+	b := binary.LittleEndian.AppendUint16([]byte{0xff}, code)
+	b = append(b, '#')
+	b = append(b, state...)
+	return me.writePacket(append(b, message...))
+}
+
+func (me *session) writeResult(columns []string, rows [][]string) error {
+	if err := me.writePacket(appendLenencInt(nil, uint64(len(columns)))); err != nil {
+		return err
+	}
+	for _, name := range columns {
+		var b []byte
+		b = appendLenencBytes(b, []byte("def"))
+		b = appendLenencBytes(b, nil)
+		b = appendLenencBytes(b, nil)
+		b = appendLenencBytes(b, nil)
+		b = appendLenencBytes(b, []byte(name))
+		b = appendLenencBytes(b, []byte(name))
+		b = append(b, 0x0c, 45, 0, 255, 0, 0, 0, 0xfd, 0, 0, 0, 0, 0)
+		if err := me.writePacket(b); err != nil {
+			return err
+		}
+	}
+	eof := []byte{0xfe, 0, 0, 2, 0}
+	if err := me.writePacket(eof); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		var b []byte
+		for _, v := range row {
+			b = appendLenencBytes(b, []byte(v))
+		}
+		if err := me.writePacket(b); err != nil {
+			return err
+		}
+	}
+	return me.writePacket(eof)
+}
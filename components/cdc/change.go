@@ -0,0 +1,98 @@
+package cdc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/puper/leo/components/cdc/binlog"
+	"gorm.io/gorm/schema"
+)
+
+type ChangeType string
+
+const (
+	Insert ChangeType = "insert"
+	Update ChangeType = "update"
+	Delete ChangeType = "delete"
+)
+
+// Change 是一行数据的变更，Before、After 的键为列名
+type Change struct {
+	Schema string     `json:"schema"`
+	Table  string     `json:"table"`
+	Type   ChangeType `json:"type"`
+	// Before 为更新、删除前的行，After 为插入、更新后的行
+	Before     map[string]any `json:"before,omitempty"`
+	After      map[string]any `json:"after,omitempty"`
+	PrimaryKey []string       `json:"primaryKey,omitempty"`
+	// Position 为行事件结束处的位置，Index 为行在事件中的序号，二者唯一确定一次变更
+	Position  binlog.Position `json:"position"`
+	Index     int             `json:"index"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// ID 返回 <file>:<pos>:<index>，重复投递时不变，可用于消费方去重
+func (me *Change) ID() string {
+	return fmt.Sprintf("%s:%d", me.Position, me.Index)
+}
+
+// Subject 返回 <schema>.<table>.<type>，用作消息的 routing key 或 subject
+func (me *Change) Subject() string {
+	return me.Schema + "." + me.Table + "." + string(me.Type)
+}
+
+// Row 返回变更后的行，删除时返回删除前的行
+func (me *Change) Row() map[string]any {
+	if me.Type == Delete {
+		return me.Before
+	}
+	return me.After
+}
+
+// Key 返回以 : 连接的主键值，表没有主键时返回空字符串
+func (me *Change) Key() string {
+	row := me.Row()
+	values := make([]string, len(me.PrimaryKey))
+	for i, column := range me.PrimaryKey {
+		values[i] = fmt.Sprint(row[column])
+	}
+	return strings.Join(values, ":")
+}
+
+// Scan 按 gorm 的字段映射把 Row() 写入 dst 指向的结构体，不存在的列被忽略
+func (me *Change) Scan(dst any) error {
+	return scan(me.Row(), dst)
+}
+
+// ScanBefore 把更新、删除前的行写入 dst
+func (me *Change) ScanBefore(dst any) error {
+	return scan(me.Before, dst)
+}
+
+var schemaCache sync.Map
+
+func scan(row map[string]any, dst any) error {
+	if row == nil {
+		return errors.New("cdc: no row to scan")
+	}
+	s, err := schema.Parse(dst, &schemaCache, schema.NamingStrategy{})
+	if err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(dst).Elem()
+	for column, value := range row {
+		field := s.LookUpField(column)
+		if field == nil || field.Set == nil {
+			continue
+		}
+		if err := field.Set(context.Background(), rv, value); err != nil {
+			return fmt.Errorf("cdc: scan column %s: %w", column, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package cdc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/puper/leo/components/cdc/binlog"
+	"github.com/puper/leo/components/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultCheckpointTable = "cdc_checkpoints"
+
+// Checkpointer 持久化已处理完的 binlog 位置，重启后从该位置继续
+type Checkpointer interface {
+	// Load 返回保存的位置，没有检查点时返回 nil
+	Load(ctx context.Context) (*binlog.Position, error)
+	Save(ctx context.Context, pos binlog.Position) error
+}
+
+// FileCheckpointer 以 JSON 保存到文件，写入临时文件后 rename，不会留下写了一半的检查点
+type FileCheckpointer struct {
+	path string
+}
+
+func NewFileCheckpointer(path string) *FileCheckpointer {
+	return &FileCheckpointer{path: path}
+}
+
+func (me *FileCheckpointer) Load(ctx context.Context) (*binlog.Position, error) {
+	data, err := os.ReadFile(me.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	pos := &binlog.Position{}
+	if err := json.Unmarshal(data, pos); err != nil {
+		return nil, err
+	}
+	return pos, nil
+}
+
+func (me *FileCheckpointer) Save(ctx context.Context, pos binlog.Position) error {
+	data, err := json.Marshal(pos)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(me.path), filepath.Base(me.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), me.path)
+}
+
+// Checkpoint 是检查点表中的一行
+type Checkpoint struct {
+	Name      string `gorm:"primaryKey;size:191"`
+	File      string `gorm:"size:255;not null"`
+	Pos       uint32 `gorm:"not null"`
+	UpdatedAt time.Time
+}
+
+func (Checkpoint) TableName() string {
+	return defaultCheckpointTable
+}
+
+// CheckpointMigration 返回创建检查点表的迁移，通过 db.WithGoMigrations 与 SQL 迁移一起执行
+func CheckpointMigration(id, table string) *db.Migration {
+	if table == "" {
+		table = defaultCheckpointTable
+	}
+	return &db.Migration{
+		ID: id,
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table(table).AutoMigrate(&Checkpoint{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(table)
+		},
+	}
+}
+
+// DbCheckpointer 以 name 为主键保存到 server 上的 table 表
+type DbCheckpointer struct {
+	db     *db.Db
+	server string
+	table  string
+	name   string
+}
+
+// NewDbCheckpointer 在 server 不存在时返回 db.ErrServerNotFound
+func NewDbCheckpointer(d *db.Db, server, table, name string) (*DbCheckpointer, error) {
+	if _, err := d.Lookup(server); err != nil {
+		return nil, err
+	}
+	if table == "" {
+		table = defaultCheckpointTable
+	}
+	return &DbCheckpointer{
+		db:     d,
+		server: server,
+		table:  table,
+		name:   name,
+	}, nil
+}
+
+// write 返回 server 的写连接，server 在运行时被移除时返回错误
+func (me *DbCheckpointer) write(ctx context.Context) (*gorm.DB, error) {
+	w, err := me.db.Lookup(me.server)
+	if err != nil {
+		return nil, err
+	}
+	return w.Write().WithContext(ctx), nil
+}
+
+func (me *DbCheckpointer) Load(ctx context.Context) (*binlog.Position, error) {
+	conn, err := me.write(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var checkpoints []Checkpoint
+	err = conn.
+		Table(me.table).
+		Where("name = ?", me.name).
+		Limit(1).
+		Find(&checkpoints).
+		Error
+	if err != nil || len(checkpoints) == 0 {
+		return nil, err
+	}
+	return &binlog.Position{File: checkpoints[0].File, Pos: checkpoints[0].Pos}, nil
+}
+
+func (me *DbCheckpointer) Save(ctx context.Context, pos binlog.Position) error {
+	conn, err := me.write(ctx)
+	if err != nil {
+		return err
+	}
+	return conn.
+		Table(me.table).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"file", "pos", "updated_at"}),
+		}).
+		Create(&Checkpoint{Name: me.name, File: pos.File, Pos: pos.Pos}).
+		Error
+}
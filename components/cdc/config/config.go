@@ -0,0 +1,32 @@
+package config
+
+import "time"
+
+type Config struct {
+	// Name 区分同一 binlog 的多个消费者，用作数据库检查点的主键，默认 cdc
+	Name string `json:"name"`
+	// Server 为 db servers 配置项，使用其 Master 连接串读取 binlog；DSN 非空时优先使用 DSN
+	Server string `json:"server"`
+	DSN    string `json:"dsn"`
+	// ServerID 注册为从库时使用的 server_id，在复制拓扑中必须唯一，默认 1001
+	ServerID uint32 `json:"serverId"`
+	// Tables 需要捕获的表，格式为 <schema>.<table>，支持 path.Match 通配符；
+	// 省略 schema 时使用连接串中的库名，为空时捕获全部表
+	Tables []string `json:"tables"`
+	// Checkpoint 检查点的存储方式，取值 file、db；为空时配置了 db 组件则为 db，否则为 file
+	Checkpoint string `json:"checkpoint"`
+	// CheckpointPath file 方式的文件路径，默认 <Name>.checkpoint.json
+	CheckpointPath string `json:"checkpointPath"`
+	// CheckpointServer、CheckpointTable db 方式的 server 与表名，默认为 Server 与 cdc_checkpoints
+	CheckpointServer string `json:"checkpointServer"`
+	CheckpointTable  string `json:"checkpointTable"`
+	// CheckpointInterval 保存检查点的最小间隔，默认 1s
+	CheckpointInterval time.Duration `json:"checkpointInterval"`
+	// StartFile、StartPos 没有检查点时的起始位置，为空时从服务端当前位置开始
+	StartFile string `json:"startFile"`
+	StartPos  uint32 `json:"startPos"`
+	// HeartbeatPeriod 服务端空闲时发送心跳的间隔，超过 3 倍间隔未收到事件视为连接失效，默认 30s
+	HeartbeatPeriod time.Duration `json:"heartbeatPeriod"`
+	// RetryInterval 连接断开或处理失败后重连的间隔，默认 5s
+	RetryInterval time.Duration `json:"retryInterval"`
+}
@@ -0,0 +1,138 @@
+package cdc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/puper/leo/pkg/timewheel"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	HeaderChangeID = "cdc-change-id"
+	HeaderSchema   = "cdc-schema"
+	HeaderTable    = "cdc-table"
+)
+
+// Handler 处理一行变更，返回错误时 cdc 断开并在 RetryInterval 后从最近的检查点重新读取，
+// 因此同一变更可能被多次处理，处理方应按 Change.ID() 去重或保证幂等。
+type Handler interface {
+	Handle(ctx context.Context, c *Change) error
+}
+
+type HandlerFunc func(ctx context.Context, c *Change) error
+
+func (f HandlerFunc) Handle(ctx context.Context, c *Change) error {
+	return f(ctx, c)
+}
+
+// handlers 依次调用每个 Handler，任一失败即返回
+type handlers []Handler
+
+func (me handlers) Handle(ctx context.Context, c *Change) error {
+	for _, h := range me {
+		if err := h.Handle(ctx, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewTimeWheelHandler 将变更作为 Job.Data 延迟 delay 后投递给 tw 上订阅了 key 的回调，
+// 适用于延迟双删缓存等场景。时间轮不持久化，进程退出时未到期的任务会丢失。
+func NewTimeWheelHandler(tw *timewheel.TimeWheel, key string, delay time.Duration) Handler {
+	return HandlerFunc(func(ctx context.Context, c *Change) error {
+		tw.Add(&timewheel.Job{
+			Key:  key,
+			Id:   c.ID(),
+			Time: time.Now().Add(delay).Unix(),
+			Data: c,
+		})
+		return nil
+	})
+}
+
+// AMQPHandler 以 Change.Subject() 为 routing key 将 JSON 编码的变更发布到 exchange，并等待 broker 的 publisher confirm
+type AMQPHandler struct {
+	ch       *amqp.Channel
+	exchange string
+}
+
+func NewAMQPHandler(ch *amqp.Channel, exchange string) (*AMQPHandler, error) {
+	if err := ch.Confirm(false); err != nil {
+		return nil, fmt.Errorf("confirm: %w", err)
+	}
+	return &AMQPHandler{
+		ch:       ch,
+		exchange: exchange,
+	}, nil
+}
+
+func (me *AMQPHandler) Handle(ctx context.Context, c *Change) error {
+	body, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	confirm, err := me.ch.PublishWithDeferredConfirmWithContext(ctx, me.exchange, c.Subject(), false, false, amqp.Publishing{
+		Headers: amqp.Table{
+			HeaderSchema: c.Schema,
+			HeaderTable:  c.Table,
+		},
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    c.ID(),
+		Timestamp:    c.Timestamp,
+		Type:         string(c.Type),
+		Body:         body,
+	})
+	if err != nil {
+		return err
+	}
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return fmt.Errorf("change %s nacked by broker", c.ID())
+	}
+	return nil
+}
+
+// NATSHandler 将 JSON 编码的变更发布到 <SubjectPrefix><Change.Subject()>。
+// 传入 JetStream 时等待 PubAck 并以 Change.ID() 作为 Nats-Msg-Id 去重，否则仅 Flush 确认已写出。
+type NATSHandler struct {
+	conn          *nats.Conn
+	js            nats.JetStreamContext
+	subjectPrefix string
+}
+
+func NewNATSHandler(conn *nats.Conn, js nats.JetStreamContext, subjectPrefix string) *NATSHandler {
+	return &NATSHandler{
+		conn:          conn,
+		js:            js,
+		subjectPrefix: subjectPrefix,
+	}
+}
+
+func (me *NATSHandler) Handle(ctx context.Context, c *Change) error {
+	body, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	msg := nats.NewMsg(me.subjectPrefix + c.Subject())
+	msg.Data = body
+	msg.Header.Set(HeaderChangeID, c.ID())
+	msg.Header.Set(HeaderSchema, c.Schema)
+	msg.Header.Set(HeaderTable, c.Table)
+	if me.js != nil {
+		_, err := me.js.PublishMsg(msg, nats.Context(ctx), nats.MsgId(c.ID()))
+		return err
+	}
+	if err := me.conn.PublishMsg(msg); err != nil {
+		return err
+	}
+	return me.conn.FlushWithContext(ctx)
+}
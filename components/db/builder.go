@@ -1,11 +1,18 @@
 package db
 
 import (
-	"embed"
+	stderrors "errors"
+	"io/fs"
+	"log"
+	"reflect"
 
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/pkg/errors"
 	"github.com/puper/leo/components/db/config"
 	"github.com/puper/leo/engine"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
@@ -26,36 +33,129 @@ func Builder(cfg *config.Config, configurers ...func(*Db) error) engine.Builder
 
 func WithConnCallback(f func(db *gorm.DB)) func(*Db) error {
 	return func(me *Db) error {
-		for _, w := range me.wrappers {
-			f(w.master)
-			for _, s := range w.slave {
-				f(s)
-			}
+		return me.addConnHook(func(name string, conn *gorm.DB) error {
+			f(conn)
+			return nil
+		})
+	}
+}
+
+// WithLogger 将 SQL 日志写入 log（通常取自 zaplog 的具名日志），级别与慢查询阈值读取配置的 log 节
+func WithLogger(log *zap.SugaredLogger) func(*Db) error {
+	return func(me *Db) error {
+		return me.addConnHook(func(name string, conn *gorm.DB) error {
+			conn.Logger = newZapLogger(log.With("server", name), me.config.Log)
+			return nil
+		})
+	}
+}
+
+// WithMetrics 统计每个 server/table 的 SQL 次数与耗时，通过 Stats 或 MetricsHandler 获取
+func WithMetrics() func(*Db) error {
+	return func(me *Db) error {
+		me.metrics = newMetrics()
+		return me.addConnHook(me.metrics.register)
+	}
+}
+
+// WithConfigWatch 在 e 的配置文件变化时，将 <key>.servers 的增删改应用到运行中的 Db。
+// 通过 e.OnConfigChange 订阅，不会覆盖其他组件的回调；需要调用方执行 e.GetConfig().WatchConfig()。
+func WithConfigWatch(e *engine.Engine, key string) func(*Db) error {
+	return func(me *Db) error {
+		v := e.GetConfig()
+		if v == nil {
+			return errors.New("engine config is nil")
 		}
+		e.OnConfigChange(func(in fsnotify.Event) {
+			if err := me.reloadServers(v, key); err != nil {
+				log.Printf("db: reload servers from %s failed: %v", in.Name, err)
+			}
+		})
 		return nil
 	}
 }
 
-func WithMigrateFs(migrateFs embed.FS) func(*Db) error {
+// reloadServers 逐个应用变化，单个 server 失败不影响其他 server
+func (me *Db) reloadServers(v *viper.Viper, key string) error {
+	var servers map[string]config.ServerConfig
+	if err := v.UnmarshalKey(key+".servers", &servers, func(dc *mapstructure.DecoderConfig) {
+		dc.TagName = "json"
+	}); err != nil {
+		return errors.WithMessage(err, "UnmarshalKey")
+	}
+	me.mu.RLock()
+	current := me.config.Servers
+	me.mu.RUnlock()
+	var errs []error
+	for name, cfg := range servers {
+		old, ok := current[name]
+		switch {
+		case !ok:
+			errs = append(errs, errors.WithMessagef(me.AddServer(name, cfg), "add %s", name))
+		case !reflect.DeepEqual(old, cfg):
+			errs = append(errs, errors.WithMessagef(me.UpdateServer(name, cfg), "update %s", name))
+		}
+	}
+	for name := range current {
+		if _, ok := servers[name]; !ok {
+			errs = append(errs, errors.WithMessagef(me.RemoveServer(name), "remove %s", name))
+		}
+	}
+	return stderrors.Join(errs...)
+}
+
+// WithMigrateFs 执行 migrateFs 中的迁移，migrateFs 可以是 embed.FS 或 os.DirFS 等任意 fs.FS
+func WithMigrateFs(migrateFs fs.FS, opts ...LoadOption) func(*Db) error {
 	return func(me *Db) error {
-		migrates, err := LoadMigrates(migrateFs)
+		return me.eachMigrate(migrateFs, opts, (*Gormigrate).Migrate)
+	}
+}
+
+// WithSeedFs 执行 seedFs 中 <Root>/<db>/seeds 下的种子数据，每个种子文件只执行一次，
+// 执行记录保存在 <迁移表名>_seeds 表中。应放在 WithMigrateFs 之后。
+func WithSeedFs(seedFs fs.FS, opts ...LoadOption) func(*Db) error {
+	return func(me *Db) error {
+		seeds, err := LoadSeeds(seedFs, opts...)
 		if err != nil {
-			return errors.WithMessage(err, "LoadMigrates")
+			return errors.WithMessage(err, "LoadSeeds")
 		}
-		for name, w := range me.wrappers {
-			cfg, ok := me.config.Servers[name]
-			if !ok {
-				continue
+		return me.eachServer(func(name string, w *Wrapper, cfg config.ServerConfig) error {
+			if cfg.Migrate != nil && cfg.Migrate.Skip {
+				return nil
 			}
-			if ms, ok := migrates[name]; ok {
-				m := NewMigrate(w.Write(), cfg.Migrate, ms)
-				if err := m.Migrate(); err != nil {
-					if err != ErrNoMigrationDefined {
-						return errors.WithMessagef(err, "migrate %s", name)
-					}
+			if ss, ok := seeds[name]; ok {
+				m := NewMigrate(w.Write(), seedOptions(cfg.Migrate), seedMigrations(ss))
+				if err := m.Migrate(); err != nil && err != ErrNoMigrationDefined {
+					return errors.WithMessagef(err, "seed %s", name)
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// RepairMigrations 在有意修改已执行的迁移文件后，用当前文件内容重写迁移表中的校验和
+func (me *Db) RepairMigrations(migrateFs fs.FS, opts ...LoadOption) error {
+	return me.eachMigrate(migrateFs, opts, (*Gormigrate).RepairChecksums)
+}
+
+func (me *Db) eachMigrate(migrateFs fs.FS, opts []LoadOption, f func(*Gormigrate) error) error {
+	migrates, err := LoadMigrates(migrateFs, opts...)
+	if err != nil {
+		return errors.WithMessage(err, "LoadMigrates")
+	}
+	return me.eachServer(func(name string, w *Wrapper, cfg config.ServerConfig) error {
+		if cfg.Migrate != nil && cfg.Migrate.Skip {
+			return nil
+		}
+		if ms, ok := migrates[name]; ok {
+			m := NewMigrate(w.Write(), cfg.Migrate, ms)
+			if err := f(m); err != nil {
+				if err != ErrNoMigrationDefined {
+					return errors.WithMessagef(err, "migrate %s", name)
 				}
 			}
 		}
 		return nil
-	}
+	})
 }
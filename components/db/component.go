@@ -3,7 +3,10 @@ package db
 import (
 	"errors"
 	"fmt"
+	"log"
+	"maps"
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/puper/leo/components/db/config"
@@ -11,10 +14,23 @@ import (
 	"gorm.io/gorm"
 )
 
+const defaultDrainTimeout = 30 * time.Second
+
+var (
+	ErrServerNotFound = errors.New("db: server not found")
+	ErrServerExists   = errors.New("db: server already exists")
+)
+
 type (
 	Db struct {
-		config   *config.Config
-		wrappers map[string]*Wrapper
+		mu        sync.RWMutex
+		config    *config.Config
+		wrappers  map[string]*Wrapper
+		shardings map[string]*sharding
+		metrics   *Metrics
+		tenancy   *tenancy
+		// connHooks 由 WithLogger、WithMetrics 等注册，运行时新增的 server 同样会执行
+		connHooks []func(name string, conn *gorm.DB) error
 	}
 	Wrapper struct {
 		master *gorm.DB
@@ -27,40 +43,72 @@ type (
 
 func New(cfg *config.Config) (*Db, error) {
 	man := &Db{
-		config:   cfg,
-		wrappers: make(map[string]*Wrapper),
+		config:    cfg,
+		wrappers:  make(map[string]*Wrapper),
+		shardings: make(map[string]*sharding),
 	}
-	var err error
 	for name, config := range cfg.Servers {
-		w := new(Wrapper)
-		w.master, err = gorm.Open(mysql.Open(config.Master))
+		w, err := newWrapper(config)
+		if err != nil {
+			man.Close()
+			return nil, err
+		}
+		man.wrappers[name] = w
+	}
+	servers := make(map[string]bool, len(cfg.Servers))
+	for name := range cfg.Servers {
+		servers[name] = true
+	}
+	for name, shardCfg := range cfg.Shards {
+		s, err := newSharding(name, shardCfg, servers)
+		if err != nil {
+			man.Close()
+			return nil, err
+		}
+		man.shardings[name] = s
+	}
+	if cfg.Tenancy != nil {
+		t, err := newTenancy(man, cfg.Tenancy)
+		if err != nil {
+			man.Close()
+			return nil, err
+		}
+		man.tenancy = t
+	}
+	return man, nil
+}
+
+func newWrapper(config config.ServerConfig) (*Wrapper, error) {
+	w := new(Wrapper)
+	var err error
+	w.master, err = gorm.Open(mysql.Open(config.Master))
+	if err != nil {
+		return nil, fmt.Errorf("gorm.Open: %w", err)
+	}
+	stdDb, err := w.master.DB()
+	if err != nil {
+		return nil, fmt.Errorf("master.DB: %w", err)
+	}
+	stdDb.SetConnMaxLifetime(time.Duration(config.ConnMaxLifeTime) * time.Second)
+	stdDb.SetMaxIdleConns(config.MaxIdleConns)
+	stdDb.SetMaxOpenConns(config.MaxOpenConns)
+	for _, s := range config.Slave {
+		slave, err := gorm.Open(mysql.Open(s))
 		if err != nil {
-			return nil, fmt.Errorf("gorm.Open: %w", err)
+			w.Close()
+			return nil, err
 		}
-		stdDb, err := w.master.DB()
+		stdDb, err := slave.DB()
 		if err != nil {
-			return nil, fmt.Errorf("master.DB: %w", err)
+			w.Close()
+			return nil, fmt.Errorf("slave.DB: %w", err)
 		}
 		stdDb.SetConnMaxLifetime(time.Duration(config.ConnMaxLifeTime) * time.Second)
 		stdDb.SetMaxIdleConns(config.MaxIdleConns)
 		stdDb.SetMaxOpenConns(config.MaxOpenConns)
-		for _, s := range config.Slave {
-			slave, err := gorm.Open(mysql.Open(s))
-			if err != nil {
-				return nil, err
-			}
-			stdDb, err := slave.DB()
-			if err != nil {
-				return nil, fmt.Errorf("slave.DB: %w", err)
-			}
-			stdDb.SetConnMaxLifetime(time.Duration(config.ConnMaxLifeTime) * time.Second)
-			stdDb.SetMaxIdleConns(config.MaxIdleConns)
-			stdDb.SetMaxOpenConns(config.MaxOpenConns)
-			w.slave = append(w.slave, slave)
-		}
-		man.wrappers[name] = w
+		w.slave = append(w.slave, slave)
 	}
-	return man, nil
+	return w, nil
 }
 
 func (me *Wrapper) Write() *gorm.DB {
@@ -74,36 +122,241 @@ func (me *Wrapper) Read() *gorm.DB {
 	return me.slave[rand.Intn(len(me.slave))]
 }
 
+// each 对主库与从库执行 f
+func (me *Wrapper) each(f func(conn *gorm.DB) error) error {
+	if err := f(me.master); err != nil {
+		return err
+	}
+	for _, s := range me.slave {
+		if err := f(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (me *Wrapper) Close() error {
+	var errs []error
+	me.each(func(conn *gorm.DB) error {
+		if db, err := conn.DB(); err == nil {
+			errs = append(errs, db.Close())
+		}
+		return nil
+	})
+	return errors.Join(errs...)
+}
+
+// addConnHook 对现有的每个连接执行 f，并保存 f 供之后 AddServer、UpdateServer 创建的连接使用
+func (me *Db) addConnHook(f func(name string, conn *gorm.DB) error) error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.connHooks = append(me.connHooks, f)
+	for name, w := range me.wrappers {
+		if err := w.each(func(conn *gorm.DB) error {
+			return f(name, conn)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyConnHooks 对新建的连接执行已注册的连接回调
+func (me *Db) applyConnHooks(name string, w *Wrapper) error {
+	me.mu.RLock()
+	hooks := me.connHooks
+	me.mu.RUnlock()
+	for _, hook := range hooks {
+		if err := w.each(func(conn *gorm.DB) error {
+			return hook(name, conn)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ServerConfig 返回 server 当前的配置
+func (me *Db) ServerConfig(name string) (config.ServerConfig, bool) {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	cfg, ok := me.config.Servers[name]
+	return cfg, ok
+}
+
+// eachServer 对当前每个 server 执行 f，f 执行期间不持有锁
+func (me *Db) eachServer(f func(name string, w *Wrapper, cfg config.ServerConfig) error) error {
+	me.mu.RLock()
+	wrappers := maps.Clone(me.wrappers)
+	servers := me.config.Servers
+	me.mu.RUnlock()
+	for name, w := range wrappers {
+		cfg, ok := servers[name]
+		if !ok {
+			continue
+		}
+		if err := f(name, w, cfg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Lookup 返回 server name 的连接，name 未配置时返回 ErrServerNotFound
+func (me *Db) Lookup(name string) (*Wrapper, error) {
+	me.mu.RLock()
+	w, ok := me.wrappers[name]
+	me.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrServerNotFound, name)
+	}
+	return w, nil
+}
+
+func (me *Db) getWrapper(name string) *Wrapper {
+	w, err := me.Lookup(name)
+	if err != nil {
+		panic(fmt.Sprintf("db: server `%s` not found", name))
+	}
+	return w
+}
+
+// Write 在 name 未配置时 panic，name 来自外部输入时应使用 Lookup
 func (me *Db) Write(name string) *gorm.DB {
-	return me.wrappers[name].Write()
+	return me.getWrapper(name).Write()
 }
 
 func (me *Db) Read(name string) *gorm.DB {
-	return me.wrappers[name].Read()
+	return me.getWrapper(name).Read()
+}
+
+// AddServer 在运行时新增 server，已注册的连接回调会作用于新连接。新 server 的迁移需要调用方自行执行。
+func (me *Db) AddServer(name string, cfg config.ServerConfig) error {
+	return me.setServer(name, cfg, false)
+}
+
+// UpdateServer 用 cfg 重建 server 的连接池并替换，旧连接池在 DrainTimeout 后关闭，
+// 使已取得旧连接的请求能够执行完毕。
+func (me *Db) UpdateServer(name string, cfg config.ServerConfig) error {
+	return me.setServer(name, cfg, true)
+}
+
+func (me *Db) setServer(name string, cfg config.ServerConfig, replace bool) error {
+	me.mu.RLock()
+	_, exists := me.wrappers[name]
+	me.mu.RUnlock()
+	if exists && !replace {
+		return fmt.Errorf("%w: %s", ErrServerExists, name)
+	}
+	if !exists && replace {
+		return fmt.Errorf("%w: %s", ErrServerNotFound, name)
+	}
+	// 建立连接在锁外进行，避免阻塞其他 server 的读写
+	w, err := newWrapper(cfg)
+	if err != nil {
+		return err
+	}
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	for _, hook := range me.connHooks {
+		if err := w.each(func(conn *gorm.DB) error {
+			return hook(name, conn)
+		}); err != nil {
+			w.Close()
+			return err
+		}
+	}
+	old, exists := me.wrappers[name]
+	if exists != replace {
+		w.Close()
+		if replace {
+			return fmt.Errorf("%w: %s", ErrServerNotFound, name)
+		}
+		return fmt.Errorf("%w: %s", ErrServerExists, name)
+	}
+	me.wrappers[name] = w
+	servers := maps.Clone(me.config.Servers)
+	if servers == nil {
+		servers = map[string]config.ServerConfig{}
+	}
+	servers[name] = cfg
+	me.config.Servers = servers
+	if old != nil {
+		me.drain(name, old)
+		if me.tenancy != nil && me.tenancy.config.Mode != TenantModeDatabase && me.tenancy.config.Server == name {
+			me.tenancy.evictAll()
+		}
+	}
+	return nil
 }
 
+// RemoveServer 移除 server，旧连接池在 DrainTimeout 后关闭。仍被分片规则引用的 server 不能移除。
+func (me *Db) RemoveServer(name string) error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	w, ok := me.wrappers[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrServerNotFound, name)
+	}
+	for shardName, s := range me.shardings {
+		for _, server := range s.config.Servers {
+			if server == name {
+				return fmt.Errorf("db: server %s is used by shard %s", name, shardName)
+			}
+		}
+	}
+	if me.tenancy != nil && me.tenancy.config.Server == name {
+		return fmt.Errorf("db: server %s is used by tenancy", name)
+	}
+	delete(me.wrappers, name)
+	servers := maps.Clone(me.config.Servers)
+	delete(servers, name)
+	me.config.Servers = servers
+	me.drain(name, w)
+	return nil
+}
+
+func (me *Db) drain(name string, w *Wrapper) {
+	timeout := me.config.DrainTimeout
+	if timeout <= 0 {
+		timeout = defaultDrainTimeout
+	}
+	time.AfterFunc(timeout, func() {
+		if err := w.Close(); err != nil {
+			log.Printf("db: close drained server %s failed: %v", name, err)
+		}
+	})
+}
+
+// WriteModel 对配置了分片的 ShardedModel 按分片键路由
 func (me *Db) WriteModel(m Model) *gorm.DB {
+	if sm, ok := m.(ShardedModel); ok && me.isSharded(sm) {
+		return me.ShardWrite(sm)
+	}
 	return me.Write(m.ConnectionName()).Model(m)
 }
 
 func (me *Db) ReadModel(m Model) *gorm.DB {
+	if sm, ok := m.(ShardedModel); ok && me.isSharded(sm) {
+		return me.ShardRead(sm)
+	}
 	return me.Read(m.ConnectionName()).Model(m)
 }
 
+func (me *Db) isSharded(m ShardedModel) bool {
+	_, ok := me.shardings[m.ConnectionName()]
+	return ok
+}
+
 func (me *Db) Close() error {
 	var errs []error
-	for _, w := range me.wrappers {
-		if db, err := w.master.DB(); err == nil {
-			errs = append(errs, db.Close())
-		}
-		for _, s := range w.slave {
-			if db, err := s.DB(); err == nil {
-				errs = append(errs, db.Close())
-			}
-		}
+	if me.tenancy != nil {
+		errs = append(errs, me.tenancy.close())
 	}
-	if len(errs) > 0 {
-		return errors.Join(errs...)
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	for _, w := range me.wrappers {
+		errs = append(errs, w.Close())
 	}
-	return nil
+	return errors.Join(errs...)
 }
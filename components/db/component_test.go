@@ -1,10 +1,14 @@
 package db
 
 import (
+	"errors"
 	"io"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/puper/leo/components/db/config"
+	"github.com/spf13/viper"
 	"gorm.io/gorm"
 )
 
@@ -39,3 +43,54 @@ func TestDatabaseConcurrentReadRace(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestLookupUnknownServer(t *testing.T) {
+	db := &Db{config: &config.Config{}, wrappers: map[string]*Wrapper{}}
+	if _, err := db.Lookup("missing"); !errors.Is(err, ErrServerNotFound) {
+		t.Fatalf("Lookup err = %v", err)
+	}
+	defer func() {
+		if r := recover(); r != "db: server `missing` not found" {
+			t.Fatalf("recover = %v", r)
+		}
+	}()
+	db.Write("missing")
+}
+
+func TestRemoveServer(t *testing.T) {
+	cfg := &config.Config{
+		Servers: map[string]config.ServerConfig{
+			"s0":   {},
+			"s1":   {},
+			"main": {},
+		},
+		Shards: map[string]*config.ShardConfig{
+			"orders": {Strategy: ShardStrategyHash, Servers: []string{"s0", "s1"}},
+		},
+		DrainTimeout: time.Millisecond,
+	}
+	db := newDryRunDb(t, cfg, "s0", "s1", "main")
+	if err := db.AddServer("main", config.ServerConfig{}); !errors.Is(err, ErrServerExists) {
+		t.Fatalf("AddServer err = %v", err)
+	}
+	if err := db.UpdateServer("missing", config.ServerConfig{}); !errors.Is(err, ErrServerNotFound) {
+		t.Fatalf("UpdateServer err = %v", err)
+	}
+	if err := db.RemoveServer("s0"); err == nil {
+		t.Fatalf("RemoveServer of a sharded server should fail")
+	}
+	v := viper.New()
+	v.Set("db.servers", map[string]any{"s0": map[string]any{}, "s1": map[string]any{}})
+	if err := db.reloadServers(v, "db"); err != nil {
+		t.Fatalf("reloadServers: %v", err)
+	}
+	if _, err := db.Lookup("main"); !errors.Is(err, ErrServerNotFound) {
+		t.Fatalf("main should be removed, err = %v", err)
+	}
+	if _, ok := cfg.Servers["main"]; ok {
+		t.Fatalf("main should be removed from config")
+	}
+	if err := db.RemoveServer("main"); !errors.Is(err, ErrServerNotFound) {
+		t.Fatalf("RemoveServer err = %v", err)
+	}
+}
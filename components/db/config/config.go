@@ -1,15 +1,82 @@
 package config
 
+import "time"
+
 type Config struct {
-	Servers map[string]struct {
-		Driver          string         `json:"driver"`
-		Master          string         `json:"master"`
-		Slave           []string       `json:"slave"`
-		ConnMaxLifeTime int            `json:"connMaxLifeTime"`
-		MaxIdleConns    int            `json:"maxIdleConns"`
-		MaxOpenConns    int            `json:"maxOpenConns"`
-		Migrate         *MigrateConfig `json:"migrate"`
-	} `json:"servers"`
+	Servers map[string]ServerConfig `json:"servers"`
+	// Shards 分片规则，分片模型的 ConnectionName() 返回此处的 key
+	Shards  map[string]*ShardConfig `json:"shards"`
+	Log     *LogConfig              `json:"log"`
+	Tenancy *TenancyConfig          `json:"tenancy"`
+	// DrainTimeout 运行时移除或替换 server 后，旧连接池延迟关闭的时长，默认 30s
+	DrainTimeout time.Duration `json:"drainTimeout"`
+}
+
+type ServerConfig struct {
+	Driver          string         `json:"driver"`
+	Master          string         `json:"master"`
+	Slave           []string       `json:"slave"`
+	ConnMaxLifeTime int            `json:"connMaxLifeTime"`
+	MaxIdleConns    int            `json:"maxIdleConns"`
+	MaxOpenConns    int            `json:"maxOpenConns"`
+	Migrate         *MigrateConfig `json:"migrate"`
+}
+
+// TenancyConfig 多租户路由，租户 ID 通过 db.WithTenant 放入 context
+type TenancyConfig struct {
+	// Mode 取值 database(每个租户独立的库与连接池)、schema(共享连接池，表名限定为 <schema>.<table>)、
+	// prefix(共享连接池，表名加前缀)
+	Mode string `json:"mode"`
+	// Server schema、prefix 模式下共享的 server；database 模式下租户连接池沿用它的连接池参数与迁移配置。
+	// 控制表也位于此 server。
+	Server  string                  `json:"server"`
+	Tenants map[string]TenantConfig `json:"tenants"`
+	// RegistryTable 租户控制表，Tenants 中没有的租户从此表加载，表结构见 db.TenantRecord
+	RegistryTable string `json:"registryTable"`
+	// IdleTimeout 空闲超过该时长的租户连接会被关闭，下次使用时重新打开，默认 10m
+	IdleTimeout time.Duration `json:"idleTimeout"`
+	// MigrateName 租户迁移文件所在的目录名 <Root>/<MigrateName>，默认 tenant
+	MigrateName string `json:"migrateName"`
+}
+
+type TenantConfig struct {
+	// Master、Slave 为 database 模式下租户库的连接串
+	Master string   `json:"master"`
+	Slave  []string `json:"slave"`
+	// Schema 为 schema 模式下的库名，默认为租户 ID
+	Schema string `json:"schema"`
+	// Prefix 为 prefix 模式下的表名前缀，默认为 <租户 ID>_
+	Prefix string `json:"prefix"`
+}
+
+type LogConfig struct {
+	// Level 取值 silent、error、warn(默认)、info，info 会记录全部 SQL
+	Level string `json:"level"`
+	// SlowThreshold 超过该耗时的 SQL 以 warn 级别记录，默认 200ms，负数关闭
+	SlowThreshold time.Duration `json:"slowThreshold"`
+	// Redact 为 true 时日志中的 SQL 参数以 ? 占位输出
+	Redact                    bool `json:"redact"`
+	IgnoreRecordNotFoundError bool `json:"ignoreRecordNotFoundError"`
+}
+
+// ShardConfig 将分片键映射到 [0, len(Servers)*TableCount) 的分片序号，
+// 序号 i 落在 Servers[i/TableCount] 上的 <table>_<i%TableCount> 表。
+type ShardConfig struct {
+	// Strategy 取值 hash、range、lookup
+	Strategy string   `json:"strategy"`
+	Servers  []string `json:"servers"`
+	// TableCount 每个 server 内的分表数，小于等于 1 时不加表后缀
+	TableCount int          `json:"tableCount"`
+	Ranges     []ShardRange `json:"ranges"`
+	// Lookup 分片键到分片序号的映射
+	Lookup map[string]int `json:"lookup"`
+}
+
+// ShardRange 区间为 [Min, Max)
+type ShardRange struct {
+	Min   int64 `json:"min"`
+	Max   int64 `json:"max"`
+	Shard int   `json:"shard"`
 }
 
 type MigrateConfig struct {
@@ -18,4 +85,18 @@ type MigrateConfig struct {
 	IDColumnSize              int    `json:"idColumnSize"`
 	UseTransaction            bool   `json:"useTransaction"`
 	ValidateUnknownMigrations bool   `json:"validateUnknownMigrations"`
+
+	// Skip 为 true 时该副本不执行迁移，由配置为迁移节点的副本负责
+	Skip bool `json:"skip"`
+	// DisableLock 关闭迁移前的咨询锁 (advisory lock)，MaxOpenConns 为 1 的 MySQL/Postgres 需要关闭
+	DisableLock bool `json:"disableLock"`
+	// LockName 默认使用 TableName
+	LockName string `json:"lockName"`
+	// LockTimeout 等待迁移锁的时长，默认 1m
+	LockTimeout time.Duration `json:"lockTimeout"`
+	// LockStaleAfter 锁表实现中持有者每 LockStaleAfter/3 刷新一次 locked_at，
+	// 超过该时长未刷新的锁视为进程崩溃残留并被接管，默认 10m
+	LockStaleAfter time.Duration `json:"lockStaleAfter"`
+	// ChecksumPolicy 已执行迁移内容被修改时的处理方式: error(默认)、warn、ignore
+	ChecksumPolicy string `json:"checksumPolicy"`
 }
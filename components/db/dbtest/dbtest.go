@@ -0,0 +1,116 @@
+// Package dbtest 提供集成测试使用的夹具加载与事务隔离工具
+package dbtest
+
+import (
+	"fmt"
+	"io/fs"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/puper/leo/components/db"
+	"gorm.io/gorm"
+)
+
+// Fixtures 从目录加载与种子相同格式的文件，每次 Load 都会清空涉及的表后重新写入
+type Fixtures struct {
+	conn  *gorm.DB
+	seeds []*db.Seed
+}
+
+func NewFixtures(conn *gorm.DB, fixtureFs fs.FS, dir string) (*Fixtures, error) {
+	seeds, err := db.LoadSeedDir(fixtureFs, dir)
+	if err != nil {
+		return nil, errors.WithMessage(err, "LoadSeedDir")
+	}
+	return &Fixtures{conn: conn, seeds: seeds}, nil
+}
+
+// Tables 返回数据文件涉及的表，按加载顺序排列
+func (me *Fixtures) Tables() []string {
+	var tables []string
+	seen := map[string]bool{}
+	for _, seed := range me.seeds {
+		if seed.Table != "" && !seen[seed.Table] {
+			seen[seed.Table] = true
+			tables = append(tables, seed.Table)
+		}
+	}
+	return tables
+}
+
+// Load 清空数据文件涉及的表并按文件顺序写入夹具，.sql 文件原样执行
+func (me *Fixtures) Load() error {
+	return me.conn.Connection(func(conn *gorm.DB) error {
+		if err := truncate(conn, me.Tables()); err != nil {
+			return err
+		}
+		for _, seed := range me.seeds {
+			if err := seed.Apply(conn); err != nil {
+				return errors.WithMessagef(err, "load %s", seed.Name)
+			}
+		}
+		return nil
+	})
+}
+
+// MustLoad 在 Load 失败时终止测试
+func (me *Fixtures) MustLoad(t testing.TB) {
+	t.Helper()
+	if err := me.Load(); err != nil {
+		t.Fatalf("dbtest: load fixtures: %v", err)
+	}
+}
+
+// truncate 在同一连接上清空表；mysql 需要临时关闭外键检查，postgres 同时重置自增序列
+func truncate(conn *gorm.DB, tables []string) error {
+	if len(tables) == 0 {
+		return nil
+	}
+	quote := func(table string) string {
+		return conn.Statement.Quote(table)
+	}
+	switch conn.Dialector.Name() {
+	case "mysql":
+		if err := conn.Exec("SET FOREIGN_KEY_CHECKS = 0").Error; err != nil {
+			return err
+		}
+		defer conn.Exec("SET FOREIGN_KEY_CHECKS = 1")
+		for _, table := range tables {
+			if err := conn.Exec(fmt.Sprintf("TRUNCATE TABLE %s", quote(table))).Error; err != nil {
+				return errors.WithMessagef(err, "truncate %s", table)
+			}
+		}
+	case "postgres":
+		quoted := ""
+		for i, table := range tables {
+			if i > 0 {
+				quoted += ", "
+			}
+			quoted += quote(table)
+		}
+		if err := conn.Exec(fmt.Sprintf("TRUNCATE TABLE %s RESTART IDENTITY CASCADE", quoted)).Error; err != nil {
+			return errors.WithMessage(err, "truncate")
+		}
+	default:
+		// 按加载的逆序删除，使引用方先于被引用方清空
+		for i := len(tables) - 1; i >= 0; i-- {
+			if err := conn.Exec(fmt.Sprintf("DELETE FROM %s", quote(tables[i]))).Error; err != nil {
+				return errors.WithMessagef(err, "delete %s", tables[i])
+			}
+		}
+	}
+	return nil
+}
+
+// Tx 开启一个事务并在测试结束时回滚，测试中的所有写入都不会残留在数据库中
+func Tx(t testing.TB, conn *gorm.DB) *gorm.DB {
+	t.Helper()
+	tx := conn.Begin()
+	if tx.Error != nil {
+		t.Fatalf("dbtest: begin: %v", tx.Error)
+	}
+	t.Cleanup(func() {
+		tx.Rollback()
+	})
+	return tx
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/puper/leo/components/db/config"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const defaultSlowThreshold = 200 * time.Millisecond
+
+// zapLogger 将 gorm 的 SQL 日志写入 zap，每个 server 一个实例以便区分来源
+type zapLogger struct {
+	log                       *zap.SugaredLogger
+	level                     logger.LogLevel
+	slowThreshold             time.Duration
+	redact                    bool
+	ignoreRecordNotFoundError bool
+}
+
+var (
+	_         logger.Interface  = (*zapLogger)(nil)
+	_         gorm.ParamsFilter = (*zapLogger)(nil)
+	logLevels                   = map[string]logger.LogLevel{
+		"silent": logger.Silent,
+		"error":  logger.Error,
+		"warn":   logger.Warn,
+		"info":   logger.Info,
+	}
+)
+
+func newZapLogger(log *zap.SugaredLogger, cfg *config.LogConfig) *zapLogger {
+	if cfg == nil {
+		cfg = &config.LogConfig{}
+	}
+	level, ok := logLevels[cfg.Level]
+	if !ok {
+		level = logger.Warn
+	}
+	slowThreshold := cfg.SlowThreshold
+	if slowThreshold == 0 {
+		slowThreshold = defaultSlowThreshold
+	}
+	return &zapLogger{
+		log:                       log,
+		level:                     level,
+		slowThreshold:             slowThreshold,
+		redact:                    cfg.Redact,
+		ignoreRecordNotFoundError: cfg.IgnoreRecordNotFoundError,
+	}
+}
+
+func (me *zapLogger) LogMode(level logger.LogLevel) logger.Interface {
+	reply := *me
+	reply.level = level
+	return &reply
+}
+
+func (me *zapLogger) Info(ctx context.Context, msg string, data ...any) {
+	if me.level >= logger.Info {
+		me.log.Infof(msg, data...)
+	}
+}
+
+func (me *zapLogger) Warn(ctx context.Context, msg string, data ...any) {
+	if me.level >= logger.Warn {
+		me.log.Warnf(msg, data...)
+	}
+}
+
+func (me *zapLogger) Error(ctx context.Context, msg string, data ...any) {
+	if me.level >= logger.Error {
+		me.log.Errorf(msg, data...)
+	}
+}
+
+func (me *zapLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if me.level <= logger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && me.level >= logger.Error && !(me.ignoreRecordNotFoundError && errors.Is(err, gorm.ErrRecordNotFound)):
+		sql, rows := fc()
+		me.log.Errorw("sql error", "elapsed", elapsed, "rows", rows, "sql", sql, "error", err)
+	case me.slowThreshold > 0 && elapsed > me.slowThreshold && me.level >= logger.Warn:
+		sql, rows := fc()
+		me.log.Warnw("slow sql", "elapsed", elapsed, "threshold", me.slowThreshold, "rows", rows, "sql", sql)
+	case me.level >= logger.Info:
+		sql, rows := fc()
+		me.log.Infow("sql", "elapsed", elapsed, "rows", rows, "sql", sql)
+	}
+}
+
+// ParamsFilter 在 Redact 开启时丢弃参数，gorm 会以 ? 占位输出 SQL
+func (me *zapLogger) ParamsFilter(ctx context.Context, sql string, params ...any) (string, []any) {
+	if me.redact {
+		return sql, nil
+	}
+	return sql, params
+}
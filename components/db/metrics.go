@@ -0,0 +1,269 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const metricsStartKey = "leo:metrics_start"
+
+// LatencyBuckets 是 SQL 耗时直方图的桶上界
+var LatencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+type (
+	// Metrics 以 gorm callback 的方式按 server/table/operation 统计 SQL 次数、错误与耗时
+	Metrics struct {
+		mu      sync.Mutex
+		queries map[queryKey]*QueryStats
+	}
+	queryKey struct {
+		server    string
+		table     string
+		operation string
+	}
+	QueryStats struct {
+		Server    string
+		Table     string
+		Operation string
+		Count     int64
+		Errors    int64
+		Duration  time.Duration
+		// Buckets[i] 为耗时不超过 LatencyBuckets[i] 的累计次数
+		Buckets []int64
+	}
+	PoolStats struct {
+		Server string
+		// Role 取值 master、slave
+		Role  string
+		Index int
+		sql.DBStats
+	}
+	Stats struct {
+		Pools   []PoolStats
+		Queries []QueryStats
+	}
+)
+
+func newMetrics() *Metrics {
+	return &Metrics{
+		queries: map[queryKey]*QueryStats{},
+	}
+}
+
+func (me *Metrics) register(server string, conn *gorm.DB) error {
+	before := func(tx *gorm.DB) {
+		tx.InstanceSet(metricsStartKey, time.Now())
+	}
+	cb := conn.Callback()
+	processors := []struct {
+		operation string
+		before    func(string, func(*gorm.DB)) error
+		after     func(string, func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("*").Register, cb.Create().After("*").Register},
+		{"query", cb.Query().Before("*").Register, cb.Query().After("*").Register},
+		{"update", cb.Update().Before("*").Register, cb.Update().After("*").Register},
+		{"delete", cb.Delete().Before("*").Register, cb.Delete().After("*").Register},
+		{"row", cb.Row().Before("*").Register, cb.Row().After("*").Register},
+		{"raw", cb.Raw().Before("*").Register, cb.Raw().After("*").Register},
+	}
+	for _, p := range processors {
+		operation := p.operation
+		if err := p.before("leo:metrics_before_"+operation, before); err != nil {
+			return err
+		}
+		after := func(tx *gorm.DB) {
+			v, ok := tx.InstanceGet(metricsStartKey)
+			if !ok {
+				return
+			}
+			start, _ := v.(time.Time)
+			failed := tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound)
+			me.observe(queryKey{server: server, table: tx.Statement.Table, operation: operation}, time.Since(start), failed)
+		}
+		if err := p.after("leo:metrics_after_"+operation, after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (me *Metrics) observe(key queryKey, elapsed time.Duration, failed bool) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	s, ok := me.queries[key]
+	if !ok {
+		s = &QueryStats{
+			Server:    key.server,
+			Table:     key.table,
+			Operation: key.operation,
+			Buckets:   make([]int64, len(LatencyBuckets)),
+		}
+		me.queries[key] = s
+	}
+	s.Count++
+	if failed {
+		s.Errors++
+	}
+	s.Duration += elapsed
+	for i, upper := range LatencyBuckets {
+		if elapsed <= upper {
+			s.Buckets[i]++
+		}
+	}
+}
+
+func (me *Metrics) snapshot() []QueryStats {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	reply := make([]QueryStats, 0, len(me.queries))
+	for _, s := range me.queries {
+		c := *s
+		c.Buckets = append([]int64(nil), s.Buckets...)
+		reply = append(reply, c)
+	}
+	sort.Slice(reply, func(i, j int) bool {
+		a, b := reply[i], reply[j]
+		if a.Server != b.Server {
+			return a.Server < b.Server
+		}
+		if a.Table != b.Table {
+			return a.Table < b.Table
+		}
+		return a.Operation < b.Operation
+	})
+	return reply
+}
+
+// Stats 返回各连接池的 sql.DBStats，启用 WithMetrics 时同时返回 SQL 统计
+func (me *Db) Stats() *Stats {
+	reply := &Stats{}
+	me.mu.RLock()
+	wrappers := maps.Clone(me.wrappers)
+	me.mu.RUnlock()
+	for _, name := range slices.Sorted(maps.Keys(wrappers)) {
+		w := wrappers[name]
+		if stdDb, err := w.master.DB(); err == nil {
+			reply.Pools = append(reply.Pools, PoolStats{Server: name, Role: "master", DBStats: stdDb.Stats()})
+		}
+		for i, s := range w.slave {
+			if stdDb, err := s.DB(); err == nil {
+				reply.Pools = append(reply.Pools, PoolStats{Server: name, Role: "slave", Index: i, DBStats: stdDb.Stats()})
+			}
+		}
+	}
+	if me.metrics != nil {
+		reply.Queries = me.metrics.snapshot()
+	}
+	return reply
+}
+
+// MetricsHandler 以 Prometheus 文本格式输出 Stats，可直接挂到 /metrics 供采集
+func (me *Db) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		me.Stats().WriteTo(w)
+	})
+}
+
+func (me *Stats) WriteTo(w io.Writer) (int64, error) {
+	pw := &promWriter{w: w}
+	pools := []struct {
+		name  string
+		typ   string
+		value func(PoolStats) float64
+	}{
+		{"db_pool_max_open_connections", "gauge", func(s PoolStats) float64 { return float64(s.MaxOpenConnections) }},
+		{"db_pool_open_connections", "gauge", func(s PoolStats) float64 { return float64(s.OpenConnections) }},
+		{"db_pool_in_use_connections", "gauge", func(s PoolStats) float64 { return float64(s.InUse) }},
+		{"db_pool_idle_connections", "gauge", func(s PoolStats) float64 { return float64(s.Idle) }},
+		{"db_pool_wait_count_total", "counter", func(s PoolStats) float64 { return float64(s.WaitCount) }},
+		{"db_pool_wait_duration_seconds_total", "counter", func(s PoolStats) float64 { return s.WaitDuration.Seconds() }},
+		{"db_pool_max_idle_closed_total", "counter", func(s PoolStats) float64 { return float64(s.MaxIdleClosed) }},
+		{"db_pool_max_lifetime_closed_total", "counter", func(s PoolStats) float64 { return float64(s.MaxLifetimeClosed) }},
+	}
+	for _, p := range pools {
+		pw.header(p.name, p.typ)
+		for _, s := range me.Pools {
+			pw.sample(p.name, p.value(s), "server", s.Server, "role", s.Role, "index", strconv.Itoa(s.Index))
+		}
+	}
+	if len(me.Queries) > 0 {
+		pw.header("db_queries_total", "counter")
+		for _, s := range me.Queries {
+			pw.sample("db_queries_total", float64(s.Count), s.labels()...)
+		}
+		pw.header("db_query_errors_total", "counter")
+		for _, s := range me.Queries {
+			pw.sample("db_query_errors_total", float64(s.Errors), s.labels()...)
+		}
+		pw.header("db_query_duration_seconds", "histogram")
+		for _, s := range me.Queries {
+			for i, upper := range LatencyBuckets {
+				pw.sample("db_query_duration_seconds_bucket", float64(s.Buckets[i]), append(s.labels(), "le", strconv.FormatFloat(upper.Seconds(), 'g', -1, 64))...)
+			}
+			pw.sample("db_query_duration_seconds_bucket", float64(s.Count), append(s.labels(), "le", "+Inf")...)
+			pw.sample("db_query_duration_seconds_sum", s.Duration.Seconds(), s.labels()...)
+			pw.sample("db_query_duration_seconds_count", float64(s.Count), s.labels()...)
+		}
+	}
+	return pw.n, pw.err
+}
+
+func (me *QueryStats) labels() []string {
+	return []string{"server", me.Server, "table", me.Table, "operation", me.Operation}
+}
+
+type promWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (me *promWriter) printf(format string, args ...any) {
+	if me.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(me.w, format, args...)
+	me.n += int64(n)
+	me.err = err
+}
+
+func (me *promWriter) header(name, typ string) {
+	me.printf("# TYPE %s %s\n", name, typ)
+}
+
+func (me *promWriter) sample(name string, value float64, labels ...string) {
+	me.printf("%s{", name)
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			me.printf(",")
+		}
+		me.printf("%s=%q", labels[i], labels[i+1])
+	}
+	me.printf("} %s\n", strconv.FormatFloat(value, 'g', -1, 64))
+}
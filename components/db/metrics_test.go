@@ -0,0 +1,45 @@
+package db
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/puper/leo/components/db/config"
+)
+
+func TestMetricsRecordsQueries(t *testing.T) {
+	me := newDryRunDb(t, &config.Config{}, "main")
+	if err := WithMetrics()(me); err != nil {
+		t.Fatalf("WithMetrics: %v", err)
+	}
+	var orders []shardedOrder
+	if err := me.Read("main").Where("id = ?", 1).Find(&orders).Error; err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+
+	stats := me.Stats()
+	if len(stats.Queries) != 1 {
+		t.Fatalf("expected one query series, got %+v", stats.Queries)
+	}
+	q := stats.Queries[0]
+	if q.Server != "main" || q.Table != "sharded_orders" || q.Operation != "query" || q.Count != 1 {
+		t.Errorf("unexpected query stats %+v", q)
+	}
+	if len(stats.Pools) != 1 || stats.Pools[0].Role != "master" {
+		t.Errorf("unexpected pool stats %+v", stats.Pools)
+	}
+
+	var b strings.Builder
+	if _, err := stats.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`db_queries_total{server="main",table="sharded_orders",operation="query"} 1`,
+		`db_query_duration_seconds_bucket{server="main",table="sharded_orders",operation="query",le="+Inf"} 1`,
+		`db_pool_open_connections{server="main",role="master",index="0"} 0`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("metrics output missing %s\n%s", want, b.String())
+		}
+	}
+}
@@ -2,9 +2,18 @@
 package db
 
 import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"log"
+	"os"
 	"reflect"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/puper/leo/components/db/config"
 	"gorm.io/gorm"
@@ -12,6 +21,18 @@ import (
 
 const (
 	initSchemaMigrationID = "SCHEMA_INIT"
+
+	checksumColumnName  = "checksum"
+	appliedAtColumnName = "applied_at"
+	durationColumnName  = "duration_ms"
+	appliedByColumnName = "applied_by"
+)
+
+// Checksum policies applied when an applied migration's source changed.
+const (
+	ChecksumPolicyError  = "error"
+	ChecksumPolicyWarn   = "warn"
+	ChecksumPolicyIgnore = "ignore"
 )
 
 // MigrateFunc is the func signature for migrating.
@@ -34,6 +55,9 @@ type Migration struct {
 	Migrate MigrateFunc
 	// Rollback will be executed on rollback. Can be nil.
 	Rollback RollbackFunc
+	// Checksum is the sha256 of the migration source, recorded when the migration runs
+	// and compared on later runs to detect edits. Empty disables the check.
+	Checksum string
 }
 
 // Gormigrate represents a collection of all migrations of a database schema.
@@ -63,6 +87,15 @@ func (e *DuplicatedIDError) Error() string {
 	return fmt.Sprintf(`gormigrate: Duplicated migration ID: "%s"`, e.ID)
 }
 
+// ChecksumMismatchError is returned when applied migrations were edited afterwards
+type ChecksumMismatchError struct {
+	IDs []string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf(`gormigrate: Checksum mismatch for applied migrations: "%s"`, strings.Join(e.IDs, `", "`))
+}
+
 var (
 	// DefaultOptions can be used if you don't want to think about options.
 	DefaultOptions = &Options{
@@ -71,6 +104,7 @@ var (
 		IDColumnSize:              255,
 		UseTransaction:            false,
 		ValidateUnknownMigrations: false,
+		ChecksumPolicy:            ChecksumPolicyError,
 	}
 
 	// ErrRollbackImpossible is returned when trying to rollback a migration
@@ -97,6 +131,9 @@ var (
 
 // New returns a new Gormigrate.
 func NewMigrate(db *gorm.DB, options *Options, migrations []*Migration) *Gormigrate {
+	if options == nil {
+		options = &Options{}
+	}
 	if options.TableName == "" {
 		options.TableName = DefaultOptions.TableName
 	}
@@ -106,6 +143,15 @@ func NewMigrate(db *gorm.DB, options *Options, migrations []*Migration) *Gormigr
 	if options.IDColumnSize == 0 {
 		options.IDColumnSize = DefaultOptions.IDColumnSize
 	}
+	if options.LockTimeout == 0 {
+		options.LockTimeout = defaultMigrateLockTimeout
+	}
+	if options.LockStaleAfter == 0 {
+		options.LockStaleAfter = defaultMigrateLockStaleAfter
+	}
+	if options.ChecksumPolicy == "" {
+		options.ChecksumPolicy = DefaultOptions.ChecksumPolicy
+	}
 	return &Gormigrate{
 		db:         db,
 		options:    options,
@@ -154,6 +200,12 @@ func (g *Gormigrate) migrate(migrationID string) error {
 		return err
 	}
 
+	unlock, err := g.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	g.begin()
 	defer g.rollback()
 
@@ -161,6 +213,10 @@ func (g *Gormigrate) migrate(migrationID string) error {
 		return err
 	}
 
+	if err := g.verifyChecksums(); err != nil {
+		return err
+	}
+
 	if g.options.ValidateUnknownMigrations {
 		unknownMigrations, err := g.unknownMigrationsHaveHappened()
 		if err != nil {
@@ -238,6 +294,12 @@ func (g *Gormigrate) RollbackLast() error {
 		return ErrNoMigrationDefined
 	}
 
+	unlock, err := g.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	g.begin()
 	defer g.rollback()
 
@@ -263,6 +325,12 @@ func (g *Gormigrate) RollbackTo(migrationID string) error {
 		return err
 	}
 
+	unlock, err := g.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	g.begin()
 	defer g.rollback()
 
@@ -302,6 +370,12 @@ func (g *Gormigrate) getLastRunMigration() (*Migration, error) {
 
 // RollbackMigration undo a migration.
 func (g *Gormigrate) RollbackMigration(m *Migration) error {
+	unlock, err := g.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	g.begin()
 	defer g.rollback()
 
@@ -328,12 +402,12 @@ func (g *Gormigrate) runInitSchema() error {
 	if err := g.initSchema(g.tx); err != nil {
 		return err
 	}
-	if err := g.insertMigration(initSchemaMigrationID); err != nil {
+	if err := g.insertMigration(&Migration{ID: initSchemaMigrationID}, 0); err != nil {
 		return err
 	}
 
 	for _, migration := range g.migrations {
-		if err := g.insertMigration(migration.ID); err != nil {
+		if err := g.insertMigration(migration, 0); err != nil {
 			return err
 		}
 	}
@@ -351,11 +425,12 @@ func (g *Gormigrate) runMigration(migration *Migration) error {
 		return err
 	}
 	if !migrationRan {
+		start := time.Now()
 		if err := migration.Migrate(g.tx); err != nil {
 			return err
 		}
 
-		if err := g.insertMigration(migration.ID); err != nil {
+		if err := g.insertMigration(migration, time.Since(start)); err != nil {
 			return err
 		}
 	}
@@ -365,27 +440,52 @@ func (g *Gormigrate) runMigration(migration *Migration) error {
 // model returns pointer to dynamically created gorm migration model struct value
 //
 //	struct defined as {
-//	  ID string `gorm:"primaryKey;column:<Options.IDColumnName>;size:<Options.IDColumnSize>"`
+//	  ID         string    `gorm:"primaryKey;column:<Options.IDColumnName>;size:<Options.IDColumnSize>"`
+//	  Checksum   string    `gorm:"column:checksum;size:64"`
+//	  AppliedAt  time.Time `gorm:"column:applied_at"`
+//	  DurationMs int64     `gorm:"column:duration_ms"`
+//	  AppliedBy  string    `gorm:"column:applied_by;size:255"`
 //	}
 func (g *Gormigrate) model() any {
-	f := reflect.StructField{
-		Name: reflect.ValueOf("ID").Interface().(string),
-		Type: reflect.TypeOf(""),
-		Tag: reflect.StructTag(fmt.Sprintf(
-			`gorm:"primaryKey;column:%s;size:%d"`,
-			g.options.IDColumnName,
-			g.options.IDColumnSize,
-		)),
-	}
-	structType := reflect.StructOf([]reflect.StructField{f})
+	fields := []reflect.StructField{
+		{
+			Name: reflect.ValueOf("ID").Interface().(string),
+			Type: reflect.TypeOf(""),
+			Tag: reflect.StructTag(fmt.Sprintf(
+				`gorm:"primaryKey;column:%s;size:%d"`,
+				g.options.IDColumnName,
+				g.options.IDColumnSize,
+			)),
+		},
+		{
+			Name: "Checksum",
+			Type: reflect.TypeOf(""),
+			Tag:  reflect.StructTag(fmt.Sprintf(`gorm:"column:%s;size:64"`, checksumColumnName)),
+		},
+		{
+			Name: "AppliedAt",
+			Type: reflect.TypeOf((*time.Time)(nil)),
+			Tag:  reflect.StructTag(fmt.Sprintf(`gorm:"column:%s"`, appliedAtColumnName)),
+		},
+		{
+			Name: "DurationMs",
+			Type: reflect.TypeOf(int64(0)),
+			Tag:  reflect.StructTag(fmt.Sprintf(`gorm:"column:%s"`, durationColumnName)),
+		},
+		{
+			Name: "AppliedBy",
+			Type: reflect.TypeOf(""),
+			Tag:  reflect.StructTag(fmt.Sprintf(`gorm:"column:%s;size:255"`, appliedByColumnName)),
+		},
+	}
+	structType := reflect.StructOf(fields)
 	structValue := reflect.New(structType).Elem()
 	return structValue.Addr().Interface()
 }
 
+// createMigrationTableIfNotExists also runs against existing tables so that
+// tables created before the audit columns existed get them added.
 func (g *Gormigrate) createMigrationTableIfNotExists() error {
-	if g.tx.Migrator().HasTable(g.options.TableName) {
-		return nil
-	}
 	return g.tx.Table(g.options.TableName).AutoMigrate(g.model())
 }
 
@@ -445,11 +545,129 @@ func (g *Gormigrate) unknownMigrationsHaveHappened() (bool, error) {
 	return false, nil
 }
 
-func (g *Gormigrate) insertMigration(id string) error {
-	record := map[string]any{g.options.IDColumnName: id}
+func (g *Gormigrate) insertMigration(m *Migration, duration time.Duration) error {
+	record := map[string]any{
+		g.options.IDColumnName: m.ID,
+		checksumColumnName:     m.Checksum,
+		appliedAtColumnName:    time.Now(),
+		durationColumnName:     duration.Milliseconds(),
+		appliedByColumnName:    migrateHost(),
+	}
 	return g.tx.Table(g.options.TableName).Create(record).Error
 }
 
+// appliedChecksums returns the recorded checksum of every applied migration.
+func (g *Gormigrate) appliedChecksums() (map[string]string, error) {
+	rows, err := g.tx.Table(g.options.TableName).Select(g.options.IDColumnName, checksumColumnName).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checksums := map[string]string{}
+	for rows.Next() {
+		var id string
+		var checksum sql.NullString
+		if err := rows.Scan(&id, &checksum); err != nil {
+			return nil, err
+		}
+		checksums[id] = checksum.String
+	}
+	return checksums, rows.Err()
+}
+
+func (g *Gormigrate) updateChecksum(m *Migration) error {
+	cond := fmt.Sprintf("%s = ?", g.options.IDColumnName)
+	return g.tx.Table(g.options.TableName).Where(cond, m.ID).Update(checksumColumnName, m.Checksum).Error
+}
+
+// verifyChecksums compares applied migrations with their current source.
+// Rows recorded before checksums existed are backfilled instead of reported.
+func (g *Gormigrate) verifyChecksums() error {
+	if g.options.ChecksumPolicy == ChecksumPolicyIgnore {
+		return nil
+	}
+	applied, err := g.appliedChecksums()
+	if err != nil {
+		return err
+	}
+	var mismatched []string
+	for _, m := range g.migrations {
+		if m.Checksum == "" {
+			continue
+		}
+		checksum, ok := applied[m.ID]
+		if !ok {
+			continue
+		}
+		if checksum == "" {
+			if err := g.updateChecksum(m); err != nil {
+				return err
+			}
+			continue
+		}
+		if checksum != m.Checksum {
+			mismatched = append(mismatched, m.ID)
+		}
+	}
+	if len(mismatched) == 0 {
+		return nil
+	}
+	mismatchErr := &ChecksumMismatchError{IDs: mismatched}
+	if g.options.ChecksumPolicy == ChecksumPolicyWarn {
+		log.Printf("%v, run RepairChecksums after a deliberate edit", mismatchErr)
+		return nil
+	}
+	return mismatchErr
+}
+
+// RepairChecksums overwrites the recorded checksum of every applied migration
+// with the checksum of its current source. Use it after deliberately editing
+// an applied migration.
+func (g *Gormigrate) RepairChecksums() error {
+	unlock, err := g.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	g.begin()
+	defer g.rollback()
+
+	if err := g.createMigrationTableIfNotExists(); err != nil {
+		return err
+	}
+	applied, err := g.appliedChecksums()
+	if err != nil {
+		return err
+	}
+	for _, m := range g.migrations {
+		if checksum, ok := applied[m.ID]; ok && m.Checksum != "" && checksum != m.Checksum {
+			if err := g.updateChecksum(m); err != nil {
+				return err
+			}
+		}
+	}
+	return g.commit()
+}
+
+// lock acquires the cross-process migration lock unless DisableLock is set.
+// The returned func releases it and is always safe to call.
+func (g *Gormigrate) lock() (func(), error) {
+	if g.options.DisableLock {
+		return func() {}, nil
+	}
+	locker := newMigrateLocker(g.db, g.options)
+	ctx, cancel := context.WithTimeout(context.Background(), g.options.LockTimeout)
+	defer cancel()
+	if err := locker.Lock(ctx); err != nil {
+		return nil, err
+	}
+	return func() {
+		locker.Unlock()
+	}, nil
+}
+
 func (g *Gormigrate) begin() {
 	if g.options.UseTransaction {
 		g.tx = g.db.Begin()
@@ -470,3 +688,22 @@ func (g *Gormigrate) rollback() {
 		g.tx.Rollback()
 	}
 }
+
+// Checksum returns the checksum recorded for a migration source.
+func Checksum(source []byte) string {
+	sum := sha256.Sum256(source)
+	return hex.EncodeToString(sum[:])
+}
+
+var (
+	hostOnce sync.Once
+	host     string
+)
+
+func migrateHost() string {
+	hostOnce.Do(func() {
+		name, _ := os.Hostname()
+		host = fmt.Sprintf("%s:%d", name, os.Getpid())
+	})
+	return host
+}
@@ -0,0 +1,123 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/puper/leo/components/db/config"
+	"gorm.io/gorm"
+)
+
+func checksumMigrations(checksum string) []*Migration {
+	return []*Migration{{
+		ID:       "1_init",
+		Migrate:  func(*gorm.DB) error { return nil },
+		Checksum: checksum,
+	}}
+}
+
+func recordedChecksum(t *testing.T, conn *gorm.DB, id string) string {
+	t.Helper()
+	var checksum string
+	if err := conn.Table("migrations").Select(checksumColumnName).Where("id = ?", id).Scan(&checksum).Error; err != nil {
+		t.Fatal(err)
+	}
+	return checksum
+}
+
+func TestVerifyChecksumsPolicies(t *testing.T) {
+	cases := []struct {
+		policy  string
+		wantErr bool
+	}{
+		{policy: ChecksumPolicyError, wantErr: true},
+		{policy: ChecksumPolicyWarn},
+		{policy: ChecksumPolicyIgnore},
+	}
+	for _, c := range cases {
+		t.Run(c.policy, func(t *testing.T) {
+			conn := openSQLite(t)
+			if err := NewMigrate(conn, &Options{ChecksumPolicy: c.policy}, checksumMigrations("a")).Migrate(); err != nil {
+				t.Fatal(err)
+			}
+			err := NewMigrate(conn, &Options{ChecksumPolicy: c.policy}, checksumMigrations("b")).Migrate()
+			var mismatch *ChecksumMismatchError
+			if c.wantErr {
+				if !errors.As(err, &mismatch) || !reflect.DeepEqual(mismatch.IDs, []string{"1_init"}) {
+					t.Fatalf("expected mismatch for 1_init, got %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("policy %s should not fail, got %v", c.policy, err)
+			}
+			// 任何策略都不会改写已记录的校验和
+			if got := recordedChecksum(t, conn, "1_init"); got != "a" {
+				t.Errorf("recorded checksum changed to %q", got)
+			}
+		})
+	}
+}
+
+func TestVerifyChecksumsBackfillsLegacyRows(t *testing.T) {
+	conn := openSQLite(t)
+	// 引入校验和之前执行的迁移记录为空
+	if err := NewMigrate(conn, nil, checksumMigrations("")).Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	if got := recordedChecksum(t, conn, "1_init"); got != "" {
+		t.Fatalf("expected empty checksum, got %q", got)
+	}
+	if err := NewMigrate(conn, nil, checksumMigrations("a")).Migrate(); err != nil {
+		t.Fatalf("legacy row should be backfilled, got %v", err)
+	}
+	if got := recordedChecksum(t, conn, "1_init"); got != "a" {
+		t.Errorf("expected backfilled checksum a, got %q", got)
+	}
+	if err := NewMigrate(conn, nil, checksumMigrations("b")).Migrate(); err == nil {
+		t.Error("backfilled checksum should be verified on later runs")
+	}
+}
+
+func TestRepairChecksums(t *testing.T) {
+	conn := openSQLite(t)
+	if err := NewMigrate(conn, nil, checksumMigrations("a")).Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewMigrate(conn, nil, checksumMigrations("b")).RepairChecksums(); err != nil {
+		t.Fatal(err)
+	}
+	if got := recordedChecksum(t, conn, "1_init"); got != "b" {
+		t.Fatalf("expected repaired checksum b, got %q", got)
+	}
+	if err := NewMigrate(conn, nil, checksumMigrations("b")).Migrate(); err != nil {
+		t.Fatalf("migrate after repair: %v", err)
+	}
+}
+
+func TestDbRepairMigrations(t *testing.T) {
+	conn := openSQLite(t)
+	me := &Db{
+		config:   &config.Config{Servers: map[string]config.ServerConfig{"main": {}}},
+		wrappers: map[string]*Wrapper{"main": {master: conn}},
+	}
+	original := fstest.MapFS{"migrations/main/1_init.up.sql": {Data: []byte("CREATE TABLE users (id int)")}}
+	edited := fstest.MapFS{"migrations/main/1_init.up.sql": {Data: []byte("CREATE TABLE users (id bigint)")}}
+
+	if err := WithMigrateFs(original, WithRoot("migrations"))(me); err != nil {
+		t.Fatal(err)
+	}
+	var mismatch *ChecksumMismatchError
+	if err := WithMigrateFs(edited, WithRoot("migrations"))(me); !errors.As(err, &mismatch) {
+		t.Fatalf("expected ChecksumMismatchError, got %v", err)
+	}
+	if err := me.RepairMigrations(edited, WithRoot("migrations")); err != nil {
+		t.Fatal(err)
+	}
+	if err := WithMigrateFs(edited, WithRoot("migrations"))(me); err != nil {
+		t.Fatalf("migrate after repair: %v", err)
+	}
+	if got := recordedChecksum(t, conn, "1_init"); got != Checksum([]byte("CREATE TABLE users (id bigint)")) {
+		t.Errorf("unexpected checksum %q after repair", got)
+	}
+}
@@ -2,92 +2,315 @@ package db
 
 import (
 	stderrors "errors"
-	"embed"
 	"io/fs"
+	"log"
+	"os"
+	"path"
 	"sort"
 	"strings"
 
 	"github.com/pkg/errors"
-	"github.com/spf13/cast"
 	"gorm.io/gorm"
 )
 
-func LoadMigrates(migrateFs embed.FS) (map[string][]*Migration, error) {
-	migrates := map[string][]*Migration{}
-	entries, err := migrateFs.ReadDir("sqls")
-	if err != nil {
-		if stderrors.Is(err, fs.ErrNotExist) {
-			return migrates, nil
+const defaultMigrateRoot = "sqls"
+
+type LoadOptions struct {
+	// Root 迁移文件根目录，其下每个子目录对应一个 servers 配置项，默认 sqls
+	Root string
+	// GoMigrations 以代码形式注册的迁移，与 SQL 文件按 ID 统一排序
+	GoMigrations map[string][]*Migration
+}
+
+type LoadOption func(*LoadOptions)
+
+func WithRoot(root string) LoadOption {
+	return func(opts *LoadOptions) {
+		opts.Root = root
+	}
+}
+
+func WithGoMigrations(dbName string, migrations ...*Migration) LoadOption {
+	return func(opts *LoadOptions) {
+		if opts.GoMigrations == nil {
+			opts.GoMigrations = map[string][]*Migration{}
 		}
-		return nil, errors.WithMessage(err, "read dir sqls")
+		opts.GoMigrations[dbName] = append(opts.GoMigrations[dbName], migrations...)
+	}
+}
+
+// LoadMigratesFromDir 从本地目录加载迁移，适用于不使用 embed 的部署方式
+func LoadMigratesFromDir(dir string, opts ...LoadOption) (map[string][]*Migration, error) {
+	return LoadMigrates(os.DirFS(dir), opts...)
+}
+
+// LoadMigrates 读取 <Root>/<db>/<id>.up.sql 与 <id>.down.sql，并合并 GoMigrations。
+// 不符合命名规则的文件不会被执行，会输出警告便于发现拼写错误。
+func LoadMigrates(migrateFs fs.FS, opts ...LoadOption) (map[string][]*Migration, error) {
+	options := &LoadOptions{Root: defaultMigrateRoot}
+	for _, opt := range opts {
+		opt(options)
+	}
+	migrates := map[string]map[string]*Migration{}
+	entries, err := fs.ReadDir(migrateFs, options.Root)
+	if err != nil && !stderrors.Is(err, fs.ErrNotExist) {
+		return nil, errors.WithMessagef(err, "read dir %s", options.Root)
 	}
 	for _, f := range entries {
 		if strings.HasPrefix(f.Name(), ".") {
 			continue
 		}
-		if f.IsDir() {
-			sqlFs, err := migrateFs.ReadDir("sqls/" + f.Name())
-			if err != nil {
-				return nil, errors.WithMessagef(err, "read dir sqls/%s", f.Name())
+		if !f.IsDir() {
+			log.Printf("db migrate: ignore %s, migration files must be placed in a database directory", path.Join(options.Root, f.Name()))
+			continue
+		}
+		dbName := f.Name()
+		ms, err := loadSQLMigrates(migrateFs, path.Join(options.Root, dbName))
+		if err != nil {
+			return nil, err
+		}
+		if len(ms) > 0 {
+			migrates[dbName] = ms
+		}
+	}
+	for dbName, gms := range options.GoMigrations {
+		if _, ok := migrates[dbName]; !ok {
+			migrates[dbName] = map[string]*Migration{}
+		}
+		for _, m := range gms {
+			if _, ok := migrates[dbName][m.ID]; ok {
+				return nil, &DuplicatedIDError{ID: m.ID}
+			}
+			migrates[dbName][m.ID] = m
+		}
+	}
+	reply := make(map[string][]*Migration, len(migrates))
+	for dbName, ms := range migrates {
+		if len(ms) == 0 {
+			continue
+		}
+		reply[dbName] = make([]*Migration, 0, len(ms))
+		for _, m := range ms {
+			reply[dbName] = append(reply[dbName], m)
+		}
+		sort.SliceStable(reply[dbName], func(i, j int) bool {
+			return migrationIDLess(reply[dbName][i].ID, reply[dbName][j].ID)
+		})
+	}
+	return reply, nil
+}
+
+func loadSQLMigrates(migrateFs fs.FS, dir string) (map[string]*Migration, error) {
+	sqlFs, err := fs.ReadDir(migrateFs, dir)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "read dir %s", dir)
+	}
+	ms := map[string]*Migration{}
+	for _, sqlf := range sqlFs {
+		name := sqlf.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		if sqlf.IsDir() {
+			// seeds 目录由 LoadSeeds 读取
+			if name == seedDirName {
+				continue
+			}
+			log.Printf("db migrate: ignore directory %s", path.Join(dir, name))
+			continue
+		}
+		var id string
+		var isUp bool
+		if prefix, ok := strings.CutSuffix(name, ".up.sql"); ok {
+			id, isUp = prefix, true
+		} else if prefix, ok := strings.CutSuffix(name, ".down.sql"); ok {
+			id, isUp = prefix, false
+		}
+		if id == "" {
+			log.Printf("db migrate: ignore %s, expect <id>.up.sql or <id>.down.sql", path.Join(dir, name))
+			continue
+		}
+		sqlBytes, err := fs.ReadFile(migrateFs, path.Join(dir, name))
+		if err != nil {
+			return nil, errors.WithMessagef(err, "read file %s", path.Join(dir, name))
+		}
+		sql := string(sqlBytes)
+		if _, ok := ms[id]; !ok {
+			ms[id] = &Migration{
+				ID: id,
+			}
+		}
+		if isUp {
+			ms[id].Checksum = Checksum(sqlBytes)
+			ms[id].Migrate = func(tx *gorm.DB) error {
+				return execStatements(tx, sql)
 			}
-			dbName := f.Name()
-			ms := map[string]*Migration{}
-			for _, sqlf := range sqlFs {
-				if sqlf.IsDir() {
-					continue
-				}
-				if strings.HasPrefix(sqlf.Name(), ".") || !strings.HasSuffix(sqlf.Name(), ".sql") {
-					continue
-				}
-				tmp := strings.Split(sqlf.Name(), ".")
-				if len(tmp) != 3 {
-					continue
-				}
-				id := tmp[0]
-
-				isUp := true
-				if tmp[1] == "up" {
-					isUp = true
-				} else if tmp[1] == "down" {
-					isUp = false
-				} else {
-					continue
-				}
-				sqlBytes, err := migrateFs.ReadFile("sqls/" + dbName + "/" + sqlf.Name())
-				if err != nil {
-					return nil, errors.WithMessagef(err, "read file sqls/%s/%s", dbName, sqlf.Name())
-				}
-				sql := string(sqlBytes)
-				if _, ok := ms[id]; !ok {
-					ms[id] = &Migration{
-						ID: id,
-					}
-				}
-				if isUp {
-					ms[id].Migrate = func(tx *gorm.DB) error {
-						return tx.Session(&gorm.Session{}).Exec(sql).Error
-					}
-				} else {
-					ms[id].Rollback = func(tx *gorm.DB) error {
-						return tx.Session(&gorm.Session{}).Exec(sql).Error
-					}
-				}
+		} else {
+			ms[id].Rollback = func(tx *gorm.DB) error {
+				return execStatements(tx, sql)
 			}
-			if len(ms) > 0 {
-				migrates[dbName] = make([]*Migration, 0, len(ms))
-				for _, m := range ms {
-					migrates[dbName] = append(migrates[dbName], m)
-				}
-				sort.SliceStable(
-					migrates[dbName],
-					func(i, j int) bool {
-						idI := strings.Split(migrates[dbName][i].ID, "_")
-						idJ := strings.Split(migrates[dbName][j].ID, "_")
-						return cast.ToInt(idI[0]) < cast.ToInt(idJ[0])
-					},
-				)
+		}
+	}
+	for id, m := range ms {
+		if m.Migrate == nil {
+			return nil, errors.Errorf("migration %s/%s has no up file", dir, id)
+		}
+	}
+	return ms, nil
+}
+
+// migrationIDLess 比较迁移 ID 的数字前缀（第一个 `_` 之前），按数值而非字符串排序，
+// 且不受 int 范围限制。数字前缀的 ID 排在非数字前缀之前，前缀相同或都不是数字时按字符串比较，
+// 保证混用两种命名时仍是全序。
+func migrationIDLess(a, b string) bool {
+	prefixA, _, _ := strings.Cut(a, "_")
+	prefixB, _, _ := strings.Cut(b, "_")
+	numericA, numericB := isDigits(prefixA), isDigits(prefixB)
+	if numericA != numericB {
+		return numericA
+	}
+	if numericA {
+		prefixA = strings.TrimLeft(prefixA, "0")
+		prefixB = strings.TrimLeft(prefixB, "0")
+		if len(prefixA) != len(prefixB) {
+			return len(prefixA) < len(prefixB)
+		}
+		if prefixA != prefixB {
+			return prefixA < prefixB
+		}
+	}
+	return a < b
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// execStatements 逐条执行迁移语句，部分驱动不接受一次 Exec 多条语句
+func execStatements(tx *gorm.DB, sql string) error {
+	for _, stmt := range SplitStatements(sql) {
+		if err := tx.Session(&gorm.Session{}).Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SplitStatements 按分隔符拆分 SQL 脚本。
+// 支持 mysql 客户端的 DELIMITER 指令、`--`/`#`/`/* */` 注释、引号字符串以及 Postgres 的 $tag$ 字符串，
+// 这些位置出现的分隔符不会被当作语句结束。只包含注释的片段会被丢弃。
+func SplitStatements(sql string) []string {
+	var (
+		stmts     []string
+		buf       strings.Builder
+		hasCode   bool
+		delimiter = ";"
+		lineStart = true
+	)
+	flush := func() {
+		if stmt := strings.TrimSpace(buf.String()); stmt != "" && hasCode {
+			stmts = append(stmts, stmt)
+		}
+		buf.Reset()
+		hasCode = false
+	}
+	for i := 0; i < len(sql); {
+		if lineStart {
+			line := sql[i:]
+			if end := strings.IndexByte(line, '\n'); end >= 0 {
+				line = line[:end]
+			}
+			fields := strings.Fields(line)
+			if len(fields) == 2 && strings.EqualFold(fields[0], "delimiter") {
+				flush()
+				delimiter = fields[1]
+				i += len(line)
+				continue
+			}
+		}
+		lineStart = false
+		c := sql[i]
+		end := i + 1
+		isCode := true
+		switch {
+		case strings.HasPrefix(sql[i:], delimiter):
+			flush()
+			i += len(delimiter)
+			continue
+		case c == '\'' || c == '"' || c == '`':
+			end = scanQuoted(sql, i)
+		case strings.HasPrefix(sql[i:], "--") || c == '#':
+			end = indexFrom(sql, i, "\n", 0)
+			isCode = false
+		case strings.HasPrefix(sql[i:], "/*"):
+			end = indexFrom(sql, i+2, "*/", 2)
+			// /*! */ 与 /*+ */ 是 MySQL 的可执行注释与优化器提示
+			isCode = strings.HasPrefix(sql[i:], "/*!") || strings.HasPrefix(sql[i:], "/*+")
+		case c == '$':
+			if tag := dollarTag(sql[i:]); tag != "" {
+				end = indexFrom(sql, i+len(tag), tag, len(tag))
 			}
+		case c == '\n':
+			lineStart = true
 		}
+		if isCode && !isSpace(c) {
+			hasCode = true
+		}
+		buf.WriteString(sql[i:end])
+		i = end
 	}
-	return migrates, nil
+	flush()
+	return stmts
+}
+
+// scanQuoted 返回从 start 处引号开始的字符串结束位置，兼容反斜杠转义与重复引号转义
+func scanQuoted(s string, start int) int {
+	q := s[start]
+	for j := start + 1; j < len(s); j++ {
+		switch {
+		case s[j] == '\\' && q != '`':
+			j++
+		case s[j] == q:
+			if j+1 < len(s) && s[j+1] == q {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return len(s)
+}
+
+// indexFrom 返回 sep 在 s[from:] 中出现位置加 extra，未找到时返回 len(s)
+func indexFrom(s string, from int, sep string, extra int) int {
+	if idx := strings.Index(s[from:], sep); idx >= 0 {
+		return from + idx + extra
+	}
+	return len(s)
+}
+
+func dollarTag(s string) string {
+	for j := 1; j < len(s); j++ {
+		c := s[j]
+		switch {
+		case c == '$':
+			return s[:j+1]
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || j > 1 && c >= '0' && c <= '9':
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
 }
@@ -0,0 +1,129 @@
+package db
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSplitStatements(t *testing.T) {
+	cases := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{
+			name: "single",
+			sql:  "CREATE TABLE a (id int)",
+			want: []string{"CREATE TABLE a (id int)"},
+		},
+		{
+			name: "comments and quotes",
+			sql: `-- create; users
+CREATE TABLE users (name varchar(10) DEFAULT 'a;b'); # trailing; comment
+/* block; */ INSERT INTO users VALUES ("x;\"y");
+-- only a comment;`,
+			want: []string{
+				"-- create; users\nCREATE TABLE users (name varchar(10) DEFAULT 'a;b')",
+				"# trailing; comment\n/* block; */ INSERT INTO users VALUES (\"x;\\\"y\")",
+			},
+		},
+		{
+			name: "mysql delimiter",
+			sql: `DELIMITER $$
+CREATE TRIGGER t BEFORE INSERT ON a FOR EACH ROW BEGIN SET NEW.x = 1; END$$
+DELIMITER ;
+SELECT 1;`,
+			want: []string{
+				"CREATE TRIGGER t BEFORE INSERT ON a FOR EACH ROW BEGIN SET NEW.x = 1; END",
+				"SELECT 1",
+			},
+		},
+		{
+			name: "postgres dollar quote",
+			sql:  "CREATE FUNCTION f() RETURNS int AS $body$ SELECT 1; $body$ LANGUAGE sql; SELECT $1",
+			want: []string{
+				"CREATE FUNCTION f() RETURNS int AS $body$ SELECT 1; $body$ LANGUAGE sql",
+				"SELECT $1",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := SplitStatements(c.sql); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestLoadMigratesOrderAndSources(t *testing.T) {
+	migrateFs := fstest.MapFS{
+		"migrations/main/9_a.up.sql":                   {Data: []byte("SELECT 1")},
+		"migrations/main/10_b.up.sql":                  {Data: []byte("SELECT 2")},
+		"migrations/main/10_b.down.sql":                {Data: []byte("SELECT 3")},
+		"migrations/main/20240101000000000_c.up.sql":   {Data: []byte("SELECT 4")},
+		"migrations/main/README.md":                    {Data: []byte("ignored")},
+		"migrations/main/9223372036854775808_d.up.sql": {Data: []byte("SELECT 5")},
+	}
+	goMigration := &Migration{ID: "11_go"}
+	migrates, err := LoadMigrates(migrateFs, WithRoot("migrations"), WithGoMigrations("main", goMigration))
+	if err != nil {
+		t.Fatalf("LoadMigrates: %v", err)
+	}
+	var ids []string
+	for _, m := range migrates["main"] {
+		ids = append(ids, m.ID)
+	}
+	want := []string{"9_a", "10_b", "11_go", "20240101000000000_c", "9223372036854775808_d"}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("got order %v, want %v", ids, want)
+	}
+	if migrates["main"][1].Rollback == nil || migrates["main"][1].Checksum != Checksum([]byte("SELECT 2")) {
+		t.Errorf("10_b should have rollback and checksum of its up file")
+	}
+}
+
+func TestMigrationIDLessMixedPrefixes(t *testing.T) {
+	// 5a_x 按字符串介于 10_b 与 9_a 之间，数字前缀必须整体排在前面才能保持传递性
+	ids := []string{"init", "10_b", "5a_x", "9_a", "010_a", "add_users", "1a_y"}
+	for _, a := range ids {
+		if migrationIDLess(a, a) {
+			t.Errorf("%s < %s", a, a)
+		}
+		for _, b := range ids {
+			if a != b && migrationIDLess(a, b) == migrationIDLess(b, a) {
+				t.Errorf("%s and %s are not ordered", a, b)
+			}
+			for _, c := range ids {
+				if migrationIDLess(a, b) && migrationIDLess(b, c) && !migrationIDLess(a, c) {
+					t.Errorf("not transitive: %s < %s < %s", a, b, c)
+				}
+			}
+		}
+	}
+	sorted := slices.Clone(ids)
+	slices.SortFunc(sorted, func(a, b string) int {
+		if migrationIDLess(a, b) {
+			return -1
+		}
+		if migrationIDLess(b, a) {
+			return 1
+		}
+		return 0
+	})
+	want := []string{"9_a", "010_a", "10_b", "1a_y", "5a_x", "add_users", "init"}
+	if !reflect.DeepEqual(sorted, want) {
+		t.Errorf("got order %v, want %v", sorted, want)
+	}
+}
+
+func TestLoadMigratesRejectsDownOnly(t *testing.T) {
+	migrateFs := fstest.MapFS{
+		"sqls/main/1_a.down.sql": {Data: []byte("SELECT 1")},
+	}
+	if _, err := LoadMigrates(migrateFs); err == nil {
+		t.Error("migration without up file should fail to load")
+	}
+}
@@ -0,0 +1,364 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultMigrateLockTimeout    = time.Minute
+	defaultMigrateLockStaleAfter = 10 * time.Minute
+	migrateLockPollInterval      = 200 * time.Millisecond
+)
+
+var (
+	// ErrMigrateLockTimeout 在等待迁移锁超时时返回
+	ErrMigrateLockTimeout = errors.New("gormigrate: Timeout waiting for migration lock")
+	// ErrMigrateLockSingleConn 在连接池只有一条连接时返回：咨询锁独占这条连接后迁移无法再获取连接
+	ErrMigrateLockSingleConn = errors.New("gormigrate: migration lock needs a dedicated connection, set MaxOpenConns > 1 or DisableLock")
+)
+
+// migrateLocker 在迁移前获取跨进程互斥锁。
+// 多副本同时启动时只有持锁者执行迁移，其余副本等锁释放后再检查迁移表，从而不会重复执行 DDL。
+type migrateLocker interface {
+	Lock(ctx context.Context) error
+	Unlock() error
+}
+
+// newMigrateLocker 按驱动选择锁实现：MySQL 使用 GET_LOCK，Postgres 使用 pg_advisory_lock，其余驱动退化为锁表。
+func newMigrateLocker(db *gorm.DB, options *Options) migrateLocker {
+	name := options.LockName
+	if name == "" {
+		name = options.TableName
+	}
+	switch db.Dialector.Name() {
+	case "mysql":
+		return &mysqlMigrateLocker{db: db, name: name}
+	case "postgres":
+		return &postgresMigrateLocker{db: db, key: migrateLockKey(name)}
+	default:
+		return &tableMigrateLocker{
+			db:         db,
+			table:      options.TableName + "_lock",
+			name:       name,
+			owner:      fmt.Sprintf("%s:%x", migrateHost(), rand.Uint32()),
+			staleAfter: options.LockStaleAfter,
+		}
+	}
+}
+
+// migrateLockKey 将锁名映射为 pg_advisory_lock 需要的 bigint
+func migrateLockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+// 咨询锁与会话绑定，加锁与解锁必须在同一条连接上执行，因此从连接池中独占一条连接。
+// MaxOpenConns 为 1 时迁移会一直等待这条连接，直接返回 ErrMigrateLockSingleConn。
+func dedicatedConn(ctx context.Context, db *gorm.DB) (*sql.Conn, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	if sqlDB.Stats().MaxOpenConnections == 1 {
+		return nil, ErrMigrateLockSingleConn
+	}
+	return sqlDB.Conn(ctx)
+}
+
+type mysqlMigrateLocker struct {
+	db   *gorm.DB
+	name string
+	conn *sql.Conn
+}
+
+func (l *mysqlMigrateLocker) Lock(ctx context.Context) error {
+	conn, err := dedicatedConn(ctx, l.db)
+	if err != nil {
+		return err
+	}
+	timeout := defaultMigrateLockTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	// GET_LOCK 在整个实例范围内生效，拼接当前库名避免不同库的迁移互相阻塞
+	var acquired sql.NullInt64
+	err = conn.QueryRowContext(
+		ctx,
+		"SELECT GET_LOCK(CONCAT(DATABASE(), ':', ?), ?)",
+		l.name,
+		int64(math.Ceil(timeout.Seconds())),
+	).Scan(&acquired)
+	if err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return ErrMigrateLockTimeout
+		}
+		return fmt.Errorf("GET_LOCK: %w", err)
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		conn.Close()
+		return ErrMigrateLockTimeout
+	}
+	l.conn = conn
+	return nil
+}
+
+func (l *mysqlMigrateLocker) Unlock() error {
+	if l.conn == nil {
+		return nil
+	}
+	defer func() {
+		l.conn.Close()
+		l.conn = nil
+	}()
+	var released sql.NullInt64
+	return l.conn.QueryRowContext(
+		context.Background(),
+		"SELECT RELEASE_LOCK(CONCAT(DATABASE(), ':', ?))",
+		l.name,
+	).Scan(&released)
+}
+
+type postgresMigrateLocker struct {
+	db   *gorm.DB
+	key  int64
+	conn *sql.Conn
+}
+
+// Lock 轮询 pg_try_advisory_lock 而不是阻塞在 pg_advisory_lock 上，以便遵守 ctx 超时
+func (l *postgresMigrateLocker) Lock(ctx context.Context) error {
+	conn, err := dedicatedConn(ctx, l.db)
+	if err != nil {
+		return err
+	}
+	for {
+		var acquired bool
+		if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", l.key).Scan(&acquired); err != nil {
+			conn.Close()
+			if ctx.Err() != nil {
+				return ErrMigrateLockTimeout
+			}
+			return fmt.Errorf("pg_try_advisory_lock: %w", err)
+		}
+		if acquired {
+			l.conn = conn
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			conn.Close()
+			return ErrMigrateLockTimeout
+		case <-time.After(migrateLockPollInterval):
+		}
+	}
+}
+
+func (l *postgresMigrateLocker) Unlock() error {
+	if l.conn == nil {
+		return nil
+	}
+	defer func() {
+		l.conn.Close()
+		l.conn = nil
+	}()
+	var released bool
+	return l.conn.QueryRowContext(context.Background(), "SELECT pg_advisory_unlock($1)", l.key).Scan(&released)
+}
+
+// tableMigrateLocker 依赖主键唯一约束实现互斥：插入成功即持锁，删除即释放。
+// 持锁期间每 staleAfter/3 刷新一次 locked_at，持锁进程异常退出时锁记录会残留，
+// 超过 staleAfter 未刷新的记录视为过期并被接管。locked_at 与锁龄都使用数据库的时钟，不受各主机时钟偏差影响。
+type tableMigrateLocker struct {
+	db    *gorm.DB
+	table string
+	name  string
+	// owner 区分同一主机上的多个进程，Unlock 只删除自己持有的记录
+	owner      string
+	staleAfter time.Duration
+	// stop 与 done 控制持锁期间刷新 locked_at 的协程
+	stop chan struct{}
+	done chan struct{}
+}
+
+type migrateLockRecord struct {
+	ID       string `gorm:"primaryKey;size:255"`
+	Owner    string `gorm:"size:255"`
+	LockedAt time.Time
+}
+
+// heldLock 为读到的锁记录以及读取时数据库的当前时间
+type heldLock struct {
+	ID       string
+	Owner    string
+	LockedAt dbTime
+	DbNow    dbTime
+}
+
+// age 返回按数据库时钟计算的锁龄
+func (h heldLock) age() time.Duration {
+	return h.DbNow.Sub(h.LockedAt.Time)
+}
+
+func (l *tableMigrateLocker) Lock(ctx context.Context) error {
+	db := l.db.Session(&gorm.Session{NewDB: true}).WithContext(ctx)
+	if !db.Migrator().HasTable(l.table) {
+		if err := db.Table(l.table).AutoMigrate(&migrateLockRecord{}); err != nil {
+			return fmt.Errorf("create lock table %s: %w", l.table, err)
+		}
+	}
+	for {
+		err := db.Table(l.table).Create(map[string]any{
+			"id":        l.name,
+			"owner":     l.owner,
+			"locked_at": gorm.Expr("CURRENT_TIMESTAMP"),
+		}).Error
+		if err == nil {
+			l.heartbeat()
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("%w: lock `%s` held in table %s", ErrMigrateLockTimeout, l.name, l.table)
+		}
+		// 只有锁已被持有时才重试，权限不足、表不存在等错误直接返回
+		held, found, findErr := l.find(db)
+		if !found && !isDuplicateKey(db, err) {
+			return fmt.Errorf("acquire lock `%s` in table %s: %w", l.name, l.table, errors.Join(err, findErr))
+		}
+		if found && l.staleAfter > 0 && held.age() > l.staleAfter {
+			taken, err := l.takeOver(db, held)
+			if err != nil {
+				return fmt.Errorf("take over stale lock `%s` in table %s: %w", l.name, l.table, err)
+			}
+			if taken {
+				l.heartbeat()
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: lock `%s` held by %s in table %s", ErrMigrateLockTimeout, l.name, held.Owner, l.table)
+		case <-time.After(migrateLockPollInterval):
+		}
+	}
+}
+
+// isDuplicateKey 使用驱动的 ErrorTranslator 判断唯一约束冲突，驱动未实现时只依据锁记录是否存在
+func isDuplicateKey(db *gorm.DB, err error) bool {
+	if translator, ok := db.Dialector.(gorm.ErrorTranslator); ok {
+		err = translator.Translate(err)
+	}
+	return errors.Is(err, gorm.ErrDuplicatedKey)
+}
+
+// find 读取锁记录，同时取数据库的当前时间用于计算锁龄
+func (l *tableMigrateLocker) find(db *gorm.DB) (heldLock, bool, error) {
+	var held heldLock
+	err := db.Table(l.table).
+		Select("id, owner, locked_at, CURRENT_TIMESTAMP").
+		Where("id = ?", l.name).
+		Limit(1).
+		Row().
+		Scan(&held.ID, &held.Owner, &held.LockedAt, &held.DbNow)
+	if errors.Is(err, sql.ErrNoRows) {
+		return held, false, nil
+	}
+	return held, err == nil, err
+}
+
+// takeOver 以读到的 owner 为条件更新记录，多个进程同时接管时只有一个成功
+func (l *tableMigrateLocker) takeOver(db *gorm.DB, stale heldLock) (bool, error) {
+	res := db.Table(l.table).
+		Where("id = ? AND owner = ?", l.name, stale.Owner).
+		Updates(map[string]any{"owner": l.owner, "locked_at": gorm.Expr("CURRENT_TIMESTAMP")})
+	return res.RowsAffected == 1, res.Error
+}
+
+// heartbeat 启动刷新 locked_at 的协程，迁移耗时超过 staleAfter 时锁也不会被其他进程接管
+func (l *tableMigrateLocker) heartbeat() {
+	if l.staleAfter <= 0 {
+		return
+	}
+	l.stop, l.done = make(chan struct{}), make(chan struct{})
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(l.staleAfter / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			res := l.db.Session(&gorm.Session{NewDB: true}).
+				Table(l.table).
+				Where("id = ? AND owner = ?", l.name, l.owner).
+				Update("locked_at", gorm.Expr("CURRENT_TIMESTAMP"))
+			// 记录已被删除或接管，不再刷新；其他错误等下次重试
+			if res.Error == nil && res.RowsAffected == 0 {
+				return
+			}
+		}
+	}(l.stop, l.done)
+}
+
+// stopHeartbeat 停止刷新 locked_at 并等待协程退出
+func (l *tableMigrateLocker) stopHeartbeat() {
+	if l.stop == nil {
+		return
+	}
+	close(l.stop)
+	<-l.done
+	l.stop, l.done = nil, nil
+}
+
+func (l *tableMigrateLocker) Unlock() error {
+	l.stopHeartbeat()
+	return l.db.Session(&gorm.Session{NewDB: true}).
+		Table(l.table).
+		Where("id = ? AND owner = ?", l.name, l.owner).
+		Delete(&migrateLockRecord{}).
+		Error
+}
+
+// dbTime 接收数据库返回的时间。sqlite 只按列声明的类型转换时间，表达式的结果以文本返回
+type dbTime struct {
+	time.Time
+}
+
+var dbTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	time.RFC3339Nano,
+}
+
+func (t *dbTime) Scan(value any) error {
+	var text string
+	switch v := value.(type) {
+	case time.Time:
+		t.Time = v
+		return nil
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return fmt.Errorf("unsupported time value %T", value)
+	}
+	for _, layout := range dbTimeLayouts {
+		if parsed, err := time.ParseInLocation(layout, text, time.UTC); err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported time format %q", text)
+}
@@ -0,0 +1,186 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestNewMigrateLockerByDialect(t *testing.T) {
+	options := &Options{TableName: "migrations"}
+	db := &gorm.DB{Config: &gorm.Config{Dialector: mysql.Dialector{}}}
+	if _, ok := newMigrateLocker(db, options).(*mysqlMigrateLocker); !ok {
+		t.Errorf("mysql dialector should use GET_LOCK locker")
+	}
+	db = &gorm.DB{Config: &gorm.Config{Dialector: &namedDialector{name: "sqlite"}}}
+	l, ok := newMigrateLocker(db, options).(*tableMigrateLocker)
+	if !ok {
+		t.Fatalf("unknown dialector should fall back to lock table")
+	}
+	if l.table != "migrations_lock" || l.name != "migrations" {
+		t.Errorf("unexpected lock table %q name %q", l.table, l.name)
+	}
+}
+
+func TestMigrateLockKeyStable(t *testing.T) {
+	if migrateLockKey("migrations") != migrateLockKey("migrations") {
+		t.Error("lock key should be deterministic")
+	}
+	if migrateLockKey("a") == migrateLockKey("b") {
+		t.Error("different lock names should map to different keys")
+	}
+}
+
+func TestNewMigrateNilOptions(t *testing.T) {
+	m := NewMigrate(nil, nil, nil)
+	if m.options.TableName != DefaultOptions.TableName || m.options.LockTimeout != defaultMigrateLockTimeout ||
+		m.options.LockStaleAfter != defaultMigrateLockStaleAfter {
+		t.Errorf("nil options should be filled with defaults, got %+v", m.options)
+	}
+}
+
+type namedDialector struct {
+	gorm.Dialector
+	name string
+}
+
+func (d *namedDialector) Name() string {
+	return d.name
+}
+
+// openSQLite 打开临时目录下的 sqlite 数据库，cgo 不可用时跳过
+func openSQLite(t *testing.T) *gorm.DB {
+	t.Helper()
+	conn, err := gorm.Open(
+		sqlite.Open(filepath.Join(t.TempDir(), "test.db")+"?_busy_timeout=5000"),
+		&gorm.Config{Logger: logger.Discard},
+	)
+	if err != nil {
+		t.Skipf("sqlite unavailable: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := conn.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return conn
+}
+
+func TestDedicatedConnSingleConn(t *testing.T) {
+	conn := openSQLite(t)
+	sqlDB, _ := conn.DB()
+	sqlDB.SetMaxOpenConns(1)
+	if _, err := dedicatedConn(context.Background(), conn); !errors.Is(err, ErrMigrateLockSingleConn) {
+		t.Fatalf("expected ErrMigrateLockSingleConn, got %v", err)
+	}
+	sqlDB.SetMaxOpenConns(2)
+	c, err := dedicatedConn(context.Background(), conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+}
+
+func TestTableMigrateLocker(t *testing.T) {
+	conn := openSQLite(t)
+	options := &Options{TableName: "migrations", LockTimeout: time.Minute}
+	a := newMigrateLocker(conn, options).(*tableMigrateLocker)
+	b := newMigrateLocker(conn, options).(*tableMigrateLocker)
+
+	if err := a.Lock(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if err := b.Lock(ctx); !errors.Is(err, ErrMigrateLockTimeout) {
+		t.Fatalf("expected ErrMigrateLockTimeout, got %v", err)
+	}
+	// 只删除自己持有的记录
+	if err := b.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := a.find(conn); !found {
+		t.Fatal("unlock by non-owner released the lock")
+	}
+	if err := a.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Lock(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	b.Unlock()
+}
+
+func TestTableMigrateLockerTakesOverStaleLock(t *testing.T) {
+	conn := openSQLite(t)
+	options := &Options{TableName: "migrations", LockStaleAfter: time.Second}
+	crashed := newMigrateLocker(conn, options).(*tableMigrateLocker)
+	if err := crashed.Lock(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// 模拟进程崩溃：不再刷新 locked_at
+	crashed.stopHeartbeat()
+
+	next := newMigrateLocker(conn, options).(*tableMigrateLocker)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := next.Lock(ctx); err != nil {
+		t.Fatalf("stale lock not taken over: %v", err)
+	}
+	held, _, _ := next.find(conn)
+	if held.Owner != next.owner {
+		t.Fatalf("lock owned by %q, want %q", held.Owner, next.owner)
+	}
+	// 原持有者恢复后 Unlock 不影响新持有者
+	crashed.Unlock()
+	if _, found, _ := next.find(conn); !found {
+		t.Fatal("stale owner released the new lock")
+	}
+}
+
+func TestTableMigrateLockerHeartbeat(t *testing.T) {
+	conn := openSQLite(t)
+	options := &Options{TableName: "migrations", LockStaleAfter: time.Second}
+	holder := newMigrateLocker(conn, options).(*tableMigrateLocker)
+	if err := holder.Lock(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer holder.Unlock()
+
+	// 持有时间超过 LockStaleAfter 的迁移仍在刷新 locked_at，锁不会被接管
+	waiter := newMigrateLocker(conn, options).(*tableMigrateLocker)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := waiter.Lock(ctx); !errors.Is(err, ErrMigrateLockTimeout) {
+		t.Fatalf("live lock taken over: %v", err)
+	}
+	if held, _, _ := holder.find(conn); held.Owner != holder.owner || held.age() > options.LockStaleAfter {
+		t.Fatalf("lock owned by %q aged %v, want %q", held.Owner, held.age(), holder.owner)
+	}
+}
+
+func TestTableMigrateLockerReturnsNonDuplicateError(t *testing.T) {
+	conn := openSQLite(t)
+	// 缺少 owner 列，插入失败但不是锁冲突
+	if err := conn.Exec("CREATE TABLE migrations_lock (id varchar(255) PRIMARY KEY)").Error; err != nil {
+		t.Fatal(err)
+	}
+	l := newMigrateLocker(conn, &Options{TableName: "migrations", LockTimeout: time.Minute})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	start := time.Now()
+	err := l.Lock(ctx)
+	if err == nil || errors.Is(err, ErrMigrateLockTimeout) {
+		t.Fatalf("expected insert error, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("non-duplicate error was retried for %v", time.Since(start))
+	}
+}
@@ -0,0 +1,46 @@
+package outbox
+
+import (
+	"github.com/pkg/errors"
+	"github.com/puper/leo/components/db"
+	"github.com/puper/leo/components/db/outbox/config"
+	"github.com/puper/leo/engine"
+	"gorm.io/gorm"
+)
+
+func Builder(cfg *config.Config, configurers ...func(*Outbox) error) engine.Builder {
+	return func() (any, error) {
+		me := New(cfg)
+		for _, configurer := range configurers {
+			if err := configurer(me); err != nil {
+				return nil, errors.WithMessage(err, "outbox.configurer")
+			}
+		}
+		if err := me.Start(); err != nil {
+			return nil, errors.WithMessage(err, "outbox.Start")
+		}
+		return me, nil
+	}
+}
+
+func WithDb(f func() *db.Db) func(*Outbox) error {
+	return func(me *Outbox) error {
+		if d := f(); d != nil {
+			me.conn = func() (*gorm.DB, error) {
+				w, err := d.Lookup(me.config.Server)
+				if err != nil {
+					return nil, err
+				}
+				return w.Write(), nil
+			}
+		}
+		return nil
+	}
+}
+
+func WithPublisher(p Publisher) func(*Outbox) error {
+	return func(me *Outbox) error {
+		me.publisher = p
+		return nil
+	}
+}
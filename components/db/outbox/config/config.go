@@ -0,0 +1,23 @@
+package config
+
+import "time"
+
+type Config struct {
+	// Server 为 outbox 表所在的 db servers 配置项
+	Server    string `json:"server"`
+	TableName string `json:"tableName"`
+	BatchSize int    `json:"batchSize"`
+	// PollInterval 没有待发送事件时的轮询间隔
+	PollInterval   time.Duration `json:"pollInterval"`
+	PublishTimeout time.Duration `json:"publishTimeout"`
+	// LeaseTimeout 领取事件后独占发送的时长，持有者崩溃时事件在到期后被其他副本重新领取，
+	// 默认 1m，且不小于两倍 PublishTimeout
+	LeaseTimeout time.Duration `json:"leaseTimeout"`
+	// MaxAttempts 超过该次数的事件标记为 dead 不再发送，0 表示无限重试
+	MaxAttempts      int           `json:"maxAttempts"`
+	RetryInterval    time.Duration `json:"retryInterval"`
+	MaxRetryInterval time.Duration `json:"maxRetryInterval"`
+	// Retention 已发送事件的保留时长，超过后由清理任务删除
+	Retention       time.Duration `json:"retention"`
+	CleanupInterval time.Duration `json:"cleanupInterval"`
+}
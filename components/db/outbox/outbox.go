@@ -0,0 +1,410 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/puper/leo/components/db"
+	"github.com/puper/leo/components/db/outbox/config"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultTableName        = "outbox"
+	defaultBatchSize        = 100
+	defaultPollInterval     = time.Second
+	defaultPublishTimeout   = 5 * time.Second
+	defaultLeaseTimeout     = time.Minute
+	defaultRetryInterval    = time.Second
+	defaultMaxRetryInterval = 5 * time.Minute
+	defaultRetention        = 7 * 24 * time.Hour
+	defaultCleanupInterval  = time.Hour
+	cleanupBatchSize        = 1000
+	maxLastErrorLength      = 1024
+)
+
+type Status int
+
+const (
+	StatusPending Status = iota
+	StatusPublished
+	// StatusDead 超过 MaxAttempts 后不再发送，需要人工处理
+	StatusDead
+)
+
+// Event 是 outbox 表中的一行，同一聚合 (AggregateType + AggregateID) 的事件按 ID 顺序发送
+type Event struct {
+	ID            int64             `gorm:"primaryKey;autoIncrement"`
+	AggregateType string            `gorm:"size:255;not null;index:idx_outbox_aggregate,priority:1"`
+	AggregateID   string            `gorm:"size:255;not null;index:idx_outbox_aggregate,priority:2"`
+	EventType     string            `gorm:"size:255;not null"`
+	Payload       []byte            `gorm:"not null"`
+	Headers       map[string]string `gorm:"serializer:json;type:text"`
+	Status        Status            `gorm:"not null;default:0;index:idx_outbox_status,priority:1"`
+	Attempts      int               `gorm:"not null;default:0"`
+	NextAttemptAt time.Time         `gorm:"not null"`
+	LastError     string            `gorm:"size:1024"`
+	CreatedAt     time.Time         `gorm:"not null;index:idx_outbox_status,priority:2"`
+	PublishedAt   *time.Time
+}
+
+func (Event) TableName() string {
+	return defaultTableName
+}
+
+// Migration 返回创建 outbox 表的迁移，通过 db.WithGoMigrations 与 SQL 迁移一起执行
+func Migration(id, table string) *db.Migration {
+	if table == "" {
+		table = defaultTableName
+	}
+	return &db.Migration{
+		ID: id,
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table(table).AutoMigrate(&Event{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(table)
+		},
+	}
+}
+
+type Outbox struct {
+	config *config.Config
+	// conn 返回 outbox 表所在的写连接，server 在运行时被移除时返回错误，relay 视为可重试的失败
+	conn      func() (*gorm.DB, error)
+	publisher Publisher
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	wakeCh chan struct{}
+}
+
+func New(cfg *config.Config) *Outbox {
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Outbox{
+		config: cfg,
+		ctx:    ctx,
+		cancel: cancel,
+		wakeCh: make(chan struct{}, 1),
+	}
+}
+
+// Enqueue 在调用方的事务 tx 中写入事件，事件与业务数据同时提交或回滚
+func (me *Outbox) Enqueue(tx *gorm.DB, events ...*Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for _, evt := range events {
+		evt.Status = StatusPending
+		if evt.NextAttemptAt.IsZero() {
+			evt.NextAttemptAt = now
+		}
+	}
+	return tx.Table(me.tableName()).Create(events).Error
+}
+
+// Notify 唤醒 relay 立即发送，通常在包含 Enqueue 的事务提交后调用以降低延迟
+func (me *Outbox) Notify() {
+	select {
+	case me.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func (me *Outbox) Start() error {
+	if me.conn == nil {
+		return errors.New("db is nil")
+	}
+	if _, err := me.conn(); err != nil {
+		return err
+	}
+	if me.publisher == nil {
+		return errors.New("publisher is nil")
+	}
+	me.wg.Add(2)
+	go me.relayLoop()
+	go me.cleanupLoop()
+	return nil
+}
+
+func (me *Outbox) Close() error {
+	me.cancel()
+	me.wg.Wait()
+	return nil
+}
+
+func (me *Outbox) relayLoop() {
+	defer me.wg.Done()
+	for {
+		n, err := me.RelayOnce(me.ctx)
+		if err != nil && me.ctx.Err() == nil {
+			log.Printf("outbox: relay failed: %v", err)
+		}
+		// 有事件发送成功时，同一聚合的后续事件或超出 BatchSize 的事件可能已经到期，立即进行下一轮；
+		// 出错或没有可发送的事件时等待，避免数据库或消息系统故障时空转
+		if err == nil && n > 0 {
+			continue
+		}
+		select {
+		case <-me.ctx.Done():
+			return
+		case <-me.wakeCh:
+		case <-time.After(me.pollInterval()):
+		}
+	}
+}
+
+// RelayOnce 领取一批到期的待发送事件并逐个发送，返回发送成功的事件数。
+// 领取在短事务中完成：SELECT ... FOR UPDATE SKIP LOCKED 只选出每个聚合中最早的待发送事件，
+// 并将其 next_attempt_at 推迟 LeaseTimeout 作为租约，发送在事务之外进行。
+// 多个副本同时运行 relay 时不会领取到同一事件，也不会越过同一聚合中未发送成功的事件；
+// 持有租约的进程崩溃后，事件在租约到期后被重新领取。
+func (me *Outbox) RelayOnce(ctx context.Context) (int, error) {
+	events, leaseUntil, err := me.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+	published := 0
+	for i, evt := range events {
+		// 租约即将到期或 relay 正在关闭时归还剩余事件，避免与其他副本重复发送
+		if ctx.Err() != nil || time.Until(leaseUntil) < me.publishTimeout() {
+			return published, me.release(ctx, events[i:])
+		}
+		if err := me.publish(ctx, evt); err != nil {
+			return published, errors.Join(err, me.release(ctx, events[i+1:]))
+		}
+		if evt.Status == StatusPublished {
+			published++
+		}
+	}
+	return published, nil
+}
+
+// claim 领取到期且同一聚合中没有更早待发送事件的事件，返回租约到期时间
+func (me *Outbox) claim(ctx context.Context) ([]*Event, time.Time, error) {
+	var events []*Event
+	now := time.Now()
+	leaseUntil := now.Add(me.leaseTimeout())
+	conn, err := me.write(ctx)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	err = conn.Transaction(func(tx *gorm.DB) error {
+		table := tx.Statement.Quote(me.tableName())
+		err := tx.Table(me.tableName()).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", StatusPending, now).
+			Where(fmt.Sprintf(
+				"NOT EXISTS (SELECT 1 FROM %[1]s prior WHERE prior.aggregate_type = %[1]s.aggregate_type"+
+					" AND prior.aggregate_id = %[1]s.aggregate_id AND prior.status = ? AND prior.id < %[1]s.id)",
+				table,
+			), StatusPending).
+			Order("id").
+			Limit(me.batchSize()).
+			Find(&events).
+			Error
+		if err != nil || len(events) == 0 {
+			return err
+		}
+		ids := make([]int64, len(events))
+		for i, evt := range events {
+			ids[i] = evt.ID
+		}
+		return tx.Table(me.tableName()).Where("id IN ?", ids).Update("next_attempt_at", leaseUntil).Error
+	})
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return events, leaseUntil, nil
+}
+
+// release 归还未发送事件的租约，使其可以被立即重新领取
+func (me *Outbox) release(ctx context.Context, events []*Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(events))
+	for i, evt := range events {
+		ids[i] = evt.ID
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), me.publishTimeout())
+	defer cancel()
+	conn, err := me.write(ctx)
+	if err != nil {
+		return err
+	}
+	return conn.
+		Table(me.tableName()).
+		Where("id IN ? AND status = ?", ids, StatusPending).
+		Update("next_attempt_at", time.Now()).
+		Error
+}
+
+func (me *Outbox) publish(ctx context.Context, evt *Event) error {
+	publishCtx, cancel := context.WithTimeout(ctx, me.publishTimeout())
+	publishErr := me.publisher.Publish(publishCtx, evt)
+	cancel()
+	now := time.Now()
+	evt.Attempts++
+	updates := map[string]any{"attempts": evt.Attempts}
+	if publishErr == nil {
+		evt.Status = StatusPublished
+		evt.PublishedAt = &now
+		updates["status"] = evt.Status
+		updates["published_at"] = now
+		updates["last_error"] = ""
+	} else {
+		lastError := publishErr.Error()
+		if len(lastError) > maxLastErrorLength {
+			lastError = lastError[:maxLastErrorLength]
+		}
+		evt.LastError = lastError
+		evt.NextAttemptAt = now.Add(me.retryDelay(evt.Attempts))
+		updates["last_error"] = lastError
+		updates["next_attempt_at"] = evt.NextAttemptAt
+		if me.config.MaxAttempts > 0 && evt.Attempts >= me.config.MaxAttempts {
+			evt.Status = StatusDead
+			updates["status"] = evt.Status
+			log.Printf("outbox: event %d dead after %d attempts: %v", evt.ID, evt.Attempts, publishErr)
+		}
+	}
+	// relay 关闭时仍需记录已发送的结果，否则事件会被重复发送
+	updateCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), me.publishTimeout())
+	defer cancel()
+	conn, err := me.write(updateCtx)
+	if err != nil {
+		return err
+	}
+	return conn.Table(me.tableName()).Where("id = ?", evt.ID).Updates(updates).Error
+}
+
+func (me *Outbox) retryDelay(attempts int) time.Duration {
+	delay := me.retryInterval()
+	for i := 1; i < attempts && delay < me.maxRetryInterval(); i++ {
+		delay *= 2
+	}
+	return min(delay, me.maxRetryInterval())
+}
+
+func (me *Outbox) cleanupLoop() {
+	defer me.wg.Done()
+	for {
+		select {
+		case <-me.ctx.Done():
+			return
+		case <-time.After(me.cleanupInterval()):
+		}
+		if err := me.Cleanup(me.ctx); err != nil && me.ctx.Err() == nil {
+			log.Printf("outbox: cleanup failed: %v", err)
+		}
+	}
+}
+
+// Cleanup 分批删除超过保留时长的已发送事件
+func (me *Outbox) Cleanup(ctx context.Context) error {
+	before := time.Now().Add(-me.retention())
+	for {
+		var ids []int64
+		conn, err := me.write(ctx)
+		if err != nil {
+			return err
+		}
+		err = conn.Table(me.tableName()).
+			Where("status = ? AND published_at < ?", StatusPublished, before).
+			Limit(cleanupBatchSize).
+			Pluck("id", &ids).
+			Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		if err := conn.Table(me.tableName()).Where("id IN ?", ids).Delete(&Event{}).Error; err != nil {
+			return err
+		}
+		if len(ids) < cleanupBatchSize {
+			return nil
+		}
+	}
+}
+
+func (me *Outbox) write(ctx context.Context) (*gorm.DB, error) {
+	conn, err := me.conn()
+	if err != nil {
+		return nil, err
+	}
+	return conn.WithContext(ctx), nil
+}
+
+func (me *Outbox) tableName() string {
+	if me.config.TableName != "" {
+		return me.config.TableName
+	}
+	return defaultTableName
+}
+
+func (me *Outbox) batchSize() int {
+	if me.config.BatchSize > 0 {
+		return me.config.BatchSize
+	}
+	return defaultBatchSize
+}
+
+func (me *Outbox) pollInterval() time.Duration {
+	if me.config.PollInterval > 0 {
+		return me.config.PollInterval
+	}
+	return defaultPollInterval
+}
+
+// leaseTimeout 至少为两倍 PublishTimeout，保证领取后至少能发送一个事件
+func (me *Outbox) leaseTimeout() time.Duration {
+	lease := defaultLeaseTimeout
+	if me.config.LeaseTimeout > 0 {
+		lease = me.config.LeaseTimeout
+	}
+	return max(lease, 2*me.publishTimeout())
+}
+
+func (me *Outbox) publishTimeout() time.Duration {
+	if me.config.PublishTimeout > 0 {
+		return me.config.PublishTimeout
+	}
+	return defaultPublishTimeout
+}
+
+func (me *Outbox) retryInterval() time.Duration {
+	if me.config.RetryInterval > 0 {
+		return me.config.RetryInterval
+	}
+	return defaultRetryInterval
+}
+
+func (me *Outbox) maxRetryInterval() time.Duration {
+	if me.config.MaxRetryInterval > 0 {
+		return me.config.MaxRetryInterval
+	}
+	return defaultMaxRetryInterval
+}
+
+func (me *Outbox) retention() time.Duration {
+	if me.config.Retention > 0 {
+		return me.config.Retention
+	}
+	return defaultRetention
+}
+
+func (me *Outbox) cleanupInterval() time.Duration {
+	if me.config.CleanupInterval > 0 {
+		return me.config.CleanupInterval
+	}
+	return defaultCleanupInterval
+}
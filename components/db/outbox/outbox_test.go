@@ -0,0 +1,260 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/puper/leo/components/db"
+	dbconfig "github.com/puper/leo/components/db/config"
+	"github.com/puper/leo/components/db/outbox/config"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type recorder struct {
+	mu        sync.Mutex
+	published []string
+	fail      func(evt *Event) bool
+	attempts  atomic.Int64
+}
+
+func (me *recorder) Publish(ctx context.Context, evt *Event) error {
+	me.attempts.Add(1)
+	if me.fail != nil && me.fail(evt) {
+		return errors.New("broker unavailable")
+	}
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.published = append(me.published, evt.EventType)
+	return nil
+}
+
+func (me *recorder) events() []string {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	return append([]string(nil), me.published...)
+}
+
+// newTestOutbox 在临时 sqlite 库上创建 outbox 表，cgo 不可用时跳过
+func newTestOutbox(t *testing.T, cfg *config.Config, p Publisher) (*Outbox, *gorm.DB) {
+	t.Helper()
+	conn, err := gorm.Open(
+		sqlite.Open(filepath.Join(t.TempDir(), "outbox.db")+"?_busy_timeout=5000"),
+		&gorm.Config{Logger: logger.Discard},
+	)
+	if err != nil {
+		t.Skipf("sqlite unavailable: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := conn.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := Migration("1_outbox", "").Migrate(conn); err != nil {
+		t.Fatal(err)
+	}
+	me := New(cfg)
+	me.conn = func() (*gorm.DB, error) { return conn, nil }
+	me.publisher = p
+	return me, conn
+}
+
+func enqueue(t *testing.T, me *Outbox, conn *gorm.DB, events ...*Event) {
+	t.Helper()
+	for _, evt := range events {
+		if evt.Payload == nil {
+			evt.Payload = []byte("{}")
+		}
+	}
+	if err := me.Enqueue(conn, events...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func loadEvent(t *testing.T, conn *gorm.DB, eventType string) *Event {
+	t.Helper()
+	evt := new(Event)
+	if err := conn.Where("event_type = ?", eventType).First(evt).Error; err != nil {
+		t.Fatal(err)
+	}
+	return evt
+}
+
+func relayOnce(t *testing.T, me *Outbox, want int) {
+	t.Helper()
+	n, err := me.RelayOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != want {
+		t.Fatalf("RelayOnce published %d events, want %d", n, want)
+	}
+}
+
+func TestRelayKeepsAggregateOrder(t *testing.T) {
+	p := &recorder{}
+	me, conn := newTestOutbox(t, &config.Config{RetryInterval: time.Millisecond, MaxRetryInterval: time.Millisecond}, p)
+	enqueue(t, me, conn,
+		&Event{AggregateType: "order", AggregateID: "a", EventType: "a1"},
+		&Event{AggregateType: "order", AggregateID: "a", EventType: "a2"},
+		&Event{AggregateType: "order", AggregateID: "b", EventType: "b1"},
+	)
+
+	p.fail = func(evt *Event) bool { return evt.EventType == "a1" }
+	// a1 失败后 a2 不会被领取，b1 不受影响
+	relayOnce(t, me, 1)
+	if got := p.attempts.Load(); got != 2 {
+		t.Fatalf("expected a1 and b1 to be attempted, got %d attempts", got)
+	}
+
+	p.fail = nil
+	time.Sleep(5 * time.Millisecond)
+	relayOnce(t, me, 1)
+	relayOnce(t, me, 1)
+	relayOnce(t, me, 0)
+	if got := p.events(); len(got) != 3 || got[0] != "b1" || got[1] != "a1" || got[2] != "a2" {
+		t.Fatalf("unexpected publish order %v", got)
+	}
+}
+
+func TestRelayBackoff(t *testing.T) {
+	p := &recorder{fail: func(*Event) bool { return true }}
+	me, conn := newTestOutbox(t, &config.Config{RetryInterval: time.Hour, MaxRetryInterval: 3 * time.Hour}, p)
+	enqueue(t, me, conn, &Event{AggregateType: "order", AggregateID: "a", EventType: "a1"})
+
+	before := time.Now()
+	relayOnce(t, me, 0)
+	evt := loadEvent(t, conn, "a1")
+	if evt.Attempts != 1 || evt.Status != StatusPending || evt.LastError == "" {
+		t.Fatalf("unexpected event after failure %+v", evt)
+	}
+	if evt.NextAttemptAt.Before(before.Add(time.Hour)) {
+		t.Fatalf("next attempt %v not backed off", evt.NextAttemptAt)
+	}
+	// 未到重试时间的事件不会被领取
+	relayOnce(t, me, 0)
+	if got := p.attempts.Load(); got != 1 {
+		t.Fatalf("event retried before next_attempt_at, %d attempts", got)
+	}
+
+	for attempts, want := range []time.Duration{time.Hour, time.Hour, 2 * time.Hour, 3 * time.Hour, 3 * time.Hour} {
+		if got := me.retryDelay(attempts); got != want {
+			t.Errorf("retryDelay(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestRelayDeadLetter(t *testing.T) {
+	p := &recorder{fail: func(evt *Event) bool { return evt.EventType == "a1" }}
+	me, conn := newTestOutbox(t, &config.Config{MaxAttempts: 2, RetryInterval: time.Millisecond, MaxRetryInterval: time.Millisecond}, p)
+	enqueue(t, me, conn,
+		&Event{AggregateType: "order", AggregateID: "a", EventType: "a1"},
+		&Event{AggregateType: "order", AggregateID: "a", EventType: "a2"},
+	)
+
+	relayOnce(t, me, 0)
+	time.Sleep(5 * time.Millisecond)
+	relayOnce(t, me, 0)
+	if evt := loadEvent(t, conn, "a1"); evt.Status != StatusDead || evt.Attempts != 2 {
+		t.Fatalf("expected a1 dead after 2 attempts, got %+v", evt)
+	}
+	// dead 事件不再阻塞同一聚合的后续事件
+	relayOnce(t, me, 1)
+	if got := p.events(); len(got) != 1 || got[0] != "a2" {
+		t.Fatalf("unexpected published events %v", got)
+	}
+}
+
+func TestRelayLeaseExpires(t *testing.T) {
+	p := &recorder{}
+	me, conn := newTestOutbox(t, &config.Config{PublishTimeout: 25 * time.Millisecond, LeaseTimeout: time.Millisecond}, p)
+	enqueue(t, me, conn, &Event{AggregateType: "order", AggregateID: "a", EventType: "a1"})
+
+	// 模拟领取后崩溃的副本
+	events, _, err := me.claim(context.Background())
+	if err != nil || len(events) != 1 {
+		t.Fatalf("claim: %v, %d events", err, len(events))
+	}
+	relayOnce(t, me, 0)
+	time.Sleep(2 * me.leaseTimeout())
+	relayOnce(t, me, 1)
+}
+
+func TestRelayLoopDrainsAndWaits(t *testing.T) {
+	p := &recorder{}
+	me, conn := newTestOutbox(t, &config.Config{PollInterval: time.Hour}, p)
+	for _, typ := range []string{"a1", "a2", "a3", "a4", "a5"} {
+		enqueue(t, me, conn, &Event{AggregateType: "order", AggregateID: "a", EventType: typ})
+	}
+	var queries atomic.Int64
+	conn.Callback().Query().Before("gorm:query").Register("test:count", func(*gorm.DB) {
+		queries.Add(1)
+	})
+	if err := me.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer me.Close()
+
+	// 每轮只能领取聚合中最早的一个事件，有进展时 relay 立即继续，无需等待 PollInterval
+	deadline := time.Now().Add(2 * time.Second)
+	for len(p.events()) < 5 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := p.events(); len(got) != 5 {
+		t.Fatalf("relay did not drain the aggregate, published %v", got)
+	}
+	// 没有可发送的事件后 relay 停止轮询，等待 PollInterval 或 Notify
+	time.Sleep(50 * time.Millisecond)
+	idle := queries.Load()
+	time.Sleep(100 * time.Millisecond)
+	if got := queries.Load(); got != idle {
+		t.Fatalf("relay kept polling while idle: %d queries", got-idle)
+	}
+}
+
+func TestRelayLoopSleepsOnError(t *testing.T) {
+	p := &recorder{}
+	me, conn := newTestOutbox(t, &config.Config{PollInterval: 50 * time.Millisecond}, p)
+	if err := conn.Migrator().DropTable(defaultTableName); err != nil {
+		t.Fatal(err)
+	}
+	var queries atomic.Int64
+	conn.Callback().Query().Before("gorm:query").Register("test:count", func(*gorm.DB) {
+		queries.Add(1)
+	})
+	if err := me.Start(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	me.Close()
+	// 出错后等待 PollInterval 再重试，200ms 内最多 5 次
+	if got := queries.Load(); got == 0 || got > 5 {
+		t.Fatalf("expected relay to back off on errors, got %d queries", got)
+	}
+}
+
+func TestStartRejectsUnknownServer(t *testing.T) {
+	d, err := db.New(&dbconfig.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	me := New(&config.Config{Server: "main"})
+	WithDb(func() *db.Db { return d })(me)
+	WithPublisher(&recorder{})(me)
+	if err := me.Start(); !errors.Is(err, db.ErrServerNotFound) {
+		t.Fatalf("expected ErrServerNotFound, got %v", err)
+	}
+	// server 在运行时被移除时 relay 返回错误而不是 panic
+	if _, err := me.RelayOnce(context.Background()); !errors.Is(err, db.ErrServerNotFound) {
+		t.Fatalf("expected ErrServerNotFound, got %v", err)
+	}
+	if err := me.Cleanup(context.Background()); !errors.Is(err, db.ErrServerNotFound) {
+		t.Fatalf("expected ErrServerNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/nats-io/nats.go"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	HeaderEventID       = "outbox-event-id"
+	HeaderAggregateType = "outbox-aggregate-type"
+	HeaderAggregateID   = "outbox-aggregate-id"
+)
+
+// Publisher 将事件投递到消息系统。relay 保证至少一次投递，消费方应按事件 ID 去重。
+type Publisher interface {
+	Publish(ctx context.Context, evt *Event) error
+}
+
+type PublisherFunc func(ctx context.Context, evt *Event) error
+
+func (f PublisherFunc) Publish(ctx context.Context, evt *Event) error {
+	return f(ctx, evt)
+}
+
+// AMQPPublisher 以 EventType 为 routing key 发布到 exchange，并等待 broker 的 publisher confirm
+type AMQPPublisher struct {
+	ch       *amqp.Channel
+	exchange string
+}
+
+func NewAMQPPublisher(ch *amqp.Channel, exchange string) (*AMQPPublisher, error) {
+	if err := ch.Confirm(false); err != nil {
+		return nil, fmt.Errorf("confirm: %w", err)
+	}
+	return &AMQPPublisher{
+		ch:       ch,
+		exchange: exchange,
+	}, nil
+}
+
+func (me *AMQPPublisher) Publish(ctx context.Context, evt *Event) error {
+	headers := amqp.Table{
+		HeaderAggregateType: evt.AggregateType,
+		HeaderAggregateID:   evt.AggregateID,
+	}
+	for k, v := range evt.Headers {
+		headers[k] = v
+	}
+	confirm, err := me.ch.PublishWithDeferredConfirmWithContext(ctx, me.exchange, evt.EventType, false, false, amqp.Publishing{
+		Headers:      headers,
+		ContentType:  evt.Headers["content-type"],
+		DeliveryMode: amqp.Persistent,
+		MessageId:    strconv.FormatInt(evt.ID, 10),
+		Timestamp:    evt.CreatedAt,
+		Type:         evt.EventType,
+		Body:         evt.Payload,
+	})
+	if err != nil {
+		return err
+	}
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return fmt.Errorf("event %d nacked by broker", evt.ID)
+	}
+	return nil
+}
+
+// NATSPublisher 发布到 <SubjectPrefix><EventType>。
+// 传入 JetStream 时等待 PubAck 并以事件 ID 作为 Nats-Msg-Id 去重，否则仅 Flush 确认已写出。
+type NATSPublisher struct {
+	conn          *nats.Conn
+	js            nats.JetStreamContext
+	subjectPrefix string
+}
+
+func NewNATSPublisher(conn *nats.Conn, js nats.JetStreamContext, subjectPrefix string) *NATSPublisher {
+	return &NATSPublisher{
+		conn:          conn,
+		js:            js,
+		subjectPrefix: subjectPrefix,
+	}
+}
+
+func (me *NATSPublisher) Publish(ctx context.Context, evt *Event) error {
+	id := strconv.FormatInt(evt.ID, 10)
+	msg := nats.NewMsg(me.subjectPrefix + evt.EventType)
+	msg.Data = evt.Payload
+	msg.Header.Set(HeaderEventID, id)
+	msg.Header.Set(HeaderAggregateType, evt.AggregateType)
+	msg.Header.Set(HeaderAggregateID, evt.AggregateID)
+	for k, v := range evt.Headers {
+		msg.Header.Set(k, v)
+	}
+	if me.js != nil {
+		_, err := me.js.PublishMsg(msg, nats.Context(ctx), nats.MsgId(id))
+		return err
+	}
+	if err := me.conn.PublishMsg(msg); err != nil {
+		return err
+	}
+	return me.conn.FlushWithContext(ctx)
+}
@@ -0,0 +1,359 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultUpsertBatchSize = 500
+
+var (
+	// ErrStaleVersion 在乐观锁更新时版本号已被其他写入修改时返回
+	ErrStaleVersion = errors.New("db: stale version, entity was modified concurrently")
+	ErrInvalidPage  = errors.New("db: page and size must be positive")
+	// ErrMissingSpec 在 DeleteBy 未提供条件时返回
+	ErrMissingSpec = errors.New("db: DeleteBy requires at least one spec")
+	// ErrShardKeyRequired 在分片模型按条件查询但 ctx 上没有分片键时返回
+	ErrShardKeyRequired = errors.New("db: sharded model requires a shard key, use WithShardKey or FanOutFind")
+)
+
+type (
+	// Versioned 模型通过版本字段实现乐观锁，VersionField 返回结构体字段名
+	Versioned interface {
+		VersionField() string
+	}
+	// Repository 封装 T 的通用读写操作，读走从库、写走主库。
+	// T 通常为以值接收者实现 ConnectionName 的结构体；分片模型的写操作按实体路由，
+	// 按条件查询的方法需通过 WithShardKey 指定分片键，否则返回 ErrShardKeyRequired，跨分片查询使用 FanOutFind。
+	Repository[T Model] struct {
+		db *Db
+		tx *gorm.DB
+	}
+	Page[T any] struct {
+		Items []T
+		Total int64
+		Page  int
+		Size  int
+	}
+	// Cursor 为基于列值的游标分页参数，Column 应为唯一且有序的列，默认为主键 id
+	Cursor struct {
+		Column string
+		After  any
+		Limit  int
+		Desc   bool
+	}
+	CursorPage[T any] struct {
+		Items []T
+		// Next 为下一页的 After 值，HasMore 为 false 时无意义
+		Next    any
+		HasMore bool
+	}
+	masterKey   struct{}
+	shardKeyKey struct{}
+)
+
+func NewRepository[T Model](db *Db) *Repository[T] {
+	return &Repository[T]{db: db}
+}
+
+// WithMaster 使 ctx 上的读操作改走主库，用于写后立即读的场景
+func WithMaster(ctx context.Context) context.Context {
+	return context.WithValue(ctx, masterKey{}, true)
+}
+
+// WithShardKey 为 ctx 上分片模型的 Get、Find、Count 等按条件执行的操作指定分片键
+func WithShardKey(ctx context.Context, key any) context.Context {
+	return context.WithValue(ctx, shardKeyKey{}, key)
+}
+
+// WithTx 返回在事务 tx 中执行所有操作的 Repository
+func (me *Repository[T]) WithTx(tx *gorm.DB) *Repository[T] {
+	return &Repository[T]{db: me.db, tx: tx}
+}
+
+// modelOf 优先使用实体指针作为 gorm Model，使自增主键等回写到调用方的实体上
+func modelOf[T Model](entity *T) Model {
+	if m, ok := any(entity).(Model); ok {
+		return m
+	}
+	return *entity
+}
+
+func (me *Repository[T]) reader(ctx context.Context, m Model) *gorm.DB {
+	if me.tx != nil {
+		return me.tx.WithContext(ctx).Model(m)
+	}
+	if master, _ := ctx.Value(masterKey{}).(bool); master {
+		return me.db.WriteModel(m).WithContext(ctx)
+	}
+	return me.db.ReadModel(m).WithContext(ctx)
+}
+
+func (me *Repository[T]) writer(ctx context.Context, m Model) *gorm.DB {
+	if me.tx != nil {
+		return me.tx.WithContext(ctx).Model(m)
+	}
+	return me.db.WriteModel(m).WithContext(ctx)
+}
+
+// query 返回按条件执行的操作使用的连接。分片模型无法从条件推断分片，按 ctx 上的分片键路由；
+// 没有分片键时返回的 tx 带有 ErrShardKeyRequired，而不是落到零值分片键所在的分片。
+func (me *Repository[T]) query(ctx context.Context, write bool) *gorm.DB {
+	m := modelOf(new(T))
+	sm, ok := m.(ShardedModel)
+	if !ok || me.tx != nil || !me.db.isSharded(sm) {
+		if write {
+			return me.writer(ctx, m)
+		}
+		return me.reader(ctx, m)
+	}
+	conn := me.db.Read
+	if master, _ := ctx.Value(masterKey{}).(bool); master || write {
+		conn = me.db.Write
+	}
+	key := ctx.Value(shardKeyKey{})
+	if key == nil {
+		s := me.db.getSharding(sm.ConnectionName())
+		tx := conn(s.config.Servers[0]).Model(m).WithContext(ctx)
+		tx.AddError(ErrShardKeyRequired)
+		return tx
+	}
+	return me.db.shardModel(sm, key, conn).WithContext(ctx)
+}
+
+func (me *Repository[T]) Get(ctx context.Context, id any) (*T, error) {
+	entity := new(T)
+	err := me.query(ctx, false).Where(clause.Eq{Column: clause.PrimaryColumn, Value: id}).First(entity).Error
+	if err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+
+func (me *Repository[T]) First(ctx context.Context, specs ...Spec) (*T, error) {
+	entity := new(T)
+	if err := applySpecs(me.query(ctx, false), specs).First(entity).Error; err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+
+func (me *Repository[T]) Find(ctx context.Context, specs ...Spec) ([]T, error) {
+	var reply []T
+	err := applySpecs(me.query(ctx, false), specs).Find(&reply).Error
+	return reply, err
+}
+
+func (me *Repository[T]) Count(ctx context.Context, specs ...Spec) (int64, error) {
+	var count int64
+	err := applySpecs(me.query(ctx, false), specs).Count(&count).Error
+	return count, err
+}
+
+// Paginate 按页码分页，page 从 1 开始
+func (me *Repository[T]) Paginate(ctx context.Context, page, size int, specs ...Spec) (*Page[T], error) {
+	if page <= 0 || size <= 0 {
+		return nil, ErrInvalidPage
+	}
+	reply := &Page[T]{Page: page, Size: size}
+	var err error
+	if reply.Total, err = me.Count(ctx, specs...); err != nil {
+		return nil, err
+	}
+	if int64((page-1)*size) >= reply.Total {
+		return reply, nil
+	}
+	err = applySpecs(me.query(ctx, false), specs).
+		Offset((page - 1) * size).
+		Limit(size).
+		Find(&reply.Items).
+		Error
+	return reply, err
+}
+
+// CursorPaginate 按列值游标分页，大页码时性能不随偏移量下降
+func (me *Repository[T]) CursorPaginate(ctx context.Context, cursor Cursor, specs ...Spec) (*CursorPage[T], error) {
+	if cursor.Limit <= 0 {
+		return nil, ErrInvalidPage
+	}
+	if cursor.Column == "" {
+		cursor.Column = "id"
+	}
+	tx := applySpecs(me.query(ctx, false), specs)
+	column := clause.Column{Name: cursor.Column}
+	if cursor.After != nil {
+		if cursor.Desc {
+			tx = tx.Where(clause.Lt{Column: column, Value: cursor.After})
+		} else {
+			tx = tx.Where(clause.Gt{Column: column, Value: cursor.After})
+		}
+	}
+	reply := &CursorPage[T]{}
+	err := tx.Order(clause.OrderByColumn{Column: column, Desc: cursor.Desc}).
+		Limit(cursor.Limit + 1).
+		Find(&reply.Items).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	if len(reply.Items) > cursor.Limit {
+		reply.Items = reply.Items[:cursor.Limit]
+		reply.HasMore = true
+	}
+	if len(reply.Items) > 0 {
+		if reply.Next, err = me.columnValue(tx, &reply.Items[len(reply.Items)-1], cursor.Column); err != nil {
+			return nil, err
+		}
+	}
+	return reply, nil
+}
+
+func (me *Repository[T]) columnValue(tx *gorm.DB, entity *T, column string) (any, error) {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(entity); err != nil {
+		return nil, err
+	}
+	field := stmt.Schema.LookUpField(column)
+	if field == nil {
+		return nil, errors.New("db: unknown cursor column " + column)
+	}
+	value, _ := field.ValueOf(tx.Statement.Context, reflect.ValueOf(entity).Elem())
+	return value, nil
+}
+
+func (me *Repository[T]) Create(ctx context.Context, entity *T) error {
+	return me.writer(ctx, modelOf(entity)).Create(entity).Error
+}
+
+// Update 更新实体的所有字段。Versioned 模型只在版本号未变化时更新并使版本号加一，
+// 否则返回 ErrStaleVersion 且实体的版本号保持不变。
+func (me *Repository[T]) Update(ctx context.Context, entity *T) error {
+	tx := me.writer(ctx, modelOf(entity))
+	versioned, ok := any(entity).(Versioned)
+	if !ok {
+		return tx.Select("*").Updates(entity).Error
+	}
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(entity); err != nil {
+		return err
+	}
+	field := stmt.Schema.LookUpField(versioned.VersionField())
+	if field == nil {
+		return errors.New("db: unknown version field " + versioned.VersionField())
+	}
+	rv := field.ReflectValueOf(ctx, reflect.ValueOf(entity).Elem())
+	current := rv.Interface()
+	switch {
+	case rv.CanInt():
+		rv.SetInt(rv.Int() + 1)
+	case rv.CanUint():
+		rv.SetUint(rv.Uint() + 1)
+	default:
+		return errors.New("db: version field " + versioned.VersionField() + " must be an integer")
+	}
+	result := tx.Where(clause.Eq{Column: clause.Column{Name: field.DBName}, Value: current}).Select("*").Updates(entity)
+	if result.Error == nil && result.RowsAffected == 0 {
+		result.Error = ErrStaleVersion
+	}
+	if result.Error != nil {
+		rv.Set(reflect.ValueOf(current))
+	}
+	return result.Error
+}
+
+// UpdateFields 只更新 fields 中的列，不处理版本号
+func (me *Repository[T]) UpdateFields(ctx context.Context, entity *T, fields map[string]any) error {
+	return me.writer(ctx, modelOf(entity)).Updates(fields).Error
+}
+
+// Delete 对包含 gorm.DeletedAt 字段的模型执行软删除，否则物理删除
+func (me *Repository[T]) Delete(ctx context.Context, entity *T) error {
+	return me.writer(ctx, modelOf(entity)).Delete(entity).Error
+}
+
+func (me *Repository[T]) HardDelete(ctx context.Context, entity *T) error {
+	return me.writer(ctx, modelOf(entity)).Unscoped().Delete(entity).Error
+}
+
+// DeleteBy 删除满足条件的记录，未提供条件时拒绝执行以免误删全表
+func (me *Repository[T]) DeleteBy(ctx context.Context, specs ...Spec) (int64, error) {
+	if len(specs) == 0 {
+		return 0, ErrMissingSpec
+	}
+	result := applySpecs(me.query(ctx, true), specs).Delete(new(T))
+	return result.RowsAffected, result.Error
+}
+
+// Upsert 分批插入，与 conflictColumns 冲突时更新 updateColumns，updateColumns 为空时更新全部列。
+// 分片模型按分片键的落点分组后分别写入，跨分片的写入不具备原子性。
+func (me *Repository[T]) Upsert(ctx context.Context, entities []T, conflictColumns []string, updateColumns ...string) error {
+	if len(entities) == 0 {
+		return nil
+	}
+	onConflict := clause.OnConflict{UpdateAll: len(updateColumns) == 0}
+	for _, column := range conflictColumns {
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: column})
+	}
+	if len(updateColumns) > 0 {
+		onConflict.DoUpdates = clause.AssignmentColumns(updateColumns)
+	}
+	groups, err := me.shardGroups(entities)
+	if err != nil {
+		return err
+	}
+	if len(groups) == 1 {
+		return me.writer(ctx, modelOf(&entities[0])).Clauses(onConflict).CreateInBatches(&entities, defaultUpsertBatchSize).Error
+	}
+	for _, group := range groups {
+		batch := make([]T, len(group))
+		for i, idx := range group {
+			batch[i] = entities[idx]
+		}
+		err := me.writer(ctx, modelOf(&batch[0])).Clauses(onConflict).CreateInBatches(&batch, defaultUpsertBatchSize).Error
+		// 回写数据库生成的自增主键等字段
+		for i, idx := range group {
+			entities[idx] = batch[i]
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shardGroups 按分片序号对实体分组，返回各组实体在 entities 中的下标。
+// 非分片模型以及事务中的仓储只有一组。
+func (me *Repository[T]) shardGroups(entities []T) ([][]int, error) {
+	sm, ok := modelOf(&entities[0]).(ShardedModel)
+	if !ok || me.tx != nil || !me.db.isSharded(sm) {
+		all := make([]int, len(entities))
+		for i := range all {
+			all[i] = i
+		}
+		return [][]int{all}, nil
+	}
+	s, err := me.db.lookupSharding(sm.ConnectionName())
+	if err != nil {
+		return nil, err
+	}
+	var groups [][]int
+	positions := map[int]int{}
+	for i := range entities {
+		shard, err := s.resolve(modelOf(&entities[i]).(ShardedModel).ShardKey())
+		if err != nil {
+			return nil, err
+		}
+		pos, ok := positions[shard.Index]
+		if !ok {
+			pos = len(groups)
+			positions[shard.Index] = pos
+			groups = append(groups, nil)
+		}
+		groups[pos] = append(groups[pos], i)
+	}
+	return groups, nil
+}
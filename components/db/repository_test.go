@@ -0,0 +1,162 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/puper/leo/components/db/config"
+	"gorm.io/gorm/logger"
+)
+
+type account struct {
+	ID      int64
+	Name    string
+	Version int64
+}
+
+func (account) ConnectionName() string { return "main" }
+
+func (account) VersionField() string { return "Version" }
+
+type ledger struct {
+	ID      int64
+	Version uint32
+}
+
+func (ledger) ConnectionName() string { return "main" }
+
+func (ledger) VersionField() string { return "Version" }
+
+type sqlRecorder struct {
+	logger.Interface
+	sqls []string
+}
+
+func (me *sqlRecorder) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	sql, _ := fc()
+	me.sqls = append(me.sqls, sql)
+}
+
+func TestRepositoryBuildsQueries(t *testing.T) {
+	me := newDryRunDb(t, &config.Config{}, "main")
+	recorder := &sqlRecorder{Interface: logger.Discard}
+	me.wrappers["main"].master.Logger = recorder
+	repo := NewRepository[account](me)
+	ctx := context.Background()
+
+	if _, err := repo.Find(ctx, Eq("name", "a"), Or(Eq("id", 1), In("id", 2, 3)), OrderBy("id", true)); err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if _, err := repo.CursorPaginate(ctx, Cursor{After: 10, Limit: 20}); err != nil {
+		t.Fatalf("CursorPaginate: %v", err)
+	}
+
+	entity := &account{ID: 7, Name: "b", Version: 3}
+	if err := repo.Update(ctx, entity); !errors.Is(err, ErrStaleVersion) {
+		t.Fatalf("dry run update should report stale version, got %v", err)
+	}
+	if entity.Version != 3 {
+		t.Errorf("version should be restored after stale update, got %d", entity.Version)
+	}
+
+	// 未提供条件时拒绝删除全表
+	if _, err := repo.DeleteBy(ctx); !errors.Is(err, ErrMissingSpec) {
+		t.Fatalf("DeleteBy without specs should fail, got %v", err)
+	}
+	if _, err := repo.DeleteBy(ctx, Eq("name", "c")); err != nil {
+		t.Fatalf("DeleteBy: %v", err)
+	}
+
+	// 无符号版本号
+	unsigned := &ledger{ID: 8, Version: 5}
+	if err := NewRepository[ledger](me).Update(ctx, unsigned); !errors.Is(err, ErrStaleVersion) {
+		t.Fatalf("dry run update should report stale version, got %v", err)
+	}
+	if unsigned.Version != 5 {
+		t.Errorf("version should be restored after stale update, got %d", unsigned.Version)
+	}
+
+	want := []string{
+		"SELECT * FROM `accounts` WHERE `name` = 'a' AND (`id` = 1 OR `id` IN (2,3)) ORDER BY `id` DESC",
+		"SELECT * FROM `accounts` WHERE `id` > 10 ORDER BY `id` LIMIT 21",
+		"UPDATE `accounts` SET `name`='b',`version`=4 WHERE `version` = 3 AND `id` = 7",
+		"DELETE FROM `accounts` WHERE `name` = 'c'",
+		"UPDATE `ledgers` SET `version`=6 WHERE `version` = 5 AND `id` = 8",
+	}
+	if len(recorder.sqls) != len(want) {
+		t.Fatalf("got sqls %q", recorder.sqls)
+	}
+	for i := range want {
+		if strings.TrimSpace(recorder.sqls[i]) != want[i] {
+			t.Errorf("sql %d:\n got  %s\n want %s", i, recorder.sqls[i], want[i])
+		}
+	}
+}
+
+func TestRepositoryUpsertRoutesEachShard(t *testing.T) {
+	me := newDryRunDb(t, &config.Config{
+		Shards: map[string]*config.ShardConfig{
+			"orders": {Strategy: ShardStrategyHash, Servers: []string{"s0", "s1"}, TableCount: 2},
+		},
+	}, "s0", "s1")
+	recorders := map[string]*sqlRecorder{}
+	for _, name := range []string{"s0", "s1"} {
+		recorders[name] = &sqlRecorder{Interface: logger.Discard}
+		me.wrappers[name].master.Logger = recorders[name]
+	}
+	repo := NewRepository[shardedOrder](me)
+
+	// 用户 3、7 落在 s1 的 _1 表，用户 0 落在 s0 的 _0 表
+	orders := []shardedOrder{{ID: 1, UserID: 3}, {ID: 2, UserID: 0}, {ID: 3, UserID: 7}}
+	if err := repo.Upsert(context.Background(), orders, []string{"id"}); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+
+	want := map[string]string{
+		"s0": "INSERT INTO `sharded_orders_0` (`user_id`,`id`) VALUES (0,2) ON DUPLICATE KEY UPDATE `user_id`=VALUES(`user_id`)",
+		"s1": "INSERT INTO `sharded_orders_1` (`user_id`,`id`) VALUES (3,1),(7,3) ON DUPLICATE KEY UPDATE `user_id`=VALUES(`user_id`)",
+	}
+	for name, sql := range want {
+		if sqls := recorders[name].sqls; len(sqls) != 1 || strings.TrimSpace(sqls[0]) != sql {
+			t.Errorf("%s:\n got  %q\n want %s", name, sqls, sql)
+		}
+	}
+}
+
+func TestRepositoryShardedQueryNeedsShardKey(t *testing.T) {
+	me := newDryRunDb(t, &config.Config{
+		Shards: map[string]*config.ShardConfig{
+			"orders": {Strategy: ShardStrategyHash, Servers: []string{"s0", "s1"}, TableCount: 2},
+		},
+	}, "s0", "s1")
+	recorders := map[string]*sqlRecorder{}
+	for _, name := range []string{"s0", "s1"} {
+		recorders[name] = &sqlRecorder{Interface: logger.Discard}
+		me.wrappers[name].master.Logger = recorders[name]
+	}
+	repo := NewRepository[shardedOrder](me)
+	ctx := context.Background()
+
+	// 没有分片键时不会落到零值分片键所在的分片
+	if _, err := repo.Get(ctx, 1); !errors.Is(err, ErrShardKeyRequired) {
+		t.Fatalf("Get: expected ErrShardKeyRequired, got %v", err)
+	}
+	if _, err := repo.Find(ctx, Eq("id", 1)); !errors.Is(err, ErrShardKeyRequired) {
+		t.Fatalf("Find: expected ErrShardKeyRequired, got %v", err)
+	}
+	if len(recorders["s0"].sqls) != 0 || len(recorders["s1"].sqls) != 0 {
+		t.Fatalf("queries without shard key were executed: %q %q", recorders["s0"].sqls, recorders["s1"].sqls)
+	}
+
+	// 用户 3 落在 s1 的 _1 表
+	if _, err := repo.Find(WithShardKey(ctx, int64(3)), Eq("user_id", 3)); err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	want := "SELECT * FROM `sharded_orders_1` WHERE `user_id` = 3"
+	if sqls := recorders["s1"].sqls; len(sqls) != 1 || strings.TrimSpace(sqls[0]) != want {
+		t.Errorf("got %q, want %s", sqls, want)
+	}
+}
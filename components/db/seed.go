@@ -0,0 +1,155 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.yaml.in/yaml/v3"
+	"gorm.io/gorm"
+)
+
+const seedDirName = "seeds"
+
+// Seed 是一个种子文件：.sql 文件按语句执行，.yaml/.yml/.json 文件为若干行数据。
+// 数据文件命名为 [<序号>_]<表名>.<ext>，序号用于控制有外键依赖时的插入顺序。
+type Seed struct {
+	Name     string
+	Table    string
+	SQL      string
+	Rows     []map[string]any
+	Checksum string
+}
+
+// Apply 在 tx 上执行种子
+func (me *Seed) Apply(tx *gorm.DB) error {
+	if me.Table == "" {
+		return execStatements(tx, me.SQL)
+	}
+	if len(me.Rows) == 0 {
+		return nil
+	}
+	return tx.Session(&gorm.Session{}).Table(me.Table).Create(&me.Rows).Error
+}
+
+// LoadSeeds 读取 <Root>/<db>/seeds 下的种子文件，按文件名排序
+func LoadSeeds(seedFs fs.FS, opts ...LoadOption) (map[string][]*Seed, error) {
+	options := &LoadOptions{Root: defaultMigrateRoot}
+	for _, opt := range opts {
+		opt(options)
+	}
+	reply := map[string][]*Seed{}
+	entries, err := fs.ReadDir(seedFs, options.Root)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return reply, nil
+		}
+		return nil, errors.WithMessagef(err, "read dir %s", options.Root)
+	}
+	for _, f := range entries {
+		if !f.IsDir() || strings.HasPrefix(f.Name(), ".") {
+			continue
+		}
+		dir := path.Join(options.Root, f.Name(), seedDirName)
+		if _, err := fs.Stat(seedFs, dir); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		seeds, err := LoadSeedDir(seedFs, dir)
+		if err != nil {
+			return nil, err
+		}
+		if len(seeds) > 0 {
+			reply[f.Name()] = seeds
+		}
+	}
+	return reply, nil
+}
+
+// LoadSeedDir 读取 dir 下的种子文件，测试夹具也使用此函数加载任意目录
+func LoadSeedDir(seedFs fs.FS, dir string) ([]*Seed, error) {
+	entries, err := fs.ReadDir(seedFs, dir)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "read dir %s", dir)
+	}
+	var seeds []*Seed
+	for _, f := range entries {
+		if f.IsDir() || strings.HasPrefix(f.Name(), ".") {
+			continue
+		}
+		seed, err := loadSeed(seedFs, path.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if seed != nil {
+			seeds = append(seeds, seed)
+		}
+	}
+	sort.SliceStable(seeds, func(i, j int) bool {
+		return migrationIDLess(seeds[i].Name, seeds[j].Name)
+	})
+	return seeds, nil
+}
+
+func loadSeed(seedFs fs.FS, file string) (*Seed, error) {
+	ext := path.Ext(file)
+	name := strings.TrimSuffix(path.Base(file), ext)
+	var decode func([]byte, any) error
+	switch ext {
+	case ".sql":
+	case ".json":
+		decode = json.Unmarshal
+	case ".yaml", ".yml":
+		decode = yaml.Unmarshal
+	default:
+		log.Printf("db seed: ignore %s, expect .sql, .yaml, .yml or .json", file)
+		return nil, nil
+	}
+	data, err := fs.ReadFile(seedFs, file)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "read file %s", file)
+	}
+	seed := &Seed{Name: name, Checksum: Checksum(data)}
+	if decode == nil {
+		seed.SQL = string(data)
+		return seed, nil
+	}
+	seed.Table = name
+	if prefix, table, ok := strings.Cut(name, "_"); ok && isDigits(prefix) {
+		seed.Table = table
+	}
+	if err := decode(data, &seed.Rows); err != nil {
+		return nil, errors.WithMessagef(err, "decode %s", file)
+	}
+	return seed, nil
+}
+
+// seedMigrations 将种子包装为迁移，复用迁移的加锁、校验和与只执行一次的语义
+func seedMigrations(seeds []*Seed) []*Migration {
+	ms := make([]*Migration, 0, len(seeds))
+	for _, seed := range seeds {
+		ms = append(ms, &Migration{
+			ID:       seed.Name,
+			Migrate:  seed.Apply,
+			Checksum: seed.Checksum,
+		})
+	}
+	return ms
+}
+
+// seedOptions 种子的执行记录保存在独立的 <TableName>_seeds 表中
+func seedOptions(migrate *Options) *Options {
+	options := &Options{}
+	if migrate != nil {
+		*options = *migrate
+	}
+	if options.TableName == "" {
+		options.TableName = DefaultOptions.TableName
+	}
+	options.TableName = fmt.Sprintf("%s_%s", options.TableName, seedDirName)
+	return options
+}
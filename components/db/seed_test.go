@@ -0,0 +1,91 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/puper/leo/components/db/config"
+	"gorm.io/gorm/logger"
+)
+
+func TestLoadSeeds(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sqls/main/1_init.up.sql":         {Data: []byte("CREATE TABLE users (id int)")},
+		"sqls/main/seeds/2_orders.json":   {Data: []byte(`[{"id": 1, "user_id": 1}]`)},
+		"sqls/main/seeds/1_users.yaml":    {Data: []byte("- id: 1\n  name: a\n- id: 2\n  name: b\n")},
+		"sqls/main/seeds/10_settings.sql": {Data: []byte("INSERT INTO settings VALUES (1); INSERT INTO settings VALUES (2);")},
+		"sqls/main/seeds/README.md":       {Data: []byte("ignored")},
+		"sqls/other/1_init.up.sql":        {Data: []byte("SELECT 1")},
+	}
+	seeds, err := LoadSeeds(fsys)
+	if err != nil {
+		t.Fatalf("LoadSeeds: %v", err)
+	}
+	if _, ok := seeds["other"]; ok {
+		t.Fatalf("unexpected seeds for other")
+	}
+	ss := seeds["main"]
+	var names, tables []string
+	for _, s := range ss {
+		names = append(names, s.Name)
+		tables = append(tables, s.Table)
+	}
+	if strings.Join(names, ",") != "1_users,2_orders,10_settings" {
+		t.Fatalf("names = %v", names)
+	}
+	if strings.Join(tables, ",") != "users,orders," {
+		t.Fatalf("tables = %v", tables)
+	}
+	if len(ss[0].Rows) != 2 || ss[0].Rows[1]["name"] != "b" {
+		t.Fatalf("rows = %v", ss[0].Rows)
+	}
+
+	migrates, err := LoadMigrates(fsys)
+	if err != nil {
+		t.Fatalf("LoadMigrates: %v", err)
+	}
+	if len(migrates["main"]) != 1 {
+		t.Fatalf("seeds must not be loaded as migrations: %v", migrates["main"])
+	}
+}
+
+func TestSeedApply(t *testing.T) {
+	me := newDryRunDb(t, &config.Config{}, "main")
+	recorder := &sqlRecorder{Interface: logger.Discard}
+	conn := me.wrappers["main"].master
+	conn.Logger = recorder
+	seeds, err := LoadSeedDir(fstest.MapFS{
+		"users.json":   {Data: []byte(`[{"id": 1}, {"id": 2}]`)},
+		"settings.sql": {Data: []byte("INSERT INTO settings VALUES (1); INSERT INTO settings VALUES (2);")},
+	}, ".")
+	if err != nil {
+		t.Fatalf("LoadSeedDir: %v", err)
+	}
+	for _, s := range seeds {
+		if err := s.Apply(conn); err != nil {
+			t.Fatalf("Apply %s: %v", s.Name, err)
+		}
+	}
+	want := []string{
+		"INSERT INTO settings VALUES (1)",
+		"INSERT INTO settings VALUES (2)",
+		"INSERT INTO `users` (`id`) VALUES (1),(2)",
+	}
+	if strings.Join(recorder.sqls, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("sqls = %q", recorder.sqls)
+	}
+}
+
+func TestSeedOptions(t *testing.T) {
+	migrate := &Options{TableName: "schema_migrations"}
+	if got := seedOptions(migrate).TableName; got != "schema_migrations_seeds" {
+		t.Fatalf("TableName = %s", got)
+	}
+	if migrate.TableName != "schema_migrations" {
+		t.Fatalf("migrate options modified")
+	}
+	if got := seedOptions(nil).TableName; got != "migrations_seeds" {
+		t.Fatalf("TableName = %s", got)
+	}
+}
@@ -0,0 +1,272 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sync"
+
+	"github.com/puper/leo/components/db/config"
+	"github.com/spf13/cast"
+	"gorm.io/gorm"
+)
+
+const (
+	ShardStrategyHash   = "hash"
+	ShardStrategyRange  = "range"
+	ShardStrategyLookup = "lookup"
+)
+
+var (
+	ErrShardNotFound = errors.New("db: no shard matches the shard key")
+	// ErrShardingNotFound 分片名未在 config.Shards 中配置
+	ErrShardingNotFound = errors.New("db: sharding not configured")
+)
+
+type (
+	// ShardedModel 的 ConnectionName() 返回 config.Shards 中的分片名
+	ShardedModel interface {
+		Model
+		ShardKey() any
+	}
+	// Shard 是分片键解析后的落点
+	Shard struct {
+		Index  int
+		Server string
+		// Suffix 为空表示不分表
+		Suffix string
+	}
+	sharding struct {
+		config *config.ShardConfig
+		count  int
+	}
+)
+
+func newSharding(name string, cfg *config.ShardConfig, servers map[string]bool) (*sharding, error) {
+	if len(cfg.Servers) == 0 {
+		return nil, fmt.Errorf("shard %s: no servers", name)
+	}
+	for _, server := range cfg.Servers {
+		if !servers[server] {
+			return nil, fmt.Errorf("shard %s: server `%s` not configured", name, server)
+		}
+	}
+	me := &sharding{
+		config: cfg,
+		count:  len(cfg.Servers) * max(cfg.TableCount, 1),
+	}
+	switch cfg.Strategy {
+	case ShardStrategyHash:
+	case ShardStrategyRange:
+		for _, r := range cfg.Ranges {
+			if r.Shard < 0 || r.Shard >= me.count {
+				return nil, fmt.Errorf("shard %s: range [%d, %d) points to invalid shard %d", name, r.Min, r.Max, r.Shard)
+			}
+		}
+	case ShardStrategyLookup:
+		for key, shard := range cfg.Lookup {
+			if shard < 0 || shard >= me.count {
+				return nil, fmt.Errorf("shard %s: lookup `%s` points to invalid shard %d", name, key, shard)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("shard %s: unknown strategy `%s`", name, cfg.Strategy)
+	}
+	return me, nil
+}
+
+func (me *sharding) resolve(key any) (*Shard, error) {
+	var index int
+	switch me.config.Strategy {
+	case ShardStrategyHash:
+		index = me.hash(key)
+	case ShardStrategyRange:
+		k, err := cast.ToInt64E(key)
+		if err != nil {
+			return nil, fmt.Errorf("range shard key: %w", err)
+		}
+		index = -1
+		for _, r := range me.config.Ranges {
+			if k >= r.Min && k < r.Max {
+				index = r.Shard
+				break
+			}
+		}
+		if index < 0 {
+			return nil, fmt.Errorf("%w: %v", ErrShardNotFound, key)
+		}
+	case ShardStrategyLookup:
+		shard, ok := me.config.Lookup[cast.ToString(key)]
+		if !ok {
+			return nil, fmt.Errorf("%w: %v", ErrShardNotFound, key)
+		}
+		index = shard
+	}
+	return me.shard(index), nil
+}
+
+// hash 对整数键直接取模，使 id 连续的数据均匀分布且便于人工定位；其余类型使用 fnv
+func (me *sharding) hash(key any) int {
+	v := reflect.ValueOf(key)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(uint64(v.Int()) % uint64(me.count))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(v.Uint() % uint64(me.count))
+	}
+	h := fnv.New32a()
+	h.Write([]byte(cast.ToString(key)))
+	return int(h.Sum32() % uint32(me.count))
+}
+
+func (me *sharding) shard(index int) *Shard {
+	tableCount := max(me.config.TableCount, 1)
+	reply := &Shard{
+		Index:  index,
+		Server: me.config.Servers[index/tableCount],
+	}
+	if me.config.TableCount > 1 {
+		reply.Suffix = fmt.Sprintf("_%d", index%tableCount)
+	}
+	return reply
+}
+
+func (me *sharding) all() []*Shard {
+	shards := make([]*Shard, me.count)
+	for i := range shards {
+		shards[i] = me.shard(i)
+	}
+	return shards
+}
+
+func (me *Db) lookupSharding(name string) (*sharding, error) {
+	s, ok := me.shardings[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrShardingNotFound, name)
+	}
+	return s, nil
+}
+
+func (me *Db) getSharding(name string) *sharding {
+	s, err := me.lookupSharding(name)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// ResolveShard 返回分片键在分片 name 中的落点，name 未配置时返回 ErrShardingNotFound
+func (me *Db) ResolveShard(name string, key any) (*Shard, error) {
+	s, err := me.lookupSharding(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.resolve(key)
+}
+
+// ShardWrite 与 Write 一致，分片未配置时 panic，分片名来自外部输入时应先调用 ResolveShard
+func (me *Db) ShardWrite(m ShardedModel) *gorm.DB {
+	return me.shardModel(m, m.ShardKey(), me.Write)
+}
+
+// ShardRead 与 Read 一致，分片未配置时 panic
+func (me *Db) ShardRead(m ShardedModel) *gorm.DB {
+	return me.shardModel(m, m.ShardKey(), me.Read)
+}
+
+// shardModel 按 key 而不是 m 的分片键路由，用于按条件查询时由调用方指定分片键
+func (me *Db) shardModel(m ShardedModel, key any, conn func(string) *gorm.DB) *gorm.DB {
+	s := me.getSharding(m.ConnectionName())
+	shard, err := s.resolve(key)
+	if err != nil {
+		tx := conn(s.config.Servers[0]).Model(m)
+		tx.AddError(err)
+		return tx
+	}
+	return shardTable(conn(shard.Server).Model(m), m, shard)
+}
+
+// shardTable 为分表分片设置实际表名 <table><suffix>
+func shardTable(tx *gorm.DB, m any, shard *Shard) *gorm.DB {
+	if shard.Suffix == "" {
+		return tx
+	}
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(m); err != nil {
+		tx.AddError(err)
+		return tx
+	}
+	return tx.Table(stmt.Table + shard.Suffix)
+}
+
+// ShardEach 在分片 name 的所有分片上并发执行 f，f 收到的 tx 已设置好分表表名。
+// 任一分片出错时返回所有错误的合并，name 未配置时返回 ErrShardingNotFound。
+func (me *Db) ShardEach(name string, m any, read bool, f func(shard *Shard, tx *gorm.DB) error) error {
+	s, err := me.lookupSharding(name)
+	if err != nil {
+		return err
+	}
+	conn := me.Write
+	if read {
+		conn = me.Read
+	}
+	shards := s.all()
+	errs := make([]error, len(shards))
+	var wg sync.WaitGroup
+	for i, shard := range shards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tx := shardTable(conn(shard.Server).Model(m), m, shard)
+			if err := f(shard, tx); err != nil {
+				errs[i] = fmt.Errorf("shard %d: %w", shard.Index, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// FanOutFind 在所有分片上执行 scope 限定的查询并合并结果，结果顺序按分片序号排列
+func FanOutFind[T any](me *Db, name string, scope func(*gorm.DB) *gorm.DB) ([]T, error) {
+	s, err := me.lookupSharding(name)
+	if err != nil {
+		return nil, err
+	}
+	parts := make([][]T, s.count)
+	err = me.ShardEach(name, new(T), true, func(shard *Shard, tx *gorm.DB) error {
+		if scope != nil {
+			tx = scope(tx)
+		}
+		return tx.Find(&parts[shard.Index]).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	var reply []T
+	for _, part := range parts {
+		reply = append(reply, part...)
+	}
+	return reply, nil
+}
+
+// FanOutCount 汇总所有分片上 scope 限定的记录数
+func (me *Db) FanOutCount(name string, m any, scope func(*gorm.DB) *gorm.DB) (int64, error) {
+	s, err := me.lookupSharding(name)
+	if err != nil {
+		return 0, err
+	}
+	counts := make([]int64, s.count)
+	err = me.ShardEach(name, m, true, func(shard *Shard, tx *gorm.DB) error {
+		if scope != nil {
+			tx = scope(tx)
+		}
+		return tx.Count(&counts[shard.Index]).Error
+	})
+	var total int64
+	for _, c := range counts {
+		total += c
+	}
+	return total, err
+}
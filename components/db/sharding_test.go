@@ -0,0 +1,146 @@
+package db
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/puper/leo/components/db/config"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+type shardedOrder struct {
+	ID     int64
+	UserID int64
+}
+
+func (shardedOrder) ConnectionName() string { return "orders" }
+
+func (me shardedOrder) ShardKey() any { return me.UserID }
+
+func newDryRunDb(t *testing.T, cfg *config.Config, names ...string) *Db {
+	t.Helper()
+	me := &Db{config: cfg, wrappers: map[string]*Wrapper{}, shardings: map[string]*sharding{}}
+	servers := map[string]bool{}
+	for _, name := range names {
+		conn, err := gorm.Open(
+			mysql.New(mysql.Config{DSN: "u:p@tcp(127.0.0.1:1)/" + name, SkipInitializeWithVersion: true}),
+			&gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true},
+		)
+		if err != nil {
+			t.Fatalf("gorm.Open: %v", err)
+		}
+		me.wrappers[name] = &Wrapper{master: conn}
+		servers[name] = true
+	}
+	for name, shardCfg := range cfg.Shards {
+		s, err := newSharding(name, shardCfg, servers)
+		if err != nil {
+			t.Fatalf("newSharding: %v", err)
+		}
+		me.shardings[name] = s
+	}
+	return me
+}
+
+func TestShardingResolve(t *testing.T) {
+	servers := map[string]bool{"s0": true, "s1": true}
+	hash, err := newSharding("h", &config.ShardConfig{Strategy: ShardStrategyHash, Servers: []string{"s0", "s1"}, TableCount: 4}, servers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shard, _ := hash.resolve(int64(13)); shard.Index != 5 || shard.Server != "s1" || shard.Suffix != "_1" {
+		t.Errorf("hash: unexpected shard %+v", shard)
+	}
+	if shard, _ := hash.resolve(-3); shard.Index < 0 || shard.Index >= 8 {
+		t.Errorf("hash: negative key out of range %+v", shard)
+	}
+
+	rng, err := newSharding("r", &config.ShardConfig{
+		Strategy: ShardStrategyRange,
+		Servers:  []string{"s0", "s1"},
+		Ranges:   []config.ShardRange{{Min: 0, Max: 100, Shard: 0}, {Min: 100, Max: 200, Shard: 1}},
+	}, servers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shard, _ := rng.resolve("150"); shard.Server != "s1" || shard.Suffix != "" {
+		t.Errorf("range: unexpected shard %+v", shard)
+	}
+	if _, err := rng.resolve(200); !errors.Is(err, ErrShardNotFound) {
+		t.Errorf("range: expected ErrShardNotFound, got %v", err)
+	}
+
+	lookup, err := newSharding("l", &config.ShardConfig{
+		Strategy: ShardStrategyLookup,
+		Servers:  []string{"s0", "s1"},
+		Lookup:   map[string]int{"cn": 1},
+	}, servers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shard, _ := lookup.resolve("cn"); shard.Server != "s1" {
+		t.Errorf("lookup: unexpected shard %+v", shard)
+	}
+
+	if _, err := newSharding("x", &config.ShardConfig{Strategy: ShardStrategyHash, Servers: []string{"s9"}}, servers); err == nil {
+		t.Error("unknown server should be rejected")
+	}
+}
+
+func TestShardWriteRoutesTable(t *testing.T) {
+	me := newDryRunDb(t, &config.Config{
+		Shards: map[string]*config.ShardConfig{
+			"orders": {Strategy: ShardStrategyHash, Servers: []string{"s0", "s1"}, TableCount: 2},
+		},
+	}, "s0", "s1")
+
+	tx := me.WriteModel(&shardedOrder{UserID: 3}).Where("id = ?", 1).Find(&[]shardedOrder{})
+	if tx.Error != nil {
+		t.Fatalf("dry run: %v", tx.Error)
+	}
+	if tx.Statement.Table != "sharded_orders_1" {
+		t.Errorf("unexpected table %s", tx.Statement.Table)
+	}
+	if tx.Statement.ConnPool != me.wrappers["s1"].master.ConnPool {
+		t.Error("query should run on server s1")
+	}
+
+	var mu sync.Mutex
+	var tables []string
+	err := me.ShardEach("orders", &shardedOrder{}, true, func(shard *Shard, tx *gorm.DB) error {
+		mu.Lock()
+		defer mu.Unlock()
+		tables = append(tables, tx.Statement.Table)
+		return nil
+	})
+	if err != nil || len(tables) != 4 {
+		t.Errorf("ShardEach should visit 4 shards, got %v, %v", tables, err)
+	}
+}
+
+func TestShardingNotConfigured(t *testing.T) {
+	me := newDryRunDb(t, &config.Config{}, "s0")
+
+	if _, err := me.ResolveShard("orders", 1); !errors.Is(err, ErrShardingNotFound) {
+		t.Errorf("ResolveShard: expected ErrShardingNotFound, got %v", err)
+	}
+	err := me.ShardEach("orders", &shardedOrder{}, true, func(*Shard, *gorm.DB) error { return nil })
+	if !errors.Is(err, ErrShardingNotFound) {
+		t.Errorf("ShardEach: expected ErrShardingNotFound, got %v", err)
+	}
+	if _, err := FanOutFind[shardedOrder](me, "orders", nil); !errors.Is(err, ErrShardingNotFound) {
+		t.Errorf("FanOutFind: expected ErrShardingNotFound, got %v", err)
+	}
+	if _, err := me.FanOutCount("orders", &shardedOrder{}, nil); !errors.Is(err, ErrShardingNotFound) {
+		t.Errorf("FanOutCount: expected ErrShardingNotFound, got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("ShardWrite should panic like Write")
+		}
+	}()
+	me.ShardWrite(&shardedOrder{UserID: 1})
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Spec 是可组合的查询条件，签名与 gorm 的 Scopes 一致，可以直接复用已有的 scope 函数
+type Spec func(*gorm.DB) *gorm.DB
+
+func applySpecs(tx *gorm.DB, specs []Spec) *gorm.DB {
+	for _, spec := range specs {
+		if spec != nil {
+			tx = spec(tx)
+		}
+	}
+	return tx
+}
+
+func Where(query any, args ...any) Spec {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where(query, args...)
+	}
+}
+
+func Eq(column string, value any) Spec {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where(clause.Eq{Column: clause.Column{Name: column}, Value: value})
+	}
+}
+
+func In(column string, values ...any) Spec {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where(clause.IN{Column: clause.Column{Name: column}, Values: values})
+	}
+}
+
+func Gt(column string, value any) Spec {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where(clause.Gt{Column: clause.Column{Name: column}, Value: value})
+	}
+}
+
+func Lt(column string, value any) Spec {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where(clause.Lt{Column: clause.Column{Name: column}, Value: value})
+	}
+}
+
+func Like(column string, pattern string) Spec {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where(clause.Like{Column: clause.Column{Name: column}, Value: pattern})
+	}
+}
+
+func OrderBy(column string, desc bool) Spec {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Order(clause.OrderByColumn{Column: clause.Column{Name: column}, Desc: desc})
+	}
+}
+
+func Preload(query string, args ...any) Spec {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Preload(query, args...)
+	}
+}
+
+// WithDeleted 查询时包含已软删除的记录
+func WithDeleted() Spec {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Unscoped()
+	}
+}
+
+// Or 将多个 Spec 以 OR 组合，每个 Spec 内部的条件仍以 AND 连接
+func Or(specs ...Spec) Spec {
+	return func(tx *gorm.DB) *gorm.DB {
+		var group *gorm.DB
+		for _, spec := range specs {
+			cond := spec(tx.Session(&gorm.Session{NewDB: true}))
+			if group == nil {
+				group = cond
+			} else {
+				group = group.Or(cond)
+			}
+		}
+		if group == nil {
+			return tx
+		}
+		return tx.Where(group)
+	}
+}
@@ -0,0 +1,456 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/puper/leo/components/db/config"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	TenantModeDatabase = "database"
+	TenantModeSchema   = "schema"
+	TenantModePrefix   = "prefix"
+
+	// TablePrefixPlaceholder 在 schema、prefix 模式下，原生 SQL（包括迁移文件）中的该占位符会被替换为租户的表名前缀，
+	// 例如 CREATE TABLE {{prefix}}users。
+	TablePrefixPlaceholder = "{{prefix}}"
+
+	defaultTenantIdleTimeout = 10 * time.Minute
+	defaultTenantMigrateName = "tenant"
+)
+
+var (
+	ErrNoTenant       = errors.New("db: no tenant in context")
+	ErrTenantNotFound = errors.New("db: tenant not found")
+)
+
+type (
+	// TenantRecord 是租户控制表中的一行，Slave 为逗号分隔的连接串，Disabled 的租户视为不存在
+	TenantRecord struct {
+		ID       string `gorm:"primaryKey;size:64"`
+		Master   string `gorm:"size:1024"`
+		Slave    string `gorm:"size:4096"`
+		Schema   string `gorm:"size:64"`
+		Prefix   string `gorm:"size:64"`
+		Disabled bool   `gorm:"not null;default:false"`
+	}
+	tenantKey struct{}
+	tenancy   struct {
+		db      *Db
+		config  *config.TenancyConfig
+		mu      sync.Mutex
+		tenants map[string]*tenant
+
+		ctx    context.Context
+		cancel context.CancelFunc
+		wg     sync.WaitGroup
+	}
+	tenant struct {
+		id      string
+		prefix  string
+		wrapper *Wrapper
+		// owned 为 true 时 wrapper 为租户独占的连接池 (database 模式)，淘汰时需要关闭
+		owned    bool
+		ready    chan struct{}
+		err      error
+		lastUsed atomic.Int64
+	}
+)
+
+// WithTenant 将租户 ID 放入 ctx，TenantWrite、TenantRead 据此选择连接
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+func TenantFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(tenantKey{}).(string)
+	return id, ok && id != ""
+}
+
+func (me *TenantRecord) tenantConfig() config.TenantConfig {
+	reply := config.TenantConfig{
+		Master: me.Master,
+		Schema: me.Schema,
+		Prefix: me.Prefix,
+	}
+	for _, s := range strings.Split(me.Slave, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			reply.Slave = append(reply.Slave, s)
+		}
+	}
+	return reply
+}
+
+func newTenancy(db *Db, cfg *config.TenancyConfig) (*tenancy, error) {
+	switch cfg.Mode {
+	case TenantModeDatabase:
+	case TenantModeSchema, TenantModePrefix:
+		if _, ok := db.config.Servers[cfg.Server]; !ok {
+			return nil, fmt.Errorf("db: tenancy server `%s` not configured", cfg.Server)
+		}
+	default:
+		return nil, fmt.Errorf("db: unknown tenancy mode `%s`", cfg.Mode)
+	}
+	if cfg.RegistryTable != "" {
+		if _, ok := db.config.Servers[cfg.Server]; !ok {
+			return nil, fmt.Errorf("db: tenancy server `%s` not configured", cfg.Server)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	reply := &tenancy{
+		db:      db,
+		config:  cfg,
+		tenants: map[string]*tenant{},
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	reply.wg.Add(1)
+	go reply.evictLoop()
+	return reply, nil
+}
+
+// get 返回已打开的租户连接，首次使用时打开，同一租户的并发调用只打开一次
+func (me *tenancy) get(ctx context.Context, id string) (*tenant, error) {
+	me.mu.Lock()
+	t, ok := me.tenants[id]
+	if !ok {
+		t = &tenant{id: id, ready: make(chan struct{})}
+		me.tenants[id] = t
+		me.mu.Unlock()
+		t.err = me.open(ctx, t)
+		if t.err != nil {
+			me.mu.Lock()
+			delete(me.tenants, id)
+			me.mu.Unlock()
+		}
+		close(t.ready)
+	} else {
+		me.mu.Unlock()
+		select {
+		case <-t.ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if t.err != nil {
+		return nil, t.err
+	}
+	t.lastUsed.Store(time.Now().UnixNano())
+	return t, nil
+}
+
+func (me *tenancy) open(ctx context.Context, t *tenant) error {
+	cfg, err := me.lookup(ctx, t.id)
+	if err != nil {
+		return err
+	}
+	name := "tenant:" + t.id
+	if me.config.Mode == TenantModeDatabase {
+		server, _ := me.db.ServerConfig(me.config.Server)
+		server.Master, server.Slave = cfg.Master, cfg.Slave
+		w, err := newWrapper(server)
+		if err != nil {
+			return err
+		}
+		if err := me.db.applyConnHooks(name, w); err != nil {
+			w.Close()
+			return err
+		}
+		t.wrapper, t.owned = w, true
+		return nil
+	}
+	base, err := me.db.Lookup(me.config.Server)
+	if err != nil {
+		return err
+	}
+	t.prefix = cmp.Or(cfg.Prefix, t.id+"_")
+	if me.config.Mode == TenantModeSchema {
+		t.prefix = cmp.Or(cfg.Schema, t.id) + "."
+	}
+	w := new(Wrapper)
+	if w.master, err = prefixed(base.master, t.prefix); err != nil {
+		return err
+	}
+	for _, s := range base.slave {
+		slave, err := prefixed(s, t.prefix)
+		if err != nil {
+			return err
+		}
+		w.slave = append(w.slave, slave)
+	}
+	if err := me.db.applyConnHooks(name, w); err != nil {
+		return err
+	}
+	t.wrapper = w
+	return nil
+}
+
+// lookup 先查配置中的静态租户，再查控制表
+func (me *tenancy) lookup(ctx context.Context, id string) (config.TenantConfig, error) {
+	if cfg, ok := me.config.Tenants[id]; ok {
+		return cfg, nil
+	}
+	if me.config.RegistryTable == "" {
+		return config.TenantConfig{}, fmt.Errorf("%w: %s", ErrTenantNotFound, id)
+	}
+	var record TenantRecord
+	err := me.db.Write(me.config.Server).WithContext(ctx).
+		Table(me.config.RegistryTable).
+		Where("id = ? AND disabled = ?", id, false).
+		Take(&record).
+		Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return config.TenantConfig{}, fmt.Errorf("%w: %s", ErrTenantNotFound, id)
+	}
+	if err != nil {
+		return config.TenantConfig{}, err
+	}
+	return record.tenantConfig(), nil
+}
+
+func (me *tenancy) ids(ctx context.Context) ([]string, error) {
+	ids := slices.Collect(maps.Keys(me.config.Tenants))
+	if me.config.RegistryTable != "" {
+		var registered []string
+		err := me.db.Write(me.config.Server).WithContext(ctx).
+			Table(me.config.RegistryTable).
+			Where("disabled = ?", false).
+			Pluck("id", &registered).
+			Error
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, registered...)
+	}
+	slices.Sort(ids)
+	return slices.Compact(ids), nil
+}
+
+func (me *tenancy) evictLoop() {
+	defer me.wg.Done()
+	ticker := time.NewTicker(me.idleTimeout() / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-me.ctx.Done():
+			return
+		case <-ticker.C:
+			me.evictIdle(time.Now().Add(-me.idleTimeout()))
+		}
+	}
+}
+
+func (me *tenancy) evictIdle(before time.Time) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	for id, t := range me.tenants {
+		select {
+		case <-t.ready:
+		default:
+			continue
+		}
+		if t.lastUsed.Load() < before.UnixNano() {
+			me.evict(id, t)
+		}
+	}
+}
+
+// evict 调用方需持有 me.mu；已取得连接的请求仍可在 DrainTimeout 内执行完毕
+func (me *tenancy) evict(id string, t *tenant) {
+	delete(me.tenants, id)
+	if t.owned {
+		me.db.drain("tenant:"+id, t.wrapper)
+	}
+}
+
+// evictAll 在共享的 server 被替换后丢弃所有基于旧连接池创建的租户连接
+func (me *tenancy) evictAll() {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	for id, t := range me.tenants {
+		me.evict(id, t)
+	}
+}
+
+func (me *tenancy) close() error {
+	me.cancel()
+	me.wg.Wait()
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	var errs []error
+	for id, t := range me.tenants {
+		delete(me.tenants, id)
+		if t.owned && t.wrapper != nil {
+			errs = append(errs, t.wrapper.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (me *tenancy) idleTimeout() time.Duration {
+	if me.config.IdleTimeout > 0 {
+		return me.config.IdleTimeout
+	}
+	return defaultTenantIdleTimeout
+}
+
+func (me *tenancy) migrateName() string {
+	return cmp.Or(me.config.MigrateName, defaultTenantMigrateName)
+}
+
+// prefixed 在 base 的连接池上创建表名带 prefix 的 gorm.DB，不会打开新的连接。
+// 模型的表名通过 NamingStrategy 加前缀；实现了 TableName() 的模型与 Table() 指定的表名由 callback 补上前缀。
+func prefixed(base *gorm.DB, prefix string) (*gorm.DB, error) {
+	d, ok := base.Dialector.(*mysql.Dialector)
+	if !ok {
+		return nil, fmt.Errorf("db: table prefix is not supported by dialector %s", base.Dialector.Name())
+	}
+	dc := *d.Config
+	dc.Conn = base.ConnPool
+	dc.SkipInitializeWithVersion = true
+	conn, err := gorm.Open(&mysql.Dialector{Config: &dc}, &gorm.Config{
+		NamingStrategy:         schema.NamingStrategy{TablePrefix: prefix, IdentifierMaxLength: 64},
+		Logger:                 base.Logger,
+		NowFunc:                base.NowFunc,
+		DryRun:                 base.DryRun,
+		SkipDefaultTransaction: base.SkipDefaultTransaction,
+		DisableAutomaticPing:   true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	qualify := func(tx *gorm.DB) {
+		stmt := tx.Statement
+		if stmt.Table != "" && !strings.HasPrefix(stmt.Table, prefix) && !strings.Contains(stmt.Table, ".") {
+			// 带别名等复杂的 Table() 表达式保持原样
+			if stmt.TableExpr == nil || stmt.TableExpr.SQL == stmt.Quote(stmt.Table) {
+				stmt.Table = prefix + stmt.Table
+				if stmt.TableExpr != nil {
+					stmt.TableExpr = &clause.Expr{SQL: stmt.Quote(stmt.Table)}
+				}
+			}
+		}
+		if sql := stmt.SQL.String(); strings.Contains(sql, TablePrefixPlaceholder) {
+			stmt.SQL.Reset()
+			stmt.SQL.WriteString(strings.ReplaceAll(sql, TablePrefixPlaceholder, prefix))
+		}
+	}
+	cb := conn.Callback()
+	for _, register := range []func(string, func(*gorm.DB)) error{
+		cb.Create().Before("*").Register,
+		cb.Query().Before("*").Register,
+		cb.Update().Before("*").Register,
+		cb.Delete().Before("*").Register,
+		cb.Row().Before("*").Register,
+		cb.Raw().Before("*").Register,
+	} {
+		if err := register("leo:tenant_prefix", qualify); err != nil {
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func (me *Db) tenant(ctx context.Context) (*tenant, error) {
+	if me.tenancy == nil {
+		return nil, errors.New("db: tenancy not configured")
+	}
+	id, ok := TenantFromContext(ctx)
+	if !ok {
+		return nil, ErrNoTenant
+	}
+	return me.tenancy.get(ctx, id)
+}
+
+// TenantWrite 返回 ctx 中租户的主库连接，连接已绑定 ctx
+func (me *Db) TenantWrite(ctx context.Context) (*gorm.DB, error) {
+	t, err := me.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return t.wrapper.Write().WithContext(ctx), nil
+}
+
+func (me *Db) TenantRead(ctx context.Context) (*gorm.DB, error) {
+	t, err := me.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return t.wrapper.Read().WithContext(ctx), nil
+}
+
+// Tenants 返回配置与控制表中的全部租户 ID
+func (me *Db) Tenants(ctx context.Context) ([]string, error) {
+	if me.tenancy == nil {
+		return nil, errors.New("db: tenancy not configured")
+	}
+	return me.tenancy.ids(ctx)
+}
+
+// EvictTenant 关闭租户的连接，控制表中租户的连接信息修改后调用，下次使用时按新的配置打开
+func (me *Db) EvictTenant(id string) {
+	if me.tenancy == nil {
+		return
+	}
+	me.tenancy.mu.Lock()
+	defer me.tenancy.mu.Unlock()
+	if t, ok := me.tenancy.tenants[id]; ok {
+		select {
+		case <-t.ready:
+			me.tenancy.evict(id, t)
+		default:
+		}
+	}
+}
+
+// MigrateTenants 对每个租户执行 <Root>/<MigrateName> 下的迁移，迁移配置沿用 tenancy server 的 migrate 节。
+// schema、prefix 模式下迁移表名同样加上租户前缀。单个租户失败不影响其他租户，错误合并后返回。
+func (me *Db) MigrateTenants(ctx context.Context, migrateFs fs.FS, opts ...LoadOption) error {
+	tenants, err := me.Tenants(ctx)
+	if err != nil {
+		return err
+	}
+	migrates, err := LoadMigrates(migrateFs, opts...)
+	if err != nil {
+		return fmt.Errorf("LoadMigrates: %w", err)
+	}
+	ms, ok := migrates[me.tenancy.migrateName()]
+	if !ok {
+		return nil
+	}
+	server, _ := me.ServerConfig(me.tenancy.config.Server)
+	var errs []error
+	for _, id := range tenants {
+		t, err := me.tenancy.get(ctx, id)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("tenant %s: %w", id, err))
+			continue
+		}
+		options := &Options{}
+		if server.Migrate != nil {
+			*options = *server.Migrate
+		}
+		if t.prefix != "" {
+			options.TableName = t.prefix + cmp.Or(options.TableName, DefaultOptions.TableName)
+		}
+		m := NewMigrate(t.wrapper.Write().WithContext(ctx), options, ms)
+		if err := m.Migrate(); err != nil && err != ErrNoMigrationDefined {
+			errs = append(errs, fmt.Errorf("tenant %s: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/puper/leo/components/db/config"
+	"gorm.io/gorm/logger"
+)
+
+type tenantAccount struct {
+	ID   int64
+	Name string
+}
+
+func (tenantAccount) TableName() string { return "accounts" }
+
+func newTenantDb(t *testing.T, mode string) (*Db, *sqlRecorder) {
+	t.Helper()
+	cfg := &config.Config{
+		Servers: map[string]config.ServerConfig{"shared": {}},
+		Tenancy: &config.TenancyConfig{
+			Mode:   mode,
+			Server: "shared",
+			Tenants: map[string]config.TenantConfig{
+				"t1": {},
+				"t2": {Prefix: "acme_", Schema: "acme"},
+			},
+		},
+	}
+	me := newDryRunDb(t, cfg, "shared")
+	recorder := &sqlRecorder{Interface: logger.Discard}
+	me.wrappers["shared"].master.Logger = recorder
+	var err error
+	if me.tenancy, err = newTenancy(me, cfg.Tenancy); err != nil {
+		t.Fatalf("newTenancy: %v", err)
+	}
+	t.Cleanup(func() { me.Close() })
+	return me, recorder
+}
+
+func TestTenantPrefix(t *testing.T) {
+	me, recorder := newTenantDb(t, TenantModePrefix)
+	for _, id := range []string{"t1", "t2"} {
+		conn, err := me.TenantRead(WithTenant(context.Background(), id))
+		if err != nil {
+			t.Fatalf("TenantRead %s: %v", id, err)
+		}
+		conn.Find(&[]account{})
+		conn.Where("id = ?", 1).Find(&[]tenantAccount{})
+		conn.Table("logs").Where("id = ?", 1).Delete(&tenantAccount{})
+		conn.Exec("DELETE FROM {{prefix}}logs")
+	}
+	want := []string{
+		"SELECT * FROM `t1_accounts`",
+		"SELECT * FROM `t1_accounts` WHERE id = 1",
+		"DELETE FROM `t1_logs` WHERE id = 1",
+		"DELETE FROM t1_logs",
+		"SELECT * FROM `acme_accounts`",
+		"SELECT * FROM `acme_accounts` WHERE id = 1",
+		"DELETE FROM `acme_logs` WHERE id = 1",
+		"DELETE FROM acme_logs",
+	}
+	if strings.Join(recorder.sqls, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("sqls = %q", recorder.sqls)
+	}
+	// 共享连接池本身不受影响
+	recorder.sqls = nil
+	me.Write("shared").Find(&[]tenantAccount{})
+	if len(recorder.sqls) != 1 || recorder.sqls[0] != "SELECT * FROM `accounts`" {
+		t.Fatalf("sqls = %q", recorder.sqls)
+	}
+}
+
+func TestTenantSchema(t *testing.T) {
+	me, recorder := newTenantDb(t, TenantModeSchema)
+	conn, err := me.TenantWrite(WithTenant(context.Background(), "t2"))
+	if err != nil {
+		t.Fatalf("TenantWrite: %v", err)
+	}
+	conn.Find(&[]tenantAccount{})
+	conn.Find(&[]account{})
+	if want := "SELECT * FROM `acme`.`accounts`\nSELECT * FROM `acme`.`accounts`"; strings.Join(recorder.sqls, "\n") != want {
+		t.Fatalf("sqls = %q", recorder.sqls)
+	}
+}
+
+func TestTenantLookup(t *testing.T) {
+	me, _ := newTenantDb(t, TenantModePrefix)
+	if _, err := me.TenantWrite(context.Background()); !errors.Is(err, ErrNoTenant) {
+		t.Fatalf("err = %v", err)
+	}
+	if _, err := me.TenantWrite(WithTenant(context.Background(), "t3")); !errors.Is(err, ErrTenantNotFound) {
+		t.Fatalf("err = %v", err)
+	}
+	ids, err := me.Tenants(context.Background())
+	if err != nil || strings.Join(ids, ",") != "t1,t2" {
+		t.Fatalf("Tenants = %v, %v", ids, err)
+	}
+	ctx := WithTenant(context.Background(), "t1")
+	first, _ := me.tenancy.get(ctx, "t1")
+	second, _ := me.tenancy.get(ctx, "t1")
+	if first != second {
+		t.Fatalf("tenant connection should be cached")
+	}
+	me.tenancy.evictIdle(time.Now().Add(time.Second))
+	if third, _ := me.tenancy.get(ctx, "t1"); third == first {
+		t.Fatalf("idle tenant should be evicted")
+	}
+	if err := me.RemoveServer("shared"); err == nil {
+		t.Fatalf("RemoveServer of the tenancy server should fail")
+	}
+}
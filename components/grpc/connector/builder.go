@@ -0,0 +1,51 @@
+package connector
+
+import (
+	"cmp"
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/puper/leo/components/grpc/connector/config"
+	"github.com/puper/leo/engine"
+	"github.com/puper/leo/pkg/reconnect"
+	"google.golang.org/grpc"
+)
+
+const defaultStartTimeout = 10 * time.Second
+
+type Component = reconnect.Typed[*grpc.ClientConn]
+
+// Builder 构建自动重连的 gRPC 连接，StartTimeout 内未进入 READY 时构建失败
+func Builder(cfg *config.Config, configurers ...func(*Connector) error) engine.Builder {
+	return func() (any, error) {
+		me := New(cfg)
+		for _, configurer := range configurers {
+			if err := configurer(me); err != nil {
+				return nil, errors.WithMessage(err, "configurer")
+			}
+		}
+		comp := reconnect.NewTyped[*grpc.ClientConn](me, me.eventHandler, me.Config())
+		ctx, cancel := context.WithTimeout(context.Background(), cmp.Or(cfg.StartTimeout, defaultStartTimeout))
+		defer cancel()
+		if err := comp.StartAndWait(ctx); err != nil {
+			return nil, errors.WithMessage(err, "grpc.StartAndWait")
+		}
+		return comp, nil
+	}
+}
+
+// WithDialOptions 追加 grpc.NewClient 的选项，可覆盖由配置生成的 TransportCredentials
+func WithDialOptions(opts ...grpc.DialOption) func(*Connector) error {
+	return func(me *Connector) error {
+		me.dialOptions = append(me.dialOptions, opts...)
+		return nil
+	}
+}
+
+func WithEventHandler(eventHandler reconnect.EventHandler) func(*Connector) error {
+	return func(me *Connector) error {
+		me.eventHandler = eventHandler
+		return nil
+	}
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"time"
+
+	"github.com/puper/leo/pkg/reconnect"
+)
+
+type Config struct {
+	Addr string `json:"addr"`
+	// DialTimeout 单次连接等待进入 READY 的时长，0 表示不限制
+	DialTimeout time.Duration `json:"dialTimeout"`
+
+	TLS                bool   `json:"tls"`
+	ServerName         string `json:"serverName"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"`
+
+	// HealthCheck 为 true 时 SendPing 调用 grpc.health.v1 的 Check，否则只检查连接状态
+	HealthCheck bool `json:"healthCheck"`
+	// HealthService Check 的服务名，空表示整个服务端
+	HealthService string `json:"healthService"`
+
+	// StartTimeout 构建时等待首次连接的时长，默认 10s
+	StartTimeout time.Duration                     `json:"startTimeout"`
+	Reconnect    *reconnect.DefaultReconnectConfig `json:"reconnect"`
+}
@@ -0,0 +1,159 @@
+package connector
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/puper/leo/components/grpc/connector/config"
+	"github.com/puper/leo/pkg/reconnect"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// Connector 由连接状态驱动的 gRPC 客户端连接：进入 READY 视为连接成功，
+// 之后进入 TRANSIENT_FAILURE 或 SHUTDOWN 时通过 NotifyDisconnect 通知 reconnect.Component 重连
+type Connector struct {
+	config       *config.Config
+	dialOptions  []grpc.DialOption
+	eventHandler reconnect.EventHandler
+
+	mu          sync.RWMutex
+	conn        *grpc.ClientConn
+	lost        chan error
+	cancelWatch context.CancelFunc
+}
+
+func New(cfg *config.Config) *Connector {
+	return &Connector{config: cfg}
+}
+
+func (me *Connector) Connect(ctx context.Context) error {
+	if me.config.DialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, me.config.DialTimeout)
+		defer cancel()
+	}
+	opts := append([]grpc.DialOption{grpc.WithTransportCredentials(me.credentials())}, me.dialOptions...)
+	conn, err := grpc.NewClient(me.config.Addr, opts...)
+	if err != nil {
+		return err
+	}
+	conn.Connect()
+	for state := conn.GetState(); state != connectivity.Ready; state = conn.GetState() {
+		if state == connectivity.TransientFailure || state == connectivity.Shutdown {
+			conn.Close()
+			return fmt.Errorf("grpc: connectivity state %s", state)
+		}
+		if !conn.WaitForStateChange(ctx, state) {
+			conn.Close()
+			return ctx.Err()
+		}
+	}
+
+	watchCtx, cancel := context.WithCancel(context.Background())
+	lost := make(chan error, 1)
+	go watch(watchCtx, conn, lost)
+	me.mu.Lock()
+	me.conn = conn
+	me.lost = lost
+	me.cancelWatch = cancel
+	me.mu.Unlock()
+	return nil
+}
+
+// watch 等待连接离开 READY 后进入失败状态。服务端关闭连接或空闲超时后进入 IDLE，
+// 此时立即重新连接，连接失败进入 TRANSIENT_FAILURE 时通知断开
+func watch(ctx context.Context, conn *grpc.ClientConn, lost chan<- error) {
+	state := connectivity.Ready
+	for conn.WaitForStateChange(ctx, state) {
+		state = conn.GetState()
+		switch state {
+		case connectivity.Idle:
+			conn.Connect()
+		case connectivity.TransientFailure, connectivity.Shutdown:
+			lost <- fmt.Errorf("grpc: connectivity state %s", state)
+			return
+		}
+	}
+}
+
+func (me *Connector) Disconnect() error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if me.conn == nil {
+		return nil
+	}
+	me.cancelWatch()
+	err := me.conn.Close()
+	me.conn = nil
+	return err
+}
+
+func (me *Connector) IsConnected() bool {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	return me.conn != nil
+}
+
+func (me *Connector) GetClient() *grpc.ClientConn {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	return me.conn
+}
+
+func (me *Connector) NotifyDisconnect() <-chan error {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	return me.lost
+}
+
+func (me *Connector) SendPing(ctx context.Context) error {
+	conn := me.GetClient()
+	if conn == nil {
+		return errors.New("grpc: not connected")
+	}
+	if !me.config.HealthCheck {
+		if state := conn.GetState(); state == connectivity.TransientFailure || state == connectivity.Shutdown {
+			return fmt.Errorf("grpc: connectivity state %s", state)
+		}
+		return nil
+	}
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: me.config.HealthService})
+	if err != nil {
+		return err
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("grpc: health status %s", resp.GetStatus())
+	}
+	return nil
+}
+
+// IsConnectionError 只有 Unavailable 说明连接不可用，其余状态码是业务错误
+func (me *Connector) IsConnectionError(err error) bool {
+	return status.Code(err) == codes.Unavailable
+}
+
+func (me *Connector) Config() reconnect.ReconnectConfig {
+	if me.config.Reconnect == nil {
+		return &reconnect.DefaultReconnectConfig{}
+	}
+	return me.config.Reconnect
+}
+
+func (me *Connector) credentials() credentials.TransportCredentials {
+	if !me.config.TLS {
+		return insecure.NewCredentials()
+	}
+	return credentials.NewTLS(&tls.Config{
+		ServerName:         me.config.ServerName,
+		InsecureSkipVerify: me.config.InsecureSkipVerify,
+	})
+}
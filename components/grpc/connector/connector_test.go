@@ -0,0 +1,98 @@
+package connector
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/puper/leo/components/grpc/connector/config"
+	"github.com/puper/leo/pkg/reconnect"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func startServer(t *testing.T, addr string) (*grpc.Server, *health.Server, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(ln)
+	return srv, hs, ln.Addr().String()
+}
+
+func TestBuilder_ReconnectOnServerRestart(t *testing.T) {
+	srv, hs, addr := startServer(t, "127.0.0.1:0")
+	var me *Connector
+	v, err := Builder(&config.Config{
+		Addr:        addr,
+		HealthCheck: true,
+		Reconnect:   &reconnect.DefaultReconnectConfig{InitialInterval: 10 * time.Millisecond},
+	}, func(c *Connector) error {
+		me = c
+		return nil
+	})()
+	if err != nil {
+		t.Fatal(err)
+	}
+	comp := v.(*Component)
+	defer comp.Close()
+
+	conn := comp.GetClient().Raw()
+	if err := me.SendPing(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	if err := me.SendPing(context.Background()); err == nil {
+		t.Fatal("SendPing should fail when not serving")
+	}
+
+	srv.Stop()
+	deadline := time.Now().Add(time.Second)
+	for comp.Stats().Disconnects == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("should notice the connection state change")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	srv, _, _ = startServer(t, addr)
+	defer srv.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := comp.WaitConnected(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if comp.GetClient().Raw() == conn {
+		t.Fatal("should use a new ClientConn after reconnect")
+	}
+}
+
+func TestConnector_IsConnectionError(t *testing.T) {
+	me := New(&config.Config{})
+	if !me.IsConnectionError(status.Error(codes.Unavailable, "down")) {
+		t.Fatal("Unavailable should be a connection error")
+	}
+	if me.IsConnectionError(status.Error(codes.NotFound, "missing")) {
+		t.Fatal("NotFound should not be a connection error")
+	}
+}
+
+func TestBuilder_StartTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	_, err = Builder(&config.Config{Addr: addr, StartTimeout: 50 * time.Millisecond})()
+	if err == nil {
+		t.Fatal("build should fail when the server is unreachable")
+	}
+}
@@ -0,0 +1,40 @@
+package locker
+
+import (
+	"github.com/pkg/errors"
+	"github.com/puper/leo/components/locker/config"
+	"github.com/puper/leo/engine"
+	"github.com/puper/leo/pkg/mutexmanager"
+	"github.com/puper/leo/pkg/mutexmanager/etcdlocker"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func Builder(cfg *config.Config, configurers ...func(*Component) error) engine.Builder {
+	return func() (any, error) {
+		me := &Component{config: cfg}
+		for _, configurer := range configurers {
+			if err := configurer(me); err != nil {
+				return nil, errors.WithMessage(err, "locker.configurer")
+			}
+		}
+		switch cfg.Backend {
+		case "", "memory":
+			me.Locker = mutexmanager.New()
+		case "etcd":
+			if me.etcdCli == nil {
+				return nil, errors.New("locker: etcd client is nil")
+			}
+			me.Locker = etcdlocker.New(me.etcdCli, &cfg.Etcd)
+		default:
+			return nil, errors.Errorf("locker: unknown backend %q", cfg.Backend)
+		}
+		return me, nil
+	}
+}
+
+func WithEtcd(f func() *clientv3.Client) func(*Component) error {
+	return func(me *Component) error {
+		me.etcdCli = f()
+		return nil
+	}
+}
@@ -0,0 +1,33 @@
+package locker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/puper/leo/components/locker/config"
+	"github.com/puper/leo/pkg/mutexmanager"
+)
+
+func TestBuilder(t *testing.T) {
+	v, err := Builder(&config.Config{})()
+	if err != nil {
+		t.Fatal(err)
+	}
+	comp := v.(*Component)
+	defer comp.Close()
+	if _, ok := comp.Locker.(*mutexmanager.MutexManager); !ok {
+		t.Fatalf("default backend is %T", comp.Locker)
+	}
+	l, err := comp.Acquire(context.Background(), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Unlock(context.Background())
+
+	if _, err := Builder(&config.Config{Backend: "etcd"})(); err == nil {
+		t.Fatal("expected error without etcd client")
+	}
+	if _, err := Builder(&config.Config{Backend: "redis"})(); err == nil {
+		t.Fatal("expected error for unknown backend")
+	}
+}
@@ -0,0 +1,23 @@
+package locker
+
+import (
+	"io"
+
+	"github.com/puper/leo/components/locker/config"
+	"github.com/puper/leo/pkg/mutexmanager"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Component 是按配置选择后端的 mutexmanager.Locker
+type Component struct {
+	mutexmanager.Locker
+	config  *config.Config
+	etcdCli *clientv3.Client
+}
+
+func (me *Component) Close() error {
+	if closer, ok := me.Locker.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+package config
+
+import "github.com/puper/leo/pkg/mutexmanager/etcdlocker"
+
+type Config struct {
+	// Backend memory (默认，进程内互斥) 或 etcd (多个进程之间互斥，需要 WithEtcd)
+	Backend string            `json:"backend"`
+	Etcd    etcdlocker.Config `json:"etcd"`
+}
@@ -0,0 +1,52 @@
+package connector
+
+import (
+	"cmp"
+	"context"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+	"github.com/puper/leo/components/nats/connector/config"
+	"github.com/puper/leo/engine"
+	"github.com/puper/leo/pkg/reconnect"
+)
+
+const defaultStartTimeout = 10 * time.Second
+
+type Component = reconnect.Typed[*nats.Conn]
+
+// Builder 构建由 reconnect.Component 管理重连的 nats 连接，StartTimeout 内未连接成功时构建失败。
+// 订阅随连接失效，需要在 OnConnected 中重新订阅
+func Builder(cfg *config.Config, configurers ...func(*Connector) error) engine.Builder {
+	return func() (any, error) {
+		me := New(cfg)
+		for _, configurer := range configurers {
+			if err := configurer(me); err != nil {
+				return nil, errors.WithMessage(err, "configurer")
+			}
+		}
+		comp := reconnect.NewTyped[*nats.Conn](me, me.eventHandler, me.Config())
+		ctx, cancel := context.WithTimeout(context.Background(), cmp.Or(cfg.StartTimeout, defaultStartTimeout))
+		defer cancel()
+		if err := comp.StartAndWait(ctx); err != nil {
+			return nil, errors.WithMessage(err, "nats.StartAndWait")
+		}
+		return comp, nil
+	}
+}
+
+// WithOptions 追加 nats.Connect 的选项
+func WithOptions(opts ...nats.Option) func(*Connector) error {
+	return func(me *Connector) error {
+		me.options = append(me.options, opts...)
+		return nil
+	}
+}
+
+func WithEventHandler(eventHandler reconnect.EventHandler) func(*Connector) error {
+	return func(me *Connector) error {
+		me.eventHandler = eventHandler
+		return nil
+	}
+}
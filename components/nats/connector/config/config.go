@@ -0,0 +1,20 @@
+package config
+
+import (
+	"time"
+
+	"github.com/puper/leo/pkg/reconnect"
+)
+
+type Config struct {
+	Url      string `json:"url"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// Name 连接名，显示在服务端的监控中
+	Name        string        `json:"name"`
+	DialTimeout time.Duration `json:"dialTimeout"`
+
+	// StartTimeout 构建时等待首次连接的时长，默认 10s
+	StartTimeout time.Duration                     `json:"startTimeout"`
+	Reconnect    *reconnect.DefaultReconnectConfig `json:"reconnect"`
+}
@@ -0,0 +1,137 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+	"github.com/puper/leo/components/nats/connector/config"
+	"github.com/puper/leo/pkg/reconnect"
+)
+
+// Connector 关闭 nats.go 自带的重连，由 reconnect.Component 负责重连，
+// 连接断开时通过 NotifyDisconnect 通知，SendPing 发送 PING 并等待 PONG
+type Connector struct {
+	config       *config.Config
+	options      []nats.Option
+	eventHandler reconnect.EventHandler
+
+	mu   sync.RWMutex
+	conn *nats.Conn
+	lost chan error
+}
+
+func New(cfg *config.Config) *Connector {
+	return &Connector{config: cfg}
+}
+
+func (me *Connector) Connect(ctx context.Context) error {
+	lost := make(chan error, 1)
+	notify := func(err error) {
+		select {
+		case lost <- err:
+		default:
+		}
+	}
+	opts := []nats.Option{
+		nats.NoReconnect(),
+		nats.UserInfo(me.config.Username, me.config.Password),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			notify(err)
+		}),
+		nats.ClosedHandler(func(*nats.Conn) {
+			notify(nats.ErrConnectionClosed)
+		}),
+	}
+	if me.config.Name != "" {
+		opts = append(opts, nats.Name(me.config.Name))
+	}
+	if me.config.DialTimeout > 0 {
+		opts = append(opts, nats.Timeout(me.config.DialTimeout))
+	}
+	opts = append(opts, me.options...)
+	// nats.Connect 不接收 ctx，ctx 结束时放弃等待，连接成功后立即关闭
+	type result struct {
+		conn *nats.Conn
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		conn, err := nats.Connect(me.config.Url, opts...)
+		done <- result{conn, err}
+	}()
+	var res result
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		go func() {
+			if res := <-done; res.conn != nil {
+				res.conn.Close()
+			}
+		}()
+		return ctx.Err()
+	}
+	if res.err != nil {
+		return res.err
+	}
+	me.mu.Lock()
+	me.conn = res.conn
+	me.lost = lost
+	me.mu.Unlock()
+	return nil
+}
+
+func (me *Connector) Disconnect() error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if me.conn == nil {
+		return nil
+	}
+	me.conn.Close()
+	me.conn = nil
+	return nil
+}
+
+func (me *Connector) IsConnected() bool {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	return me.conn != nil && me.conn.IsConnected()
+}
+
+func (me *Connector) GetClient() *nats.Conn {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	return me.conn
+}
+
+func (me *Connector) NotifyDisconnect() <-chan error {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	return me.lost
+}
+
+func (me *Connector) SendPing(ctx context.Context) error {
+	conn := me.GetClient()
+	if conn == nil {
+		return errors.New("nats: not connected")
+	}
+	return conn.FlushWithContext(ctx)
+}
+
+// IsConnectionError 只有连接相关的错误触发重连，超时、权限等错误直接返回
+func (me *Connector) IsConnectionError(err error) bool {
+	return errors.Is(err, nats.ErrConnectionClosed) ||
+		errors.Is(err, nats.ErrConnectionDraining) ||
+		errors.Is(err, nats.ErrConnectionReconnecting) ||
+		errors.Is(err, nats.ErrStaleConnection) ||
+		errors.Is(err, nats.ErrNoServers) ||
+		errors.Is(err, nats.ErrDisconnected)
+}
+
+func (me *Connector) Config() reconnect.ReconnectConfig {
+	if me.config.Reconnect == nil {
+		return &reconnect.DefaultReconnectConfig{}
+	}
+	return me.config.Reconnect
+}
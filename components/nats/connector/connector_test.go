@@ -0,0 +1,101 @@
+package connector
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/puper/leo/components/nats/connector/config"
+	"github.com/puper/leo/pkg/reconnect"
+)
+
+// fakeServer 实现握手所需的最小 NATS 协议：发送 INFO，对 PING 回复 PONG
+func fakeServer(t *testing.T) (string, <-chan net.Conn) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	accepted := make(chan net.Conn, 4)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+			go func() {
+				conn.Write([]byte(`INFO {"server_id":"fake","version":"2.10.0","max_payload":1048576}` + "\r\n"))
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if strings.HasPrefix(line, "PING") {
+						conn.Write([]byte("PONG\r\n"))
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String(), accepted
+}
+
+func TestBuilder(t *testing.T) {
+	addr, accepted := fakeServer(t)
+	var me *Connector
+	v, err := Builder(&config.Config{
+		Url:       "nats://" + addr,
+		Reconnect: &reconnect.DefaultReconnectConfig{InitialInterval: 10 * time.Millisecond},
+	}, func(c *Connector) error {
+		me = c
+		return nil
+	})()
+	if err != nil {
+		t.Fatal(err)
+	}
+	comp := v.(*Component)
+	defer comp.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := me.SendPing(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	server := <-accepted
+	server.Close()
+	select {
+	case <-accepted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("should reconnect after the server closes the connection")
+	}
+	if err := comp.WaitConnected(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if stats := comp.Stats(); stats.Reconnects != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestBuilder_StartTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	_, err = Builder(&config.Config{
+		Url:          "nats://" + addr,
+		StartTimeout: 50 * time.Millisecond,
+		Reconnect:    &reconnect.DefaultReconnectConfig{InitialInterval: 10 * time.Millisecond},
+	})()
+	if err == nil {
+		t.Fatal("build should fail when the server is unreachable")
+	}
+}
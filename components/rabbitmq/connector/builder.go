@@ -0,0 +1,100 @@
+package connector
+
+import (
+	"cmp"
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/puper/leo/components/rabbitmq/connector/config"
+	"github.com/puper/leo/engine"
+	"github.com/puper/leo/pkg/reconnect"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const defaultStartTimeout = 10 * time.Second
+
+type (
+	Component        = reconnect.Typed[*amqp.Connection]
+	ChannelComponent = reconnect.Typed[*amqp.Channel]
+)
+
+// Builder 构建自动重连的 AMQP 连接，StartTimeout 内未连接成功时构建失败
+func Builder(cfg *config.Config, configurers ...func(*Connector) error) engine.Builder {
+	return func() (any, error) {
+		me := New(cfg)
+		for _, configurer := range configurers {
+			if err := configurer(me); err != nil {
+				return nil, errors.WithMessage(err, "configurer")
+			}
+		}
+		comp := reconnect.NewTyped[*amqp.Connection](me, me.eventHandler, me.Config())
+		if err := startAndWait(comp.Component, cfg.StartTimeout); err != nil {
+			return nil, errors.WithMessage(err, "rabbitmq.StartAndWait")
+		}
+		return comp, nil
+	}
+}
+
+// ChannelBuilder 构建自动重新打开的 AMQP channel，需要 WithConnection 提供连接
+func ChannelBuilder(cfg *config.ChannelConfig, configurers ...func(*ChannelConnector) error) engine.Builder {
+	return func() (any, error) {
+		me := NewChannel(cfg, nil)
+		for _, configurer := range configurers {
+			if err := configurer(me); err != nil {
+				return nil, errors.WithMessage(err, "configurer")
+			}
+		}
+		if me.connection == nil {
+			return nil, errors.New("rabbitmq: WithConnection is required")
+		}
+		comp := reconnect.NewTyped[*amqp.Channel](me, me.eventHandler, me.Config())
+		if err := startAndWait(comp.Component, cfg.StartTimeout); err != nil {
+			return nil, errors.WithMessage(err, "rabbitmq.StartAndWait")
+		}
+		return comp, nil
+	}
+}
+
+func startAndWait(comp *reconnect.Component, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), cmp.Or(timeout, defaultStartTimeout))
+	defer cancel()
+	return comp.StartAndWait(ctx)
+}
+
+// WithAMQPConfig 设置 amqp.Config，如 TLSClientConfig、Properties；Dial 会被替换
+func WithAMQPConfig(amqpConfig amqp.Config) func(*Connector) error {
+	return func(me *Connector) error {
+		me.amqpConfig = amqpConfig
+		return nil
+	}
+}
+
+func WithEventHandler(eventHandler reconnect.EventHandler) func(*Connector) error {
+	return func(me *Connector) error {
+		me.eventHandler = eventHandler
+		return nil
+	}
+}
+
+func WithConnection(f func() *Component) func(*ChannelConnector) error {
+	return func(me *ChannelConnector) error {
+		me.connection = f()
+		return nil
+	}
+}
+
+// WithSetup 每次打开 channel 后调用，用于声明 exchange、queue 等
+func WithSetup(setup func(*amqp.Channel) error) func(*ChannelConnector) error {
+	return func(me *ChannelConnector) error {
+		me.setup = setup
+		return nil
+	}
+}
+
+func WithChannelEventHandler(eventHandler reconnect.EventHandler) func(*ChannelConnector) error {
+	return func(me *ChannelConnector) error {
+		me.eventHandler = eventHandler
+		return nil
+	}
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"time"
+
+	"github.com/puper/leo/pkg/reconnect"
+)
+
+type Config struct {
+	// Addr amqp:// 或 amqps:// 地址
+	Addr string `json:"addr,omitempty"`
+	// Heartbeat 默认 10s，地址中的 heartbeat 参数优先
+	Heartbeat time.Duration `json:"heartbeat,omitempty"`
+	// DialTimeout 建立 TCP 连接及完成握手的时长，默认 30s
+	DialTimeout time.Duration `json:"dialTimeout,omitempty"`
+
+	// StartTimeout 构建时等待首次连接的时长，默认 10s
+	StartTimeout time.Duration                     `json:"startTimeout,omitempty"`
+	Reconnect    *reconnect.DefaultReconnectConfig `json:"reconnect,omitempty"`
+}
+
+type ChannelConfig struct {
+	PrefetchCount int `json:"prefetchCount,omitempty"`
+	PrefetchSize  int `json:"prefetchSize,omitempty"`
+	// Confirm 开启 publisher confirm
+	Confirm bool `json:"confirm,omitempty"`
+
+	// StartTimeout 构建时等待首次打开 channel 的时长，默认 10s
+	StartTimeout time.Duration                     `json:"startTimeout,omitempty"`
+	Reconnect    *reconnect.DefaultReconnectConfig `json:"reconnect,omitempty"`
+}
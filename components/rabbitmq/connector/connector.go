@@ -0,0 +1,251 @@
+package connector
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/puper/leo/components/rabbitmq/connector/config"
+	"github.com/puper/leo/pkg/reconnect"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const defaultDialTimeout = 30 * time.Second
+
+// Connector 管理 AMQP 连接，连接被关闭时通过 NotifyDisconnect 通知 reconnect.Component 重连
+type Connector struct {
+	config       *config.Config
+	amqpConfig   amqp.Config
+	eventHandler reconnect.EventHandler
+
+	mu   sync.RWMutex
+	conn *amqp.Connection
+	lost chan error
+}
+
+func New(cfg *config.Config) *Connector {
+	return &Connector{config: cfg}
+}
+
+func (me *Connector) Connect(ctx context.Context) error {
+	timeout := cmp.Or(me.config.DialTimeout, defaultDialTimeout)
+	amqpConfig := me.amqpConfig
+	if amqpConfig.Heartbeat == 0 {
+		amqpConfig.Heartbeat = me.config.Heartbeat
+	}
+	var stopAfter func() bool
+	amqpConfig.Dial = func(network, addr string) (net.Conn, error) {
+		conn, err := (&net.Dialer{Timeout: timeout}).DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		// 握手完成后 amqp091 会清除 deadline，ctx 结束时中断握手
+		if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		stopAfter = context.AfterFunc(ctx, func() {
+			conn.SetDeadline(time.Now())
+		})
+		return conn, nil
+	}
+	conn, err := amqp.DialConfig(me.config.Addr, amqpConfig)
+	if stopAfter != nil {
+		stopAfter()
+	}
+	if err != nil {
+		return err
+	}
+	me.mu.Lock()
+	me.conn = conn
+	me.lost = notifyClose(conn.NotifyClose(make(chan *amqp.Error, 1)))
+	me.mu.Unlock()
+	return nil
+}
+
+func (me *Connector) Disconnect() error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if me.conn == nil {
+		return nil
+	}
+	err := me.conn.Close()
+	me.conn = nil
+	if errors.Is(err, amqp.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+func (me *Connector) IsConnected() bool {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	return me.conn != nil && !me.conn.IsClosed()
+}
+
+func (me *Connector) GetClient() *amqp.Connection {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	return me.conn
+}
+
+func (me *Connector) NotifyDisconnect() <-chan error {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	return me.lost
+}
+
+// SendPing 打开并关闭一个 channel，确认服务端仍在响应
+func (me *Connector) SendPing(ctx context.Context) error {
+	conn := me.GetClient()
+	if conn == nil || conn.IsClosed() {
+		return amqp.ErrClosed
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	return ch.Close()
+}
+
+func (me *Connector) IsConnectionError(err error) bool {
+	return isAMQPError(err)
+}
+
+func (me *Connector) Config() reconnect.ReconnectConfig {
+	if me.config.Reconnect == nil {
+		return &reconnect.DefaultReconnectConfig{}
+	}
+	return me.config.Reconnect
+}
+
+// ChannelConnector 在 Connector 管理的连接上打开 channel，channel 或连接被关闭时重新打开
+type ChannelConnector struct {
+	config       *config.ChannelConfig
+	connection   *reconnect.Typed[*amqp.Connection]
+	setup        func(*amqp.Channel) error
+	eventHandler reconnect.EventHandler
+
+	mu   sync.RWMutex
+	ch   *amqp.Channel
+	lost chan error
+}
+
+func NewChannel(cfg *config.ChannelConfig, connection *reconnect.Typed[*amqp.Connection]) *ChannelConnector {
+	return &ChannelConnector{config: cfg, connection: connection}
+}
+
+func (me *ChannelConnector) Connect(ctx context.Context) error {
+	if me.connection == nil {
+		return reconnect.Permanent(errors.New("rabbitmq: channel without connection"))
+	}
+	if err := me.connection.WaitConnected(ctx); err != nil {
+		return err
+	}
+	conn := me.connection.GetClient().Raw()
+	if conn == nil {
+		return amqp.ErrClosed
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	if err := me.init(ch); err != nil {
+		ch.Close()
+		return err
+	}
+	me.mu.Lock()
+	me.ch = ch
+	me.lost = notifyClose(ch.NotifyClose(make(chan *amqp.Error, 1)))
+	me.mu.Unlock()
+	return nil
+}
+
+func (me *ChannelConnector) init(ch *amqp.Channel) error {
+	if me.config.PrefetchCount > 0 || me.config.PrefetchSize > 0 {
+		if err := ch.Qos(me.config.PrefetchCount, me.config.PrefetchSize, false); err != nil {
+			return err
+		}
+	}
+	if me.config.Confirm {
+		if err := ch.Confirm(false); err != nil {
+			return err
+		}
+	}
+	if me.setup != nil {
+		return me.setup(ch)
+	}
+	return nil
+}
+
+func (me *ChannelConnector) Disconnect() error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if me.ch == nil {
+		return nil
+	}
+	err := me.ch.Close()
+	me.ch = nil
+	if errors.Is(err, amqp.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+func (me *ChannelConnector) IsConnected() bool {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	return me.ch != nil && !me.ch.IsClosed()
+}
+
+func (me *ChannelConnector) GetClient() *amqp.Channel {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	return me.ch
+}
+
+func (me *ChannelConnector) NotifyDisconnect() <-chan error {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	return me.lost
+}
+
+func (me *ChannelConnector) SendPing(ctx context.Context) error {
+	ch := me.GetClient()
+	if ch == nil || ch.IsClosed() {
+		return amqp.ErrClosed
+	}
+	return nil
+}
+
+// IsConnectionError 任何 AMQP 异常都会关闭 channel，需要重新打开
+func (me *ChannelConnector) IsConnectionError(err error) bool {
+	return isAMQPError(err)
+}
+
+func (me *ChannelConnector) Config() reconnect.ReconnectConfig {
+	if me.config.Reconnect == nil {
+		return &reconnect.DefaultReconnectConfig{}
+	}
+	return me.config.Reconnect
+}
+
+// notifyClose 把 NotifyClose 的 *amqp.Error 转换为 error，正常关闭时只关闭 channel
+func notifyClose(closed <-chan *amqp.Error) chan error {
+	lost := make(chan error, 1)
+	go func() {
+		defer close(lost)
+		if err, ok := <-closed; ok && err != nil {
+			lost <- err
+		}
+	}()
+	return lost
+}
+
+func isAMQPError(err error) bool {
+	var amqpErr *amqp.Error
+	return errors.As(err, &amqpErr)
+}
@@ -0,0 +1,95 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/puper/leo/components/rabbitmq/connector/config"
+	"github.com/puper/leo/pkg/reconnect"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestNotifyClose(t *testing.T) {
+	closed := make(chan *amqp.Error, 1)
+	lost := notifyClose(closed)
+	closed <- &amqp.Error{Code: amqp.ConnectionForced, Reason: "shutdown"}
+	if err := <-lost; !isAMQPError(err) {
+		t.Fatalf("lost = %v, want *amqp.Error", err)
+	}
+	if _, ok := <-lost; ok {
+		t.Fatal("lost should be closed after the error")
+	}
+
+	// 正常关闭时不发送错误
+	closed = make(chan *amqp.Error)
+	lost = notifyClose(closed)
+	close(closed)
+	if err, ok := <-lost; ok {
+		t.Fatalf("lost = %v, want closed without error", err)
+	}
+}
+
+func TestIsConnectionError(t *testing.T) {
+	me := New(&config.Config{})
+	if !me.IsConnectionError(amqp.ErrClosed) {
+		t.Fatal("ErrClosed should be a connection error")
+	}
+	if me.IsConnectionError(errors.New("encode message")) {
+		t.Fatal("non-AMQP errors should not trigger reconnect")
+	}
+}
+
+func TestConnect_HandshakeRespectsContext(t *testing.T) {
+	// 只接受连接不响应握手的服务端
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	me := New(&config.Config{Addr: "amqp://guest:guest@" + ln.Addr().String() + "/"})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := me.Connect(ctx); err == nil {
+		t.Fatal("Connect should fail without a handshake")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Connect took %v, should stop when ctx is done", elapsed)
+	}
+}
+
+func TestBuilder_StartTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	_, err = Builder(&config.Config{
+		Addr:         "amqp://guest:guest@" + addr + "/",
+		StartTimeout: 50 * time.Millisecond,
+		Reconnect:    &reconnect.DefaultReconnectConfig{InitialInterval: 10 * time.Millisecond},
+	})()
+	if err == nil {
+		t.Fatal("build should fail when the broker is unreachable")
+	}
+}
+
+func TestChannelBuilder_RequiresConnection(t *testing.T) {
+	if _, err := ChannelBuilder(&config.ChannelConfig{})(); err == nil {
+		t.Fatal("ChannelBuilder should require WithConnection")
+	}
+}
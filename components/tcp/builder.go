@@ -0,0 +1,97 @@
+package tcp
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/puper/leo/components/tcp/config"
+	"github.com/puper/leo/components/tcp/connector"
+	"github.com/puper/leo/engine"
+	"github.com/puper/leo/pkg/reconnect"
+)
+
+const defaultStartTimeout = 10 * time.Second
+
+// ServerBuilder 构建并启动 Server，Close 时优雅关闭所有连接
+func ServerBuilder(cfg *config.ServerConfig, configurers ...func(*Server) error) engine.Builder {
+	return func() (any, error) {
+		me, err := NewServer(cfg, configurers...)
+		if err != nil {
+			return nil, errors.WithMessage(err, "tcp.NewServer")
+		}
+		if err := me.Start(); err != nil {
+			return nil, errors.WithMessage(err, "tcp.Start")
+		}
+		return me, nil
+	}
+}
+
+// ClientBuilder 构建自动重连的 Client，Dial.StartTimeout 内未连接成功时构建失败
+func ClientBuilder(cfg *config.ClientConfig, configurers ...func(*Client) error) engine.Builder {
+	return func() (any, error) {
+		me, err := NewClient(cfg, configurers...)
+		if err != nil {
+			return nil, errors.WithMessage(err, "tcp.NewClient")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), cmp.Or(cfg.Dial.StartTimeout, defaultStartTimeout))
+		defer cancel()
+		if err := me.StartAndWait(ctx); err != nil {
+			return nil, errors.WithMessage(err, "tcp.StartAndWait")
+		}
+		return me, nil
+	}
+}
+
+func WithHandler(handler Handler) func(*Server) error {
+	return func(me *Server) error {
+		me.handler = handler
+		return nil
+	}
+}
+
+// WithCodec 使用自定义的 Codec，忽略配置中的 Codec
+func WithCodec(codec Codec) func(*Server) error {
+	return func(me *Server) error {
+		me.codec = codec
+		return nil
+	}
+}
+
+// WithTLSConfig 使用自定义的 tls.Config，忽略配置中的 CertFile、KeyFile
+func WithTLSConfig(tlsConfig *tls.Config) func(*Server) error {
+	return func(me *Server) error {
+		me.tlsConfig = tlsConfig
+		return nil
+	}
+}
+
+func WithClientHandler(handler Handler) func(*Client) error {
+	return func(me *Client) error {
+		me.connector.handler = handler
+		return nil
+	}
+}
+
+func WithClientCodec(codec Codec) func(*Client) error {
+	return func(me *Client) error {
+		me.connector.codec = codec
+		return nil
+	}
+}
+
+// WithClientTLSConfig 使用自定义的 tls.Config，忽略配置中的 TLS 字段
+func WithClientTLSConfig(tlsConfig *tls.Config) func(*Client) error {
+	return func(me *Client) error {
+		return connector.WithTLSConfig(tlsConfig)(me.connector.Connector)
+	}
+}
+
+func WithClientEventHandler(eventHandler reconnect.EventHandler) func(*Client) error {
+	return func(me *Client) error {
+		me.connector.eventHandler = eventHandler
+		return nil
+	}
+}
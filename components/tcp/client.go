@@ -0,0 +1,128 @@
+package tcp
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/puper/leo/components/tcp/config"
+	"github.com/puper/leo/components/tcp/connector"
+	"github.com/puper/leo/pkg/reconnect"
+)
+
+const defaultCloseTimeout = 5 * time.Second
+
+// Client 是自动重连的分帧客户端，连接由 connector.Connector 建立，
+// 连接断开 (包括 IdleTimeout) 后由 reconnect.Component 重连
+type Client struct {
+	*reconnect.Typed[*Conn]
+	connector *clientConnector
+}
+
+type clientConnector struct {
+	*connector.Connector
+	config       *config.ClientConfig
+	codec        Codec
+	handler      Handler
+	eventHandler reconnect.EventHandler
+	nextID       atomic.Uint64
+
+	mu   sync.RWMutex
+	conn *Conn
+	lost chan error
+}
+
+func NewClient(cfg *config.ClientConfig, configurers ...func(*Client) error) (*Client, error) {
+	codec, err := NewCodec(&cfg.Codec)
+	if err != nil {
+		return nil, err
+	}
+	me := &Client{
+		connector: &clientConnector{
+			Connector: connector.New(&cfg.Dial),
+			config:    cfg,
+			codec:     codec,
+		},
+	}
+	for _, configurer := range configurers {
+		if err := configurer(me); err != nil {
+			return nil, err
+		}
+	}
+	me.Typed = reconnect.NewTyped[*Conn](me.connector, me.connector.eventHandler, me.connector.Config())
+	return me, nil
+}
+
+// Send 在当前连接上发送帧，未连接时等待重连，连接在发送前关闭时重连后重试
+func (me *Client) Send(ctx context.Context, frame []byte) error {
+	return me.GetClient().DoContext(ctx, func(conn *Conn) error {
+		// 重连过程中拿到的连接为 nil，按连接错误处理以等待重连
+		if conn == nil {
+			return ErrConnClosed
+		}
+		return conn.Send(ctx, frame)
+	})
+}
+
+func (me *clientConnector) Connect(ctx context.Context) error {
+	if err := me.Connector.Connect(ctx); err != nil {
+		return err
+	}
+	lost := make(chan error, 1)
+	conn := newConn(me.nextID.Add(1), me.Connector.GetClient(), me.codec, &me.config.Conn, me.handler)
+	conn.onClose = func(_ *Conn, err error) {
+		lost <- cmp.Or(err, ErrConnClosed)
+	}
+	if err := conn.start(); err != nil {
+		me.Connector.Disconnect()
+		return err
+	}
+	me.mu.Lock()
+	me.conn = conn
+	me.lost = lost
+	me.mu.Unlock()
+	return nil
+}
+
+// Disconnect 关闭连接，最多等待 CloseTimeout 写完队列中的帧
+func (me *clientConnector) Disconnect() error {
+	me.mu.Lock()
+	conn := me.conn
+	me.conn = nil
+	me.mu.Unlock()
+	if conn != nil {
+		conn.Close()
+		select {
+		case <-conn.Done():
+		case <-time.After(cmp.Or(me.config.CloseTimeout, defaultCloseTimeout)):
+		}
+	}
+	return me.Connector.Disconnect()
+}
+
+func (me *clientConnector) IsConnected() bool {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	return me.conn != nil
+}
+
+func (me *clientConnector) GetClient() *Conn {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	return me.conn
+}
+
+func (me *clientConnector) NotifyDisconnect() <-chan error {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	return me.lost
+}
+
+// IsConnectionError 只有连接已关闭时刷新连接，ctx 超时、帧过大等错误直接返回
+func (me *clientConnector) IsConnectionError(err error) bool {
+	return errors.Is(err, ErrConnClosed) || errors.Is(err, net.ErrClosed)
+}
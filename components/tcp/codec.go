@@ -0,0 +1,193 @@
+package tcp
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/puper/leo/components/tcp/config"
+)
+
+const defaultMaxFrameSize = 1 << 20
+
+var (
+	ErrFrameTooLarge = errors.New("tcp: frame too large")
+	// ErrDelimiterInFrame DelimiterCodec 写入的帧包含分隔符
+	ErrDelimiterInFrame = errors.New("tcp: delimiter in frame")
+)
+
+// Codec 在字节流上划分帧，空帧用作心跳，不会传给 Handler
+type Codec interface {
+	WriteFrame(w io.Writer, frame []byte) error
+	ReadFrame(r *bufio.Reader) ([]byte, error)
+}
+
+func NewCodec(cfg *config.Codec) (Codec, error) {
+	switch cfg.Type {
+	case "", "length":
+		switch cfg.LengthSize {
+		case 0, 1, 2, 4, 8:
+		default:
+			return nil, fmt.Errorf("tcp: invalid length size %d", cfg.LengthSize)
+		}
+		return &LengthPrefixCodec{LengthSize: cfg.LengthSize, LittleEndian: cfg.LittleEndian, MaxFrameSize: cfg.MaxFrameSize}, nil
+	case "delimiter":
+		return &DelimiterCodec{Delimiter: []byte(cfg.Delimiter), MaxFrameSize: cfg.MaxFrameSize}, nil
+	case "varint":
+		return &VarintCodec{MaxFrameSize: cfg.MaxFrameSize}, nil
+	}
+	return nil, fmt.Errorf("tcp: unknown codec %q", cfg.Type)
+}
+
+// LengthPrefixCodec 每帧以固定长度的无符号整数表示帧长
+type LengthPrefixCodec struct {
+	// LengthSize 1、2、4 或 8，默认 4
+	LengthSize   int
+	LittleEndian bool
+	// MaxFrameSize 默认 1MiB
+	MaxFrameSize int
+}
+
+func (me *LengthPrefixCodec) WriteFrame(w io.Writer, frame []byte) error {
+	size := cmp.Or(me.LengthSize, 4)
+	if len(frame) > cmp.Or(me.MaxFrameSize, defaultMaxFrameSize) || (size < 8 && uint64(len(frame)) >= 1<<(8*size)) {
+		return ErrFrameTooLarge
+	}
+	var header [8]byte
+	order := me.byteOrder()
+	switch size {
+	case 1:
+		header[0] = byte(len(frame))
+	case 2:
+		order.PutUint16(header[:], uint16(len(frame)))
+	case 4:
+		order.PutUint32(header[:], uint32(len(frame)))
+	default:
+		order.PutUint64(header[:], uint64(len(frame)))
+	}
+	if _, err := w.Write(header[:size]); err != nil {
+		return err
+	}
+	_, err := w.Write(frame)
+	return err
+}
+
+func (me *LengthPrefixCodec) ReadFrame(r *bufio.Reader) ([]byte, error) {
+	size := cmp.Or(me.LengthSize, 4)
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:size]); err != nil {
+		return nil, err
+	}
+	var n uint64
+	order := me.byteOrder()
+	switch size {
+	case 1:
+		n = uint64(header[0])
+	case 2:
+		n = uint64(order.Uint16(header[:]))
+	case 4:
+		n = uint64(order.Uint32(header[:]))
+	default:
+		n = order.Uint64(header[:])
+	}
+	return readN(r, n, me.MaxFrameSize)
+}
+
+func (me *LengthPrefixCodec) byteOrder() binary.ByteOrder {
+	if me.LittleEndian {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
+
+// VarintCodec 每帧以 uvarint 表示帧长
+type VarintCodec struct {
+	// MaxFrameSize 默认 1MiB
+	MaxFrameSize int
+}
+
+func (me *VarintCodec) WriteFrame(w io.Writer, frame []byte) error {
+	if len(frame) > cmp.Or(me.MaxFrameSize, defaultMaxFrameSize) {
+		return ErrFrameTooLarge
+	}
+	if _, err := w.Write(binary.AppendUvarint(nil, uint64(len(frame)))); err != nil {
+		return err
+	}
+	_, err := w.Write(frame)
+	return err
+}
+
+func (me *VarintCodec) ReadFrame(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	return readN(r, n, me.MaxFrameSize)
+}
+
+func readN(r *bufio.Reader, n uint64, maxFrameSize int) ([]byte, error) {
+	if n > uint64(cmp.Or(maxFrameSize, defaultMaxFrameSize)) {
+		return nil, ErrFrameTooLarge
+	}
+	frame := make([]byte, n)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+// DelimiterCodec 每帧以分隔符结尾，帧内不能包含分隔符
+type DelimiterCodec struct {
+	// Delimiter 默认 \n
+	Delimiter []byte
+	// MaxFrameSize 默认 1MiB，不含分隔符
+	MaxFrameSize int
+}
+
+func (me *DelimiterCodec) WriteFrame(w io.Writer, frame []byte) error {
+	delim := me.delimiter()
+	if len(frame) > cmp.Or(me.MaxFrameSize, defaultMaxFrameSize) {
+		return ErrFrameTooLarge
+	}
+	if bytes.Contains(frame, delim) {
+		return ErrDelimiterInFrame
+	}
+	if _, err := w.Write(frame); err != nil {
+		return err
+	}
+	_, err := w.Write(delim)
+	return err
+}
+
+func (me *DelimiterCodec) ReadFrame(r *bufio.Reader) ([]byte, error) {
+	delim := me.delimiter()
+	limit := cmp.Or(me.MaxFrameSize, defaultMaxFrameSize) + len(delim)
+	var frame []byte
+	for {
+		chunk, err := r.ReadSlice(delim[len(delim)-1])
+		frame = append(frame, chunk...)
+		if len(frame) > limit {
+			return nil, ErrFrameTooLarge
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if bytes.HasSuffix(frame, delim) {
+			return frame[:len(frame)-len(delim)], nil
+		}
+	}
+}
+
+func (me *DelimiterCodec) delimiter() []byte {
+	if len(me.Delimiter) == 0 {
+		return []byte{'\n'}
+	}
+	return me.Delimiter
+}
@@ -0,0 +1,77 @@
+package tcp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/puper/leo/components/tcp/config"
+)
+
+func TestCodec_RoundTrip(t *testing.T) {
+	cases := map[string]config.Codec{
+		"length":        {},
+		"length2Little": {LengthSize: 2, LittleEndian: true},
+		"varint":        {Type: "varint"},
+		"delimiter":     {Type: "delimiter"},
+		"delimiterCRLF": {Type: "delimiter", Delimiter: "\r\n"},
+	}
+	frames := [][]byte{[]byte("hello"), {}, bytes.Repeat([]byte("x"), 5000), []byte("a\rb")}
+	for name, cfg := range cases {
+		t.Run(name, func(t *testing.T) {
+			codec, err := NewCodec(&cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			for _, frame := range frames {
+				if err := codec.WriteFrame(&buf, frame); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// 小缓冲区覆盖 DelimiterCodec 跨多次 ReadSlice 的情况
+			r := bufio.NewReaderSize(&buf, 16)
+			for _, want := range frames {
+				got, err := codec.ReadFrame(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Fatalf("got %q, want %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCodec_MaxFrameSize(t *testing.T) {
+	for _, typ := range []string{"length", "varint", "delimiter"} {
+		t.Run(typ, func(t *testing.T) {
+			codec, err := NewCodec(&config.Codec{Type: typ, MaxFrameSize: 4})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := codec.WriteFrame(&buf, []byte("12345")); !errors.Is(err, ErrFrameTooLarge) {
+				t.Fatalf("write: %v", err)
+			}
+			large, _ := NewCodec(&config.Codec{Type: typ})
+			large.WriteFrame(&buf, []byte("12345"))
+			if _, err := codec.ReadFrame(bufio.NewReader(&buf)); !errors.Is(err, ErrFrameTooLarge) {
+				t.Fatalf("read: %v", err)
+			}
+		})
+	}
+
+	codec := &LengthPrefixCodec{LengthSize: 1}
+	if err := codec.WriteFrame(&bytes.Buffer{}, make([]byte, 256)); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("length size 1: %v", err)
+	}
+	if err := (&DelimiterCodec{}).WriteFrame(&bytes.Buffer{}, []byte("a\nb")); !errors.Is(err, ErrDelimiterInFrame) {
+		t.Fatalf("delimiter: %v", err)
+	}
+	if _, err := NewCodec(&config.Codec{Type: "unknown"}); err == nil {
+		t.Fatal("expected error for unknown codec")
+	}
+}
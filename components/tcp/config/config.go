@@ -0,0 +1,51 @@
+package config
+
+import (
+	"time"
+
+	connectorconfig "github.com/puper/leo/components/tcp/connector/config"
+)
+
+type Codec struct {
+	// Type length (默认)、delimiter 或 varint
+	Type string `json:"type"`
+	// LengthSize length 的长度字段字节数：1、2、4 或 8，默认 4
+	LengthSize   int  `json:"lengthSize"`
+	LittleEndian bool `json:"littleEndian"`
+	// Delimiter delimiter 的分隔符，默认 \n
+	Delimiter string `json:"delimiter"`
+	// MaxFrameSize 单帧最大字节数，默认 1MiB
+	MaxFrameSize int `json:"maxFrameSize"`
+}
+
+type Conn struct {
+	// WriteQueueSize 每个连接的写队列长度，默认 256
+	WriteQueueSize int           `json:"writeQueueSize"`
+	WriteTimeout   time.Duration `json:"writeTimeout"`
+	// HeartbeatInterval 超过该时长没有写入时发送空帧，0 表示不发送
+	HeartbeatInterval time.Duration `json:"heartbeatInterval"`
+	// IdleTimeout 超过该时长没有读到任何帧 (包括心跳) 时关闭连接，0 表示不限制
+	IdleTimeout time.Duration `json:"idleTimeout"`
+}
+
+type ServerConfig struct {
+	Addr  string `json:"addr"`
+	Codec Codec  `json:"codec"`
+	Conn  Conn   `json:"conn"`
+	// MaxConns 超过时新连接被直接关闭，0 表示不限制
+	MaxConns int `json:"maxConns"`
+	// CertFile、KeyFile 都设置时使用 TLS
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+	// ShutdownTimeout Close 时等待连接发送完写队列的时长，默认 10s
+	ShutdownTimeout time.Duration `json:"shutdownTimeout"`
+}
+
+type ClientConfig struct {
+	// Dial 地址、TLS、StartTimeout 与重连配置
+	Dial  connectorconfig.Config `json:"dial"`
+	Codec Codec                  `json:"codec"`
+	Conn  Conn                   `json:"conn"`
+	// CloseTimeout 断开时等待发送完写队列的时长，默认 5s
+	CloseTimeout time.Duration `json:"closeTimeout"`
+}
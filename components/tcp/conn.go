@@ -0,0 +1,317 @@
+package tcp
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/puper/leo/components/tcp/config"
+)
+
+const defaultWriteQueueSize = 256
+
+var (
+	ErrConnClosed     = errors.New("tcp: connection closed")
+	ErrWriteQueueFull = errors.New("tcp: write queue full")
+	ErrIdleTimeout    = errors.New("tcp: idle timeout")
+)
+
+// Handler 处理收到的帧，同一连接的帧在该连接的读协程中依次调用
+type Handler interface {
+	OnFrame(conn *Conn, frame []byte)
+}
+
+type HandlerFunc func(conn *Conn, frame []byte)
+
+func (f HandlerFunc) OnFrame(conn *Conn, frame []byte) {
+	f(conn, frame)
+}
+
+// ConnectHandler 由 Handler 选择实现，在连接开始读写前调用
+type ConnectHandler interface {
+	OnConnect(conn *Conn)
+}
+
+// CloseHandler 由 Handler 选择实现，在连接的读写协程都退出后调用，本端 Close 时 err 为 nil
+type CloseHandler interface {
+	OnClose(conn *Conn, err error)
+}
+
+// Conn 是按 Codec 分帧的连接，读写各在一个协程中进行，Send 把帧放入写队列
+type Conn struct {
+	id      uint64
+	conn    net.Conn
+	codec   Codec
+	config  *config.Conn
+	handler Handler
+	onClose func(*Conn, error)
+
+	sendCh    chan []byte
+	heartbeat []byte
+	// mu 保证 Close 通知写协程之前进行中的 Send 都已入队
+	mu        sync.RWMutex
+	closed    atomic.Bool
+	closeOnce sync.Once
+	closing   chan struct{}
+	readDone  chan struct{}
+	writeDone chan struct{}
+	done      chan struct{}
+
+	errMu sync.Mutex
+	err   error
+
+	values sync.Map
+}
+
+func newConn(id uint64, nc net.Conn, codec Codec, cfg *config.Conn, handler Handler) *Conn {
+	return &Conn{
+		id:        id,
+		conn:      nc,
+		codec:     codec,
+		config:    cfg,
+		handler:   handler,
+		sendCh:    make(chan []byte, cmp.Or(cfg.WriteQueueSize, defaultWriteQueueSize)),
+		closing:   make(chan struct{}),
+		readDone:  make(chan struct{}),
+		writeDone: make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+func (me *Conn) start() error {
+	heartbeat, err := me.encode(nil)
+	if err != nil {
+		return err
+	}
+	me.heartbeat = heartbeat
+	if h, ok := me.handler.(ConnectHandler); ok {
+		h.OnConnect(me)
+	}
+	go me.readLoop()
+	go me.writeLoop()
+	go func() {
+		<-me.readDone
+		<-me.writeDone
+		err := me.Err()
+		if h, ok := me.handler.(CloseHandler); ok {
+			h.OnClose(me, err)
+		}
+		if me.onClose != nil {
+			me.onClose(me, err)
+		}
+		close(me.done)
+	}()
+	return nil
+}
+
+func (me *Conn) ID() uint64 {
+	return me.id
+}
+
+func (me *Conn) LocalAddr() net.Addr {
+	return me.conn.LocalAddr()
+}
+
+func (me *Conn) RemoteAddr() net.Addr {
+	return me.conn.RemoteAddr()
+}
+
+// NetConn 返回底层连接，不要直接读写
+func (me *Conn) NetConn() net.Conn {
+	return me.conn
+}
+
+// Set 保存与连接关联的数据
+func (me *Conn) Set(key, value any) {
+	me.values.Store(key, value)
+}
+
+func (me *Conn) Get(key any) (any, bool) {
+	return me.values.Load(key)
+}
+
+// Send 把帧放入写队列，队列满时等待。帧超过 Codec 限制时直接返回错误，连接关闭后返回 ErrConnClosed
+func (me *Conn) Send(ctx context.Context, frame []byte) error {
+	data, err := me.encode(frame)
+	if err != nil {
+		return err
+	}
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	// 写协程退出后 sendCh 可能仍有空位，先检查避免帧被静默丢弃
+	if me.closed.Load() || me.writerExited() {
+		return ErrConnClosed
+	}
+	select {
+	case me.sendCh <- data:
+		return nil
+	case <-me.writeDone:
+		return ErrConnClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TrySend 与 Send 相同，但写队列满时返回 ErrWriteQueueFull 而不是等待
+func (me *Conn) TrySend(frame []byte) error {
+	data, err := me.encode(frame)
+	if err != nil {
+		return err
+	}
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	// 写协程退出后 sendCh 可能仍有空位，先检查避免帧被静默丢弃
+	if me.closed.Load() || me.writerExited() {
+		return ErrConnClosed
+	}
+	select {
+	case me.sendCh <- data:
+		return nil
+	case <-me.writeDone:
+		return ErrConnClosed
+	default:
+		return ErrWriteQueueFull
+	}
+}
+
+// Close 不再接受新的帧，写协程写完队列中的帧后关闭连接。Close 不等待，使用 Done 等待关闭完成
+func (me *Conn) Close() error {
+	me.closeOnce.Do(func() {
+		me.closed.Store(true)
+		go func() {
+			me.mu.Lock()
+			close(me.closing)
+			me.mu.Unlock()
+		}()
+	})
+	return nil
+}
+
+// Done 在连接关闭且 CloseHandler 调用完成后关闭
+func (me *Conn) Done() <-chan struct{} {
+	return me.done
+}
+
+// Err 返回连接关闭的原因，本端 Close 或尚未关闭时为 nil
+func (me *Conn) Err() error {
+	me.errMu.Lock()
+	defer me.errMu.Unlock()
+	return me.err
+}
+
+func (me *Conn) writerExited() bool {
+	select {
+	case <-me.writeDone:
+		return true
+	default:
+		return false
+	}
+}
+
+// abort 立即关闭连接，丢弃写队列中的帧
+func (me *Conn) abort(err error) {
+	me.errMu.Lock()
+	if me.err == nil && !me.closed.Load() {
+		me.err = err
+	}
+	me.errMu.Unlock()
+	me.conn.Close()
+}
+
+func (me *Conn) encode(frame []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := me.codec.WriteFrame(&buf, frame); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (me *Conn) readLoop() {
+	defer close(me.readDone)
+	r := bufio.NewReader(me.conn)
+	idleTimeout := me.config.IdleTimeout
+	for {
+		if idleTimeout > 0 {
+			me.conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		}
+		frame, err := me.codec.ReadFrame(r)
+		if err != nil {
+			var netErr net.Error
+			if idleTimeout > 0 && errors.As(err, &netErr) && netErr.Timeout() {
+				err = ErrIdleTimeout
+			}
+			me.abort(err)
+			return
+		}
+		// 空帧是心跳
+		if len(frame) == 0 || me.handler == nil {
+			continue
+		}
+		me.handler.OnFrame(me, frame)
+	}
+}
+
+func (me *Conn) writeLoop() {
+	defer close(me.writeDone)
+	w := bufio.NewWriter(me.conn)
+	var heartbeat <-chan time.Time
+	if interval := me.config.HeartbeatInterval; interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+	lastWrite := time.Now()
+	for {
+		var err error
+		select {
+		case data := <-me.sendCh:
+			me.setWriteDeadline()
+			_, err = w.Write(data)
+			// 已入队的帧合并到一次 Flush
+			for err == nil && len(me.sendCh) > 0 {
+				_, err = w.Write(<-me.sendCh)
+			}
+			if err == nil {
+				err = w.Flush()
+			}
+			lastWrite = time.Now()
+		case <-heartbeat:
+			if time.Since(lastWrite) < me.config.HeartbeatInterval {
+				continue
+			}
+			me.setWriteDeadline()
+			if _, err = w.Write(me.heartbeat); err == nil {
+				err = w.Flush()
+			}
+			lastWrite = time.Now()
+		case <-me.closing:
+			me.setWriteDeadline()
+			for err == nil && len(me.sendCh) > 0 {
+				_, err = w.Write(<-me.sendCh)
+			}
+			if err == nil {
+				err = w.Flush()
+			}
+			me.abort(err)
+			return
+		case <-me.readDone:
+			return
+		}
+		if err != nil {
+			me.abort(err)
+			return
+		}
+	}
+}
+
+func (me *Conn) setWriteDeadline() {
+	if me.config.WriteTimeout > 0 {
+		me.conn.SetWriteDeadline(time.Now().Add(me.config.WriteTimeout))
+	}
+}
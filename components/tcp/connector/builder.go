@@ -0,0 +1,60 @@
+package connector
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/puper/leo/components/tcp/connector/config"
+	"github.com/puper/leo/engine"
+	"github.com/puper/leo/pkg/reconnect"
+)
+
+const defaultStartTimeout = 10 * time.Second
+
+type Component = reconnect.Typed[net.Conn]
+
+// Builder 构建自动重连的 TCP 连接，StartTimeout 内未连接成功时构建失败
+func Builder(cfg *config.Config, configurers ...func(*Connector) error) engine.Builder {
+	return func() (any, error) {
+		me := New(cfg)
+		for _, configurer := range configurers {
+			if err := configurer(me); err != nil {
+				return nil, errors.WithMessage(err, "configurer")
+			}
+		}
+		comp := reconnect.NewTyped[net.Conn](me, me.eventHandler, me.Config())
+		ctx, cancel := context.WithTimeout(context.Background(), cmp.Or(cfg.StartTimeout, defaultStartTimeout))
+		defer cancel()
+		if err := comp.StartAndWait(ctx); err != nil {
+			return nil, errors.WithMessage(err, "tcp.StartAndWait")
+		}
+		return comp, nil
+	}
+}
+
+// WithTLSConfig 使用自定义的 tls.Config，忽略配置中的 TLS 字段
+func WithTLSConfig(tlsConfig *tls.Config) func(*Connector) error {
+	return func(me *Connector) error {
+		me.tlsConfig = tlsConfig
+		return nil
+	}
+}
+
+// WithPing 设置健康检查，需要同时配置 Reconnect.HealthCheckInterval
+func WithPing(ping func(ctx context.Context, conn net.Conn) error) func(*Connector) error {
+	return func(me *Connector) error {
+		me.ping = ping
+		return nil
+	}
+}
+
+func WithEventHandler(eventHandler reconnect.EventHandler) func(*Connector) error {
+	return func(me *Connector) error {
+		me.eventHandler = eventHandler
+		return nil
+	}
+}
@@ -0,0 +1,24 @@
+package config
+
+import (
+	"time"
+
+	"github.com/puper/leo/pkg/reconnect"
+)
+
+type Config struct {
+	Addr string `json:"addr"`
+	// Network 默认 tcp
+	Network     string        `json:"network"`
+	DialTimeout time.Duration `json:"dialTimeout"`
+	// KeepAlive TCP keepalive 间隔，0 使用系统默认，< 0 关闭
+	KeepAlive time.Duration `json:"keepAlive"`
+
+	TLS                bool   `json:"tls"`
+	ServerName         string `json:"serverName"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"`
+
+	// StartTimeout 构建时等待首次连接的时长，默认 10s
+	StartTimeout time.Duration                     `json:"startTimeout"`
+	Reconnect    *reconnect.DefaultReconnectConfig `json:"reconnect"`
+}
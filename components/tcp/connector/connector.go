@@ -0,0 +1,107 @@
+package connector
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/puper/leo/components/tcp/connector/config"
+	"github.com/puper/leo/pkg/reconnect"
+)
+
+// Connector 建立 TCP 或 TLS 连接，配合 reconnect.Component 使用
+type Connector struct {
+	config       *config.Config
+	tlsConfig    *tls.Config
+	ping         func(ctx context.Context, conn net.Conn) error
+	eventHandler reconnect.EventHandler
+
+	mu   sync.RWMutex
+	conn net.Conn
+}
+
+func New(cfg *config.Config) *Connector {
+	return &Connector{config: cfg}
+}
+
+func (me *Connector) Connect(ctx context.Context) error {
+	dialer := &net.Dialer{
+		Timeout:   me.config.DialTimeout,
+		KeepAlive: me.config.KeepAlive,
+	}
+	network := cmp.Or(me.config.Network, "tcp")
+	var (
+		conn net.Conn
+		err  error
+	)
+	if tlsConfig := me.getTLSConfig(); tlsConfig != nil {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, network, me.config.Addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, network, me.config.Addr)
+	}
+	if err != nil {
+		return err
+	}
+	me.mu.Lock()
+	me.conn = conn
+	me.mu.Unlock()
+	return nil
+}
+
+func (me *Connector) Disconnect() error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if me.conn == nil {
+		return nil
+	}
+	err := me.conn.Close()
+	me.conn = nil
+	return err
+}
+
+func (me *Connector) IsConnected() bool {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	return me.conn != nil
+}
+
+func (me *Connector) GetClient() net.Conn {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	return me.conn
+}
+
+// SendPing 调用 WithPing 设置的函数，未设置时只依赖 TCP keepalive 与读写错误发现断线
+func (me *Connector) SendPing(ctx context.Context) error {
+	conn := me.GetClient()
+	if conn == nil {
+		return errors.New("tcp: not connected")
+	}
+	if me.ping == nil {
+		return nil
+	}
+	return me.ping(ctx, conn)
+}
+
+func (me *Connector) Config() reconnect.ReconnectConfig {
+	if me.config.Reconnect == nil {
+		return &reconnect.DefaultReconnectConfig{}
+	}
+	return me.config.Reconnect
+}
+
+func (me *Connector) getTLSConfig() *tls.Config {
+	if me.tlsConfig != nil {
+		return me.tlsConfig
+	}
+	if !me.config.TLS {
+		return nil
+	}
+	return &tls.Config{
+		ServerName:         me.config.ServerName,
+		InsecureSkipVerify: me.config.InsecureSkipVerify,
+	}
+}
@@ -0,0 +1,104 @@
+package connector
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/puper/leo/components/tcp/connector/config"
+	"github.com/puper/leo/pkg/reconnect"
+)
+
+func TestBuilder(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	v, err := Builder(&config.Config{
+		Addr:      ln.Addr().String(),
+		Reconnect: &reconnect.DefaultReconnectConfig{InitialInterval: time.Millisecond},
+	})()
+	if err != nil {
+		t.Fatal(err)
+	}
+	comp := v.(*Component)
+	defer comp.Close()
+
+	server := <-accepted
+	server.Close()
+	// 服务端关闭后第一次读取失败，刷新连接并在新连接上重试
+	err = comp.GetClient().Do(func(conn net.Conn) error {
+		conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+		_, err := conn.Read(make([]byte, 1))
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-accepted:
+	case <-time.After(time.Second):
+		t.Fatal("should reconnect")
+	}
+}
+
+func TestBuilder_StartTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	_, err = Builder(&config.Config{
+		Addr:         addr,
+		StartTimeout: 50 * time.Millisecond,
+		Reconnect:    &reconnect.DefaultReconnectConfig{InitialInterval: 10 * time.Millisecond},
+	})()
+	if err == nil {
+		t.Fatal("build should fail when the server is unreachable")
+	}
+}
+
+func TestConnector_TLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	pinged := false
+	me := New(&config.Config{Addr: server.Listener.Addr().String(), TLS: true, InsecureSkipVerify: true})
+	WithPing(func(ctx context.Context, conn net.Conn) error {
+		pinged = true
+		return nil
+	})(me)
+	if err := me.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer me.Disconnect()
+	if err := me.SendPing(context.Background()); err != nil || !pinged {
+		t.Fatalf("SendPing = %v, pinged = %v", err, pinged)
+	}
+	if _, ok := me.GetClient().(interface{ ConnectionState() tls.ConnectionState }); !ok {
+		t.Fatalf("client should be a TLS connection, got %T", me.GetClient())
+	}
+}
@@ -0,0 +1,88 @@
+package rpc
+
+import (
+	"cmp"
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/puper/leo/components/tcp"
+	"github.com/puper/leo/components/tcp/rpc/config"
+	"github.com/puper/leo/engine"
+)
+
+const defaultStartTimeout = 10 * time.Second
+
+func ServerBuilder(cfg *config.ServerConfig, configurers ...func(*Server) error) engine.Builder {
+	return func() (any, error) {
+		me, err := NewServer(cfg, configurers...)
+		if err != nil {
+			return nil, errors.WithMessage(err, "rpc.NewServer")
+		}
+		if err := me.Start(); err != nil {
+			return nil, errors.WithMessage(err, "rpc.Start")
+		}
+		return me, nil
+	}
+}
+
+// ClientBuilder 构建 Client，TCP.Dial.StartTimeout 内未连接成功时构建失败
+func ClientBuilder(cfg *config.ClientConfig, configurers ...func(*Client) error) engine.Builder {
+	return func() (any, error) {
+		me, err := NewClient(cfg, configurers...)
+		if err != nil {
+			return nil, errors.WithMessage(err, "rpc.NewClient")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), cmp.Or(cfg.TCP.Dial.StartTimeout, defaultStartTimeout))
+		defer cancel()
+		if err := me.StartAndWait(ctx); err != nil {
+			return nil, errors.WithMessage(err, "rpc.StartAndWait")
+		}
+		return me, nil
+	}
+}
+
+func WithHandler(typ string, handler HandlerFunc) func(*Server) error {
+	return func(me *Server) error {
+		me.handlers[typ] = handler
+		return nil
+	}
+}
+
+// WithPayloadCodec 使用自定义的 PayloadCodec，忽略配置中的 PayloadCodec
+func WithPayloadCodec(codec PayloadCodec) func(*Server) error {
+	return func(me *Server) error {
+		me.codec = codec
+		return nil
+	}
+}
+
+// WithServerOptions 传给 tcp.NewServer，如 tcp.WithTLSConfig、tcp.WithCodec
+func WithServerOptions(configurers ...func(*tcp.Server) error) func(*Server) error {
+	return func(me *Server) error {
+		me.tcpConfigurers = append(me.tcpConfigurers, configurers...)
+		return nil
+	}
+}
+
+func WithPushHandler(typ string, handler PushHandlerFunc) func(*Client) error {
+	return func(me *Client) error {
+		me.pushHandlers[typ] = handler
+		return nil
+	}
+}
+
+func WithClientPayloadCodec(codec PayloadCodec) func(*Client) error {
+	return func(me *Client) error {
+		me.codec = codec
+		return nil
+	}
+}
+
+// WithClientOptions 传给 tcp.NewClient，如 tcp.WithClientTLSConfig、tcp.WithClientEventHandler
+func WithClientOptions(configurers ...func(*tcp.Client) error) func(*Client) error {
+	return func(me *Client) error {
+		me.tcpConfigurers = append(me.tcpConfigurers, configurers...)
+		return nil
+	}
+}
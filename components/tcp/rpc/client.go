@@ -0,0 +1,206 @@
+package rpc
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/puper/leo/components/tcp"
+	"github.com/puper/leo/components/tcp/rpc/config"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// ErrConnectionLost 请求已发出，但收到响应前连接断开。请求可能已被处理，因此不会自动重试
+var ErrConnectionLost = errors.New("rpc: connection lost before response")
+
+// RemoteError 是服务端处理请求返回的错误
+type RemoteError struct {
+	Type    string
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	return fmt.Sprintf("rpc: %s: %s", e.Type, e.Message)
+}
+
+// PushHandlerFunc 处理服务端推送，在连接的读协程中依次调用，不要阻塞
+type PushHandlerFunc func(msg *Message)
+
+// Client 在自动重连的 tcp.Client 上按请求 ID 多路复用，多个 Call 共享一个连接
+type Client struct {
+	client         *tcp.Client
+	config         *config.ClientConfig
+	codec          PayloadCodec
+	tcpConfigurers []func(*tcp.Client) error
+	nextID         atomic.Uint64
+
+	mu           sync.RWMutex
+	pending      map[uint64]*call
+	pushHandlers map[string]PushHandlerFunc
+}
+
+type call struct {
+	conn *tcp.Conn
+	// done 收到响应，连接断开时收到 nil
+	done chan *envelope
+}
+
+func NewClient(cfg *config.ClientConfig, configurers ...func(*Client) error) (*Client, error) {
+	codec, err := NewPayloadCodec(cfg.PayloadCodec)
+	if err != nil {
+		return nil, err
+	}
+	me := &Client{
+		config:       cfg,
+		codec:        codec,
+		pending:      make(map[uint64]*call),
+		pushHandlers: make(map[string]PushHandlerFunc),
+	}
+	for _, configurer := range configurers {
+		if err := configurer(me); err != nil {
+			return nil, err
+		}
+	}
+	me.client, err = tcp.NewClient(&cfg.TCP, append(me.tcpConfigurers, tcp.WithClientHandler(me))...)
+	if err != nil {
+		return nil, err
+	}
+	return me, nil
+}
+
+// OnPush 注册 typ 类型推送的处理函数，可在 Start 之后调用
+func (me *Client) OnPush(typ string, handler PushHandlerFunc) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.pushHandlers[typ] = handler
+}
+
+// TCP 返回底层的 tcp.Client，用于 Start、Close、Stats 等，不要直接发送帧
+func (me *Client) TCP() *tcp.Client {
+	return me.client
+}
+
+func (me *Client) StartAndWait(ctx context.Context) error {
+	return me.client.StartAndWait(ctx)
+}
+
+func (me *Client) Close() error {
+	return me.client.Close()
+}
+
+// Call 发送 typ 类型的请求并等待响应，resp 为 nil 时忽略响应负载。
+// ctx 没有 deadline 时使用 Timeout，deadline 随请求发给服务端；ctx 结束时向服务端发送取消帧
+func (me *Client) Call(ctx context.Context, typ string, req, resp any) error {
+	if _, ok := ctx.Deadline(); !ok && me.config.Timeout >= 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cmp.Or(me.config.Timeout, defaultTimeout))
+		defer cancel()
+	}
+	var payload []byte
+	if req != nil {
+		var err error
+		if payload, err = me.codec.Marshal(req); err != nil {
+			return err
+		}
+	}
+	id := me.nextID.Add(1)
+	c := &call{done: make(chan *envelope, 1)}
+	me.mu.Lock()
+	me.pending[id] = c
+	me.mu.Unlock()
+	defer func() {
+		me.mu.Lock()
+		delete(me.pending, id)
+		me.mu.Unlock()
+	}()
+
+	env := &envelope{kind: kindRequest, id: id, typ: typ, payload: payload}
+	if deadline, ok := ctx.Deadline(); ok {
+		env.timeout = max(time.Until(deadline), time.Millisecond)
+	}
+	frame := env.marshal()
+	// Send 在连接关闭时返回 tcp.ErrConnClosed，tcp.Client 重连后在新连接上重试
+	err := me.client.GetClient().DoContext(ctx, func(conn *tcp.Conn) error {
+		if conn == nil {
+			return tcp.ErrConnClosed
+		}
+		me.mu.Lock()
+		c.conn = conn
+		select {
+		case <-c.done:
+		default:
+		}
+		me.mu.Unlock()
+		return conn.Send(ctx, frame)
+	})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case res := <-c.done:
+		switch {
+		case res == nil:
+			return ErrConnectionLost
+		case res.kind == kindError:
+			return &RemoteError{Type: typ, Message: string(res.payload)}
+		case resp != nil && len(res.payload) > 0:
+			return me.codec.Unmarshal(res.payload, resp)
+		}
+		return nil
+	case <-ctx.Done():
+		me.mu.RLock()
+		conn := c.conn
+		me.mu.RUnlock()
+		if conn != nil {
+			conn.TrySend((&envelope{kind: kindCancel, id: id}).marshal())
+		}
+		return ctx.Err()
+	}
+}
+
+func (me *Client) OnFrame(conn *tcp.Conn, frame []byte) {
+	var env envelope
+	if err := env.unmarshal(frame); err != nil {
+		conn.Close()
+		return
+	}
+	switch env.kind {
+	case kindResponse, kindError:
+		me.mu.RLock()
+		c := me.pending[env.id]
+		me.mu.RUnlock()
+		if c != nil {
+			select {
+			case c.done <- &env:
+			default:
+			}
+		}
+	case kindPush:
+		me.mu.RLock()
+		handler := me.pushHandlers[env.typ]
+		me.mu.RUnlock()
+		if handler != nil {
+			handler(&Message{Conn: conn, Type: env.typ, Payload: env.payload, codec: me.codec})
+		}
+	}
+}
+
+// OnClose 让在该连接上等待响应的 Call 返回 ErrConnectionLost
+func (me *Client) OnClose(conn *tcp.Conn, err error) {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	for _, c := range me.pending {
+		if c.conn == conn {
+			select {
+			case c.done <- nil:
+			default:
+			}
+		}
+	}
+}
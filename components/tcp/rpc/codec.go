@@ -0,0 +1,115 @@
+package rpc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// PayloadCodec 编解码请求、响应与推送的负载
+type PayloadCodec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+func NewPayloadCodec(name string) (PayloadCodec, error) {
+	switch name {
+	case "", "json":
+		return JSONCodec{}, nil
+	case "proto":
+		return ProtoCodec{}, nil
+	}
+	return nil, fmt.Errorf("rpc: unknown payload codec %q", name)
+}
+
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// ProtoCodec 只支持 proto.Message
+type ProtoCodec struct{}
+
+func (ProtoCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("rpc: %T is not a proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (ProtoCodec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("rpc: %T is not a proto.Message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+type kind byte
+
+const (
+	kindRequest kind = iota + 1
+	kindResponse
+	// kindError 的负载是错误信息
+	kindError
+	// kindCancel 由客户端在 Call 的 ctx 结束时发送，取消服务端的处理
+	kindCancel
+	// kindPush 由服务端主动发送，不需要响应
+	kindPush
+)
+
+var errMalformed = errors.New("rpc: malformed envelope")
+
+// envelope 是每一帧的格式：kind 1B | id uvarint | timeout(ms) uvarint | len(type) uvarint | type | payload
+type envelope struct {
+	kind    kind
+	id      uint64
+	timeout time.Duration
+	typ     string
+	payload []byte
+}
+
+func (e *envelope) marshal() []byte {
+	buf := make([]byte, 0, 1+3*binary.MaxVarintLen64+len(e.typ)+len(e.payload))
+	buf = append(buf, byte(e.kind))
+	buf = binary.AppendUvarint(buf, e.id)
+	buf = binary.AppendUvarint(buf, uint64(e.timeout.Milliseconds()))
+	buf = binary.AppendUvarint(buf, uint64(len(e.typ)))
+	buf = append(buf, e.typ...)
+	return append(buf, e.payload...)
+}
+
+func (e *envelope) unmarshal(frame []byte) error {
+	if len(frame) == 0 {
+		return errMalformed
+	}
+	e.kind = kind(frame[0])
+	rest := frame[1:]
+	var fields [3]uint64
+	for i := range fields {
+		v, n := binary.Uvarint(rest)
+		if n <= 0 {
+			return errMalformed
+		}
+		fields[i] = v
+		rest = rest[n:]
+	}
+	if fields[2] > uint64(len(rest)) {
+		return errMalformed
+	}
+	e.id = fields[0]
+	e.timeout = time.Duration(fields[1]) * time.Millisecond
+	e.typ = string(rest[:fields[2]])
+	e.payload = rest[fields[2]:]
+	return nil
+}
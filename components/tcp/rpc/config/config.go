@@ -0,0 +1,21 @@
+package config
+
+import (
+	"time"
+
+	tcpconfig "github.com/puper/leo/components/tcp/config"
+)
+
+type ServerConfig struct {
+	TCP tcpconfig.ServerConfig `json:"tcp"`
+	// PayloadCodec json (默认) 或 proto
+	PayloadCodec string `json:"payloadCodec"`
+}
+
+type ClientConfig struct {
+	TCP tcpconfig.ClientConfig `json:"tcp"`
+	// PayloadCodec json (默认) 或 proto
+	PayloadCodec string `json:"payloadCodec"`
+	// Timeout ctx 没有 deadline 时 Call 的超时，默认 10s，< 0 表示不限制
+	Timeout time.Duration `json:"timeout"`
+}
@@ -0,0 +1,224 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/puper/leo/components/tcp/rpc/config"
+	"github.com/puper/leo/pkg/reconnect"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func startPair(t *testing.T, serverCfg *config.ServerConfig, clientCfg *config.ClientConfig, serverConfigurers []func(*Server) error, clientConfigurers ...func(*Client) error) (*Server, *Client) {
+	t.Helper()
+	serverCfg.TCP.Addr = "127.0.0.1:0"
+	v, err := ServerBuilder(serverCfg, serverConfigurers...)()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := v.(*Server)
+	t.Cleanup(func() { server.Close() })
+
+	clientCfg.TCP.Dial.Addr = server.Addr().String()
+	clientCfg.TCP.Dial.Reconnect = &reconnect.DefaultReconnectConfig{InitialInterval: 10 * time.Millisecond}
+	v, err = ClientBuilder(clientCfg, clientConfigurers...)()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := v.(*Client)
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
+
+func echo(ctx context.Context, msg *Message) (any, error) {
+	var s string
+	if err := msg.Bind(&s); err != nil {
+		return nil, err
+	}
+	// 让响应乱序返回
+	time.Sleep(time.Duration(len(s)%5) * time.Millisecond)
+	return s, nil
+}
+
+func TestEnvelope(t *testing.T) {
+	in := &envelope{kind: kindRequest, id: 1 << 40, timeout: 1500 * time.Millisecond, typ: "echo", payload: []byte("data")}
+	var out envelope
+	if err := out.unmarshal(in.marshal()); err != nil {
+		t.Fatal(err)
+	}
+	if out.kind != in.kind || out.id != in.id || out.timeout != in.timeout || out.typ != in.typ || string(out.payload) != "data" {
+		t.Fatalf("got %+v", out)
+	}
+	if err := out.unmarshal([]byte{byte(kindRequest), 1, 0, 10, 'a'}); !errors.Is(err, errMalformed) {
+		t.Fatalf("expected errMalformed, got %v", err)
+	}
+}
+
+func TestCall_Concurrent(t *testing.T) {
+	server, client := startPair(t, &config.ServerConfig{}, &config.ClientConfig{}, []func(*Server) error{WithHandler("echo", echo)})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			want := fmt.Sprint("msg-", i)
+			var got string
+			if err := client.Call(context.Background(), "echo", want, &got); err != nil {
+				errs <- err
+			} else if got != want {
+				errs <- fmt.Errorf("got %q, want %q", got, want)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if n := server.TCP().Len(); n != 1 {
+		t.Fatalf("server has %d conns", n)
+	}
+}
+
+func TestCall_RemoteError(t *testing.T) {
+	_, client := startPair(t, &config.ServerConfig{}, &config.ClientConfig{}, []func(*Server) error{
+		WithHandler("fail", func(ctx context.Context, msg *Message) (any, error) {
+			return nil, errors.New("boom")
+		}),
+		WithHandler("panic", func(ctx context.Context, msg *Message) (any, error) {
+			panic("oops")
+		}),
+	})
+
+	var remote *RemoteError
+	if err := client.Call(context.Background(), "fail", nil, nil); !errors.As(err, &remote) || remote.Message != "boom" {
+		t.Fatalf("fail: %v", err)
+	}
+	if err := client.Call(context.Background(), "panic", nil, nil); !errors.As(err, &remote) || remote.Message != "panic: oops" {
+		t.Fatalf("panic: %v", err)
+	}
+	if err := client.Call(context.Background(), "missing", nil, nil); !errors.As(err, &remote) {
+		t.Fatalf("missing: %v", err)
+	}
+}
+
+func TestCall_CancelAndTimeout(t *testing.T) {
+	handlerErrs := make(chan error, 2)
+	_, client := startPair(t, &config.ServerConfig{}, &config.ClientConfig{}, []func(*Server) error{
+		WithHandler("block", func(ctx context.Context, msg *Message) (any, error) {
+			<-ctx.Done()
+			handlerErrs <- ctx.Err()
+			return nil, ctx.Err()
+		}),
+	})
+
+	// ctx 取消时发送取消帧，服务端在自己的 deadline 之前结束
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if err := client.Call(ctx, "block", nil, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancel: %v", err)
+	}
+	select {
+	case err := <-handlerErrs:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("handler: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler not cancelled")
+	}
+
+	// deadline 随请求发给服务端
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := client.Call(ctx, "block", nil, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("timeout: %v", err)
+	}
+	select {
+	case <-handlerErrs:
+	case <-time.After(time.Second):
+		t.Fatal("handler not timed out")
+	}
+}
+
+func TestCall_ConnectionLost(t *testing.T) {
+	started := make(chan struct{}, 1)
+	server, client := startPair(t, &config.ServerConfig{}, &config.ClientConfig{}, []func(*Server) error{
+		WithHandler("block", func(ctx context.Context, msg *Message) (any, error) {
+			started <- struct{}{}
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}),
+		WithHandler("echo", echo),
+	})
+
+	go func() {
+		<-started
+		for _, conn := range server.TCP().Conns() {
+			conn.Close()
+		}
+	}()
+	if err := client.Call(context.Background(), "block", nil, nil); !errors.Is(err, ErrConnectionLost) {
+		t.Fatalf("expected ErrConnectionLost, got %v", err)
+	}
+	// 重连后继续可用
+	var got string
+	if err := client.Call(context.Background(), "echo", "again", &got); err != nil || got != "again" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+}
+
+func TestPush(t *testing.T) {
+	pushed := make(chan string, 1)
+	server, _ := startPair(t, &config.ServerConfig{}, &config.ClientConfig{}, nil,
+		WithPushHandler("tick", func(msg *Message) {
+			var s string
+			msg.Bind(&s)
+			pushed <- s
+		}))
+
+	// 客户端连接成功时服务端可能还未注册该连接
+	deadline := time.Now().Add(2 * time.Second)
+	for server.TCP().Len() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := server.Broadcast("tick", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-pushed:
+		if got != "hello" {
+			t.Fatalf("got %q", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("push not received")
+	}
+}
+
+func TestProtoCodec(t *testing.T) {
+	_, client := startPair(t, &config.ServerConfig{PayloadCodec: "proto"}, &config.ClientConfig{PayloadCodec: "proto"}, []func(*Server) error{
+		WithHandler("double", func(ctx context.Context, msg *Message) (any, error) {
+			var d durationpb.Duration
+			if err := msg.Bind(&d); err != nil {
+				return nil, err
+			}
+			return durationpb.New(2 * d.AsDuration()), nil
+		}),
+	})
+
+	var resp durationpb.Duration
+	if err := client.Call(context.Background(), "double", durationpb.New(time.Second), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.AsDuration() != 2*time.Second {
+		t.Fatalf("got %v", resp.AsDuration())
+	}
+	if err := client.Call(context.Background(), "double", "not proto", nil); err == nil {
+		t.Fatal("expected marshal error")
+	}
+}
@@ -0,0 +1,241 @@
+package rpc
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/puper/leo/components/tcp"
+	"github.com/puper/leo/components/tcp/rpc/config"
+)
+
+const defaultShutdownTimeout = 10 * time.Second
+
+// Message 是收到的请求或推送
+type Message struct {
+	Conn    *tcp.Conn
+	Type    string
+	Payload []byte
+	codec   PayloadCodec
+}
+
+// Bind 使用 PayloadCodec 解码负载
+func (m *Message) Bind(v any) error {
+	return m.codec.Unmarshal(m.Payload, v)
+}
+
+// HandlerFunc 处理一种类型的请求，返回值编码后作为响应，返回 error 时调用方收到 RemoteError。
+// 客户端取消或超时后 ctx 结束，此时不再发送响应
+type HandlerFunc func(ctx context.Context, msg *Message) (any, error)
+
+// Server 在 tcp.Server 上按请求 ID 多路复用，每个请求在单独的协程中处理
+type Server struct {
+	server         *tcp.Server
+	config         *config.ServerConfig
+	codec          PayloadCodec
+	tcpConfigurers []func(*tcp.Server) error
+
+	mu       sync.RWMutex
+	handlers map[string]HandlerFunc
+	closing  bool
+	// wg 等待处理中的请求
+	wg sync.WaitGroup
+}
+
+type inflightKey struct{}
+
+// inflight 记录一个连接上处理中的请求，用于响应取消帧与连接关闭
+type inflight struct {
+	mu      sync.Mutex
+	cancels map[uint64]context.CancelFunc
+}
+
+func NewServer(cfg *config.ServerConfig, configurers ...func(*Server) error) (*Server, error) {
+	codec, err := NewPayloadCodec(cfg.PayloadCodec)
+	if err != nil {
+		return nil, err
+	}
+	me := &Server{
+		config:   cfg,
+		codec:    codec,
+		handlers: make(map[string]HandlerFunc),
+	}
+	for _, configurer := range configurers {
+		if err := configurer(me); err != nil {
+			return nil, err
+		}
+	}
+	me.server, err = tcp.NewServer(&cfg.TCP, append(me.tcpConfigurers, tcp.WithHandler(me))...)
+	if err != nil {
+		return nil, err
+	}
+	return me, nil
+}
+
+// Handle 注册 typ 类型请求的处理函数，可在 Start 之后调用
+func (me *Server) Handle(typ string, handler HandlerFunc) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.handlers[typ] = handler
+}
+
+func (me *Server) Start() error {
+	return me.server.Start()
+}
+
+func (me *Server) Addr() net.Addr {
+	return me.server.Addr()
+}
+
+// TCP 返回底层的 tcp.Server，不要直接在其连接上发送帧
+func (me *Server) TCP() *tcp.Server {
+	return me.server
+}
+
+// Push 向连接推送消息
+func (me *Server) Push(ctx context.Context, conn *tcp.Conn, typ string, v any) error {
+	payload, err := me.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return conn.Send(ctx, (&envelope{kind: kindPush, typ: typ, payload: payload}).marshal())
+}
+
+// Broadcast 使用 TrySend 向所有连接推送消息
+func (me *Server) Broadcast(typ string, v any) error {
+	payload, err := me.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return me.server.Broadcast((&envelope{kind: kindPush, typ: typ, payload: payload}).marshal())
+}
+
+// Shutdown 拒绝新的请求，等待处理中的请求完成后关闭 tcp.Server，ctx 结束时取消剩余的请求
+func (me *Server) Shutdown(ctx context.Context) error {
+	me.mu.Lock()
+	me.closing = true
+	me.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		me.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	return me.server.Shutdown(ctx)
+}
+
+// Close 调用 Shutdown，最多等待 TCP.ShutdownTimeout
+func (me *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), cmp.Or(me.config.TCP.ShutdownTimeout, defaultShutdownTimeout))
+	defer cancel()
+	return me.Shutdown(ctx)
+}
+
+func (me *Server) OnConnect(conn *tcp.Conn) {
+	conn.Set(inflightKey{}, &inflight{cancels: make(map[uint64]context.CancelFunc)})
+}
+
+func (me *Server) OnFrame(conn *tcp.Conn, frame []byte) {
+	var env envelope
+	if err := env.unmarshal(frame); err != nil {
+		conn.Close()
+		return
+	}
+	v, _ := conn.Get(inflightKey{})
+	in := v.(*inflight)
+	switch env.kind {
+	case kindRequest:
+		me.serve(conn, in, &env)
+	case kindCancel:
+		in.mu.Lock()
+		cancel := in.cancels[env.id]
+		in.mu.Unlock()
+		if cancel != nil {
+			cancel()
+		}
+	}
+}
+
+// OnClose 取消连接上所有处理中的请求
+func (me *Server) OnClose(conn *tcp.Conn, err error) {
+	v, _ := conn.Get(inflightKey{})
+	in := v.(*inflight)
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	for _, cancel := range in.cancels {
+		cancel()
+	}
+}
+
+func (me *Server) serve(conn *tcp.Conn, in *inflight, req *envelope) {
+	me.mu.RLock()
+	handler := me.handlers[req.typ]
+	closing := me.closing
+	if !closing {
+		me.wg.Add(1)
+	}
+	me.mu.RUnlock()
+	if closing {
+		me.respond(conn, req, nil, fmt.Errorf("server shutting down"))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if req.timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), req.timeout)
+	}
+	in.mu.Lock()
+	in.cancels[req.id] = cancel
+	in.mu.Unlock()
+
+	go func() {
+		defer me.wg.Done()
+		defer func() {
+			in.mu.Lock()
+			delete(in.cancels, req.id)
+			in.mu.Unlock()
+			cancel()
+		}()
+		var (
+			resp any
+			err  error
+		)
+		if handler == nil {
+			err = fmt.Errorf("unknown message type %q", req.typ)
+		} else {
+			resp, err = me.call(ctx, handler, &Message{Conn: conn, Type: req.typ, Payload: req.payload, codec: me.codec})
+		}
+		// 调用方已取消或超时，不再等待响应
+		if ctx.Err() != nil {
+			return
+		}
+		me.respond(conn, req, resp, err)
+	}()
+}
+
+func (me *Server) call(ctx context.Context, handler HandlerFunc, msg *Message) (resp any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, msg)
+}
+
+func (me *Server) respond(conn *tcp.Conn, req *envelope, resp any, err error) {
+	out := &envelope{kind: kindResponse, id: req.id, typ: req.typ}
+	if err == nil && resp != nil {
+		out.payload, err = me.codec.Marshal(resp)
+	}
+	if err != nil {
+		out.kind = kindError
+		out.payload = []byte(err.Error())
+	}
+	conn.Send(context.Background(), out.marshal())
+}
@@ -0,0 +1,216 @@
+package tcp
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/puper/leo/components/tcp/config"
+)
+
+const defaultShutdownTimeout = 10 * time.Second
+
+var ErrServerClosed = errors.New("tcp: server closed")
+
+// Server 接受连接并为每个连接创建 Conn，连接关闭后从 Server 中移除
+type Server struct {
+	config    *config.ServerConfig
+	codec     Codec
+	handler   Handler
+	tlsConfig *tls.Config
+
+	nextID atomic.Uint64
+
+	mu       sync.RWMutex
+	listener net.Listener
+	conns    map[uint64]*Conn
+	closed   bool
+	// wg 等待接受连接的协程与所有连接
+	wg sync.WaitGroup
+}
+
+func NewServer(cfg *config.ServerConfig, configurers ...func(*Server) error) (*Server, error) {
+	codec, err := NewCodec(&cfg.Codec)
+	if err != nil {
+		return nil, err
+	}
+	me := &Server{
+		config: cfg,
+		codec:  codec,
+		conns:  make(map[uint64]*Conn),
+	}
+	for _, configurer := range configurers {
+		if err := configurer(me); err != nil {
+			return nil, err
+		}
+	}
+	if me.tlsConfig == nil && cfg.CertFile != "" && cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		me.tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+	return me, nil
+}
+
+// Start 监听 Addr 并在后台接受连接
+func (me *Server) Start() error {
+	listener, err := net.Listen("tcp", me.config.Addr)
+	if err != nil {
+		return err
+	}
+	if me.tlsConfig != nil {
+		listener = tls.NewListener(listener, me.tlsConfig)
+	}
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if me.closed {
+		listener.Close()
+		return ErrServerClosed
+	}
+	if me.listener != nil {
+		listener.Close()
+		return errors.New("tcp: server already started")
+	}
+	me.listener = listener
+	me.wg.Add(1)
+	go me.serve(listener)
+	return nil
+}
+
+// Addr 返回监听地址，未 Start 时返回 nil
+func (me *Server) Addr() net.Addr {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	if me.listener == nil {
+		return nil
+	}
+	return me.listener.Addr()
+}
+
+func (me *Server) Conn(id uint64) (*Conn, bool) {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	conn, ok := me.conns[id]
+	return conn, ok
+}
+
+func (me *Server) Conns() []*Conn {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	conns := make([]*Conn, 0, len(me.conns))
+	for _, conn := range me.conns {
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+func (me *Server) Len() int {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	return len(me.conns)
+}
+
+// Broadcast 使用 TrySend 向所有连接发送帧，返回发送失败的连接的错误
+func (me *Server) Broadcast(frame []byte) error {
+	var errs []error
+	for _, conn := range me.Conns() {
+		if err := conn.TrySend(frame); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Shutdown 停止接受连接并 Close 所有连接，等待它们写完队列中的帧。
+// ctx 结束时立即关闭剩余的连接并返回 ctx.Err()
+func (me *Server) Shutdown(ctx context.Context) error {
+	me.mu.Lock()
+	me.closed = true
+	listener := me.listener
+	conns := make([]*Conn, 0, len(me.conns))
+	for _, conn := range me.conns {
+		conns = append(conns, conn)
+	}
+	me.mu.Unlock()
+
+	if listener != nil {
+		listener.Close()
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+	done := make(chan struct{})
+	go func() {
+		me.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		for _, conn := range conns {
+			conn.abort(ctx.Err())
+		}
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Close 调用 Shutdown，最多等待 ShutdownTimeout
+func (me *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), cmp.Or(me.config.ShutdownTimeout, defaultShutdownTimeout))
+	defer cancel()
+	return me.Shutdown(ctx)
+}
+
+func (me *Server) serve(listener net.Listener) {
+	defer me.wg.Done()
+	var delay time.Duration
+	for {
+		nc, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			// 与 net/http 相同，Accept 出错 (如文件描述符耗尽) 时退避后重试
+			delay = min(max(2*delay, 5*time.Millisecond), time.Second)
+			time.Sleep(delay)
+			continue
+		}
+		delay = 0
+		me.accept(nc)
+	}
+}
+
+func (me *Server) accept(nc net.Conn) {
+	conn := newConn(me.nextID.Add(1), nc, me.codec, &me.config.Conn, me.handler)
+	conn.onClose = func(conn *Conn, _ error) {
+		me.mu.Lock()
+		delete(me.conns, conn.id)
+		me.mu.Unlock()
+		me.wg.Done()
+	}
+	me.mu.Lock()
+	if me.closed || (me.config.MaxConns > 0 && len(me.conns) >= me.config.MaxConns) {
+		me.mu.Unlock()
+		nc.Close()
+		return
+	}
+	me.conns[conn.id] = conn
+	me.wg.Add(1)
+	me.mu.Unlock()
+	if err := conn.start(); err != nil {
+		me.mu.Lock()
+		delete(me.conns, conn.id)
+		me.mu.Unlock()
+		me.wg.Done()
+		nc.Close()
+	}
+}
@@ -0,0 +1,253 @@
+package tcp
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/puper/leo/components/tcp/config"
+	connectorconfig "github.com/puper/leo/components/tcp/connector/config"
+	"github.com/puper/leo/pkg/reconnect"
+)
+
+type recordHandler struct {
+	frames chan string
+	mu     sync.Mutex
+	opened int
+	closed []error
+	echo   bool
+}
+
+func newRecordHandler(echo bool) *recordHandler {
+	return &recordHandler{frames: make(chan string, 100), echo: echo}
+}
+
+func (h *recordHandler) OnConnect(conn *Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.opened++
+}
+
+func (h *recordHandler) OnFrame(conn *Conn, frame []byte) {
+	h.frames <- string(frame)
+	if h.echo {
+		conn.Send(context.Background(), frame)
+	}
+}
+
+func (h *recordHandler) OnClose(conn *Conn, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = append(h.closed, err)
+}
+
+func (h *recordHandler) closeErrs() []error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]error(nil), h.closed...)
+}
+
+func startServer(t *testing.T, cfg *config.ServerConfig, configurers ...func(*Server) error) *Server {
+	t.Helper()
+	cfg.Addr = "127.0.0.1:0"
+	v, err := ServerBuilder(cfg, configurers...)()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := v.(*Server)
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+func startClient(t *testing.T, server *Server, cfg *config.ClientConfig, configurers ...func(*Client) error) *Client {
+	t.Helper()
+	cfg.Dial.Addr = server.Addr().String()
+	cfg.Dial.Reconnect = &reconnect.DefaultReconnectConfig{InitialInterval: 10 * time.Millisecond}
+	v, err := ClientBuilder(cfg, configurers...)()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := v.(*Client)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func receive(t *testing.T, frames chan string) string {
+	t.Helper()
+	select {
+	case frame := <-frames:
+		return frame
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for frame")
+	}
+	return ""
+}
+
+func waitFor(t *testing.T, cond func() bool, msg string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestServer_Echo(t *testing.T) {
+	serverHandler := newRecordHandler(true)
+	server := startServer(t, &config.ServerConfig{Codec: config.Codec{Type: "varint"}}, WithHandler(serverHandler))
+	clientHandler := newRecordHandler(false)
+	client := startClient(t, server, &config.ClientConfig{Codec: config.Codec{Type: "varint"}}, WithClientHandler(clientHandler))
+
+	for i := range 10 {
+		if err := client.Send(context.Background(), []byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range 10 {
+		if got := receive(t, clientHandler.frames); got != fmt.Sprint(i) {
+			t.Fatalf("got %q, want %d", got, i)
+		}
+	}
+	if server.Len() != 1 {
+		t.Fatalf("server has %d conns", server.Len())
+	}
+
+	client.Close()
+	waitFor(t, func() bool { return server.Len() == 0 }, "server conn not removed")
+	if errs := serverHandler.closeErrs(); len(errs) != 1 || !errors.Is(errs[0], io.EOF) {
+		t.Fatalf("server close errs: %v", errs)
+	}
+}
+
+func TestServer_IdleTimeoutAndHeartbeat(t *testing.T) {
+	serverHandler := newRecordHandler(false)
+	server := startServer(t, &config.ServerConfig{Conn: config.Conn{IdleTimeout: 100 * time.Millisecond}}, WithHandler(serverHandler))
+	startClient(t, server, &config.ClientConfig{Conn: config.Conn{HeartbeatInterval: 20 * time.Millisecond}})
+
+	raw, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+
+	waitFor(t, func() bool { return len(serverHandler.closeErrs()) == 1 }, "idle conn not closed")
+	if errs := serverHandler.closeErrs(); !errors.Is(errs[0], ErrIdleTimeout) {
+		t.Fatalf("close err: %v", errs[0])
+	}
+	// 发送心跳的客户端保持连接，心跳不会传给 Handler
+	time.Sleep(200 * time.Millisecond)
+	if server.Len() != 1 || len(serverHandler.frames) != 0 {
+		t.Fatalf("conns %d, frames %d", server.Len(), len(serverHandler.frames))
+	}
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	const n = 100
+	connected := make(chan *Conn, 1)
+	server := startServer(t, &config.ServerConfig{}, WithHandler(connectHandler(func(conn *Conn) { connected <- conn })))
+
+	raw, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	conn := <-connected
+	for i := range n {
+		if err := conn.TrySend([]byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.TrySend([]byte("late")); !errors.Is(err, ErrConnClosed) {
+		t.Fatalf("send after shutdown: %v", err)
+	}
+
+	// 写队列中的帧在关闭前全部写出
+	r := bufio.NewReader(raw)
+	codec := &LengthPrefixCodec{}
+	for i := range n {
+		frame, err := codec.ReadFrame(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(frame) != fmt.Sprint(i) {
+			t.Fatalf("got %q, want %d", frame, i)
+		}
+	}
+	if _, err := codec.ReadFrame(r); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+}
+
+type connectHandler func(conn *Conn)
+
+func (f connectHandler) OnConnect(conn *Conn) {
+	f(conn)
+}
+
+func (f connectHandler) OnFrame(conn *Conn, frame []byte) {}
+
+func TestClient_Reconnect(t *testing.T) {
+	serverHandler := newRecordHandler(false)
+	server := startServer(t, &config.ServerConfig{}, WithHandler(serverHandler))
+	client := startClient(t, server, &config.ClientConfig{})
+
+	if err := client.Send(context.Background(), []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, serverHandler.frames)
+	for _, conn := range server.Conns() {
+		conn.Close()
+	}
+	waitFor(t, func() bool { return client.Stats().Reconnects == 1 }, "client not reconnected")
+
+	if err := client.Send(context.Background(), []byte("second")); err != nil {
+		t.Fatal(err)
+	}
+	if got := receive(t, serverHandler.frames); got != "second" {
+		t.Fatalf("got %q", got)
+	}
+	serverHandler.mu.Lock()
+	defer serverHandler.mu.Unlock()
+	if serverHandler.opened != 2 {
+		t.Fatalf("server opened %d conns", serverHandler.opened)
+	}
+}
+
+func TestServer_TLS(t *testing.T) {
+	ts := httptest.NewUnstartedServer(nil)
+	ts.StartTLS()
+	cert := ts.TLS.Certificates[0]
+	ts.Close()
+
+	serverHandler := newRecordHandler(true)
+	server := startServer(t, &config.ServerConfig{Codec: config.Codec{Type: "delimiter"}},
+		WithHandler(serverHandler), WithTLSConfig(&tls.Config{Certificates: []tls.Certificate{cert}}))
+	clientHandler := newRecordHandler(false)
+	client := startClient(t, server, &config.ClientConfig{
+		Dial:  connectorconfig.Config{TLS: true, InsecureSkipVerify: true},
+		Codec: config.Codec{Type: "delimiter"},
+	}, WithClientHandler(clientHandler))
+
+	if err := client.Send(context.Background(), []byte("secure")); err != nil {
+		t.Fatal(err)
+	}
+	if got := receive(t, clientHandler.frames); got != "secure" {
+		t.Fatalf("got %q", got)
+	}
+	if _, ok := client.GetClient().Raw().NetConn().(*tls.Conn); !ok {
+		t.Fatal("client conn is not TLS")
+	}
+}
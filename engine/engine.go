@@ -7,6 +7,7 @@ import (
 	"sync"
 	"syscall"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 )
 
@@ -32,6 +33,9 @@ type Engine struct {
 	instances sync.Map
 	config    *Config
 	graph     *graph
+
+	watchMutex sync.RWMutex
+	watchers   []func(in fsnotify.Event)
 }
 
 func (me *Engine) Register(name string, builder Builder, dependencies ...string) {
@@ -104,6 +108,26 @@ func (me *Engine) GetConfig() *Config {
 	return me.config
 }
 
+// OnConfigChange 订阅配置文件变化，回调按注册顺序执行。viper 只保留一个 OnConfigChange 回调，
+// 组件应通过此方法订阅而不是直接调用 GetConfig().OnConfigChange。调用方仍需执行 GetConfig().WatchConfig()。
+func (me *Engine) OnConfigChange(f func(in fsnotify.Event)) {
+	me.watchMutex.Lock()
+	defer me.watchMutex.Unlock()
+	if len(me.watchers) == 0 && me.config != nil {
+		me.config.OnConfigChange(me.notifyConfigChange)
+	}
+	me.watchers = append(me.watchers, f)
+}
+
+func (me *Engine) notifyConfigChange(in fsnotify.Event) {
+	me.watchMutex.RLock()
+	watchers := me.watchers
+	me.watchMutex.RUnlock()
+	for _, f := range watchers {
+		f(in)
+	}
+}
+
 func (me *Engine) Get(name string) any {
 	if instance, ok := me.instances.Load(name); ok {
 		return instance
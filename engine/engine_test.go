@@ -2,7 +2,13 @@ package engine
 
 import (
 	"errors"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
 )
 
 type failingCloser struct {
@@ -75,3 +81,41 @@ func TestEngineGetPanicsWhenNotFound(t *testing.T) {
 	e.Get("nonexistent")
 	t.Log("BUG or FEATURE: Get() should panic when component not found")
 }
+
+func TestOnConfigChangeFansOut(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(file, []byte(`{"a": 1}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	v := viper.New()
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+	e := New(v)
+	first := make(chan struct{}, 1)
+	second := make(chan struct{}, 1)
+	e.OnConfigChange(func(fsnotify.Event) {
+		select {
+		case first <- struct{}{}:
+		default:
+		}
+	})
+	e.OnConfigChange(func(fsnotify.Event) {
+		select {
+		case second <- struct{}{}:
+		default:
+		}
+	})
+	v.WatchConfig()
+	if err := os.WriteFile(file, []byte(`{"a": 2}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for name, ch := range map[string]chan struct{}{"first": first, "second": second} {
+		select {
+		case <-ch:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s listener was not notified", name)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package mutexmanager
 
 import (
+	"context"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 var (
@@ -18,10 +21,49 @@ func Default() *MutexManager {
 	return defaultMutexManager
 }
 
+// Mutex 是单个 key 的读写锁，状态由 MutexManager.mutex 保护，等待可被 ctx 取消。
+// 与 sync.RWMutex 相同，有写锁在等待时新的读锁也需要等待。
 type Mutex struct {
-	sync.RWMutex
+	// locks、rlocks 为等待与持有写锁、读锁的数量，都为 0 时从 MutexManager 中删除
 	locks  int64
 	rlocks int64
+
+	writer         bool
+	readers        int64
+	waitingWriters int64
+	// wake 在锁释放或等待者放弃时关闭并替换，唤醒所有等待者重新检查
+	wake chan struct{}
+}
+
+func (m *Mutex) canLock() bool {
+	return !m.writer && m.readers == 0
+}
+
+func (m *Mutex) canRLock() bool {
+	return !m.writer && m.waitingWriters == 0
+}
+
+// Handle 是一次加锁的句柄，只能释放它自己持有的锁，重复 Unlock 无效
+type Handle struct {
+	manager *MutexManager
+	key     string
+	mutex   *Mutex
+	read    bool
+	once    sync.Once
+}
+
+func (h *Handle) Key() string {
+	return h.key
+}
+
+func (h *Handle) Unlock() {
+	h.once.Do(func() {
+		if h.read {
+			h.manager.runlock(h.key, h.mutex)
+		} else {
+			h.manager.unlock(h.key, h.mutex)
+		}
+	})
 }
 
 func New() *MutexManager {
@@ -33,95 +75,184 @@ func New() *MutexManager {
 type MutexManager struct {
 	mutex   sync.Mutex
 	mutexes map[string]*Mutex
+	// tokens 为 Acquire 生成 fencing token
+	tokens atomic.Int64
 }
 
 func (me *MutexManager) Lock(key string) {
-	me.mutex.Lock()
-	if _, ok := me.mutexes[key]; !ok {
-		me.mutexes[key] = &Mutex{}
+	me.lock(context.Background(), key)
+}
+
+func (me *MutexManager) Unlock(key string) {
+	me.unlock(key, nil)
+}
+
+func (me *MutexManager) RLock(key string) {
+	me.rlock(context.Background(), key)
+}
+
+func (me *MutexManager) RUnlock(key string) {
+	me.runlock(key, nil)
+}
+
+// LockContext 获取写锁，ctx 结束前未获取到时返回 ctx.Err()
+func (me *MutexManager) LockContext(ctx context.Context, key string) (*Handle, error) {
+	m, err := me.lock(ctx, key)
+	if err != nil {
+		return nil, err
 	}
-	me.mutexes[key].locks++
-	m := me.mutexes[key]
-	me.mutex.Unlock()
-	m.Lock()
+	return &Handle{manager: me, key: key, mutex: m}, nil
+}
 
+// RLockContext 获取读锁，ctx 结束前未获取到时返回 ctx.Err()
+func (me *MutexManager) RLockContext(ctx context.Context, key string) (*Handle, error) {
+	m, err := me.rlock(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return &Handle{manager: me, key: key, mutex: m, read: true}, nil
 }
 
-func (me *MutexManager) Unlock(key string) {
+// TryLockFor 最多等待 d 获取写锁
+func (me *MutexManager) TryLockFor(key string, d time.Duration) (*Handle, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	h, err := me.LockContext(ctx, key)
+	return h, err == nil
+}
+
+// TryRLockFor 最多等待 d 获取读锁
+func (me *MutexManager) TryRLockFor(key string, d time.Duration) (*Handle, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	h, err := me.RLockContext(ctx, key)
+	return h, err == nil
+}
+
+func (me *MutexManager) TryLock(key string) bool {
+	_, ok := me.tryLock(key)
+	return ok
+}
+
+func (me *MutexManager) TryRLock(key string) bool {
 	me.mutex.Lock()
-	if _, ok := me.mutexes[key]; ok {
-		me.mutexes[key].Unlock()
-		me.mutexes[key].locks--
-		if me.mutexes[key].locks == 0 && me.mutexes[key].rlocks == 0 {
-			delete(me.mutexes, key)
-		}
-		me.mutex.Unlock()
-	} else {
-		me.mutex.Unlock()
-		panic("unlock of unlocked mutex")
+	defer me.mutex.Unlock()
+	m := me.getLocked(key)
+	if !m.canRLock() {
+		me.releaseLocked(key, m)
+		return false
 	}
+	m.rlocks++
+	m.readers++
+	return true
 }
 
-func (me *MutexManager) RLock(key string) {
+func (me *MutexManager) tryLock(key string) (*Mutex, bool) {
 	me.mutex.Lock()
-	if _, ok := me.mutexes[key]; !ok {
-		me.mutexes[key] = &Mutex{}
+	defer me.mutex.Unlock()
+	m := me.getLocked(key)
+	if !m.canLock() {
+		me.releaseLocked(key, m)
+		return nil, false
 	}
-	me.mutexes[key].rlocks++
-	m := me.mutexes[key]
-	me.mutex.Unlock()
-	m.RLock()
+	m.locks++
+	m.writer = true
+	return m, true
 }
 
-func (me *MutexManager) RUnlock(key string) {
+func (me *MutexManager) lock(ctx context.Context, key string) (*Mutex, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	me.mutex.Lock()
-	if _, ok := me.mutexes[key]; ok {
-		me.mutexes[key].RUnlock()
-		me.mutexes[key].rlocks--
-		if me.mutexes[key].locks == 0 && me.mutexes[key].rlocks == 0 {
-			delete(me.mutexes, key)
+	defer me.mutex.Unlock()
+	m := me.getLocked(key)
+	m.locks++
+	m.waitingWriters++
+	for !m.canLock() {
+		if err := me.waitLocked(ctx, m); err != nil {
+			m.waitingWriters--
+			m.locks--
+			me.releaseLocked(key, m)
+			return nil, err
 		}
-		me.mutex.Unlock()
-	} else {
-		me.mutex.Unlock()
-		panic("r_unlock of unlocked mutex")
 	}
+	m.waitingWriters--
+	m.writer = true
+	return m, nil
 }
 
-func (me *MutexManager) TryLock(key string) bool {
-	me.mutex.Lock()
-	if _, ok := me.mutexes[key]; !ok {
-		me.mutexes[key] = &Mutex{}
+func (me *MutexManager) rlock(ctx context.Context, key string) (*Mutex, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
-	me.mutexes[key].locks++
-	m := me.mutexes[key]
-	if !m.TryLock() {
-		me.mutexes[key].locks--
-		if me.mutexes[key].locks == 0 && me.mutexes[key].rlocks == 0 {
-			delete(me.mutexes, key)
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	m := me.getLocked(key)
+	m.rlocks++
+	for !m.canRLock() {
+		if err := me.waitLocked(ctx, m); err != nil {
+			m.rlocks--
+			me.releaseLocked(key, m)
+			return nil, err
 		}
-		me.mutex.Unlock()
-		return false
 	}
+	m.readers++
+	return m, nil
+}
+
+// waitLocked 释放 me.mutex 等待 m 的状态变化或 ctx 结束，返回前重新持有 me.mutex
+func (me *MutexManager) waitLocked(ctx context.Context, m *Mutex) error {
+	wake := m.wake
 	me.mutex.Unlock()
-	return true
+	defer me.mutex.Lock()
+	select {
+	case <-wake:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-func (me *MutexManager) TryRLock(key string) bool {
+// unlock 释放写锁，expected 不为 nil 时要求 key 当前对应的仍是它
+func (me *MutexManager) unlock(key string, expected *Mutex) {
 	me.mutex.Lock()
-	if _, ok := me.mutexes[key]; !ok {
-		me.mutexes[key] = &Mutex{}
+	defer me.mutex.Unlock()
+	m, ok := me.mutexes[key]
+	if !ok || !m.writer || (expected != nil && m != expected) {
+		panic("unlock of unlocked mutex")
 	}
-	me.mutexes[key].rlocks++
-	m := me.mutexes[key]
-	if !m.TryRLock() {
-		me.mutexes[key].rlocks--
-		if me.mutexes[key].locks == 0 && me.mutexes[key].rlocks == 0 {
-			delete(me.mutexes, key)
-		}
-		me.mutex.Unlock()
-		return false
+	m.writer = false
+	m.locks--
+	me.releaseLocked(key, m)
+}
+
+func (me *MutexManager) runlock(key string, expected *Mutex) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	m, ok := me.mutexes[key]
+	if !ok || m.readers == 0 || (expected != nil && m != expected) {
+		panic("r_unlock of unlocked mutex")
+	}
+	m.readers--
+	m.rlocks--
+	me.releaseLocked(key, m)
+}
+
+func (me *MutexManager) getLocked(key string) *Mutex {
+	m, ok := me.mutexes[key]
+	if !ok {
+		m = &Mutex{wake: make(chan struct{})}
+		me.mutexes[key] = m
+	}
+	return m
+}
+
+// releaseLocked 唤醒等待者，没有等待与持有者时删除 m
+func (me *MutexManager) releaseLocked(key string, m *Mutex) {
+	close(m.wake)
+	m.wake = make(chan struct{})
+	if m.locks == 0 && m.rlocks == 0 {
+		delete(me.mutexes, key)
 	}
-	me.mutex.Unlock()
-	return true
 }
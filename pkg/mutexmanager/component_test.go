@@ -1,6 +1,8 @@
 package mutexmanager
 
 import (
+	"context"
+	"errors"
 	"sync"
 	"testing"
 	"time"
@@ -214,3 +216,165 @@ UNLOCK:
 		t.Fatal("mutex should be deleted after writer/reader both released")
 	}
 }
+
+func TestLockContextCancelCleansUp(t *testing.T) {
+	m := New()
+	key := "ctx-cancel"
+
+	m.Lock(key)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := m.LockContext(ctx, key); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("LockContext: %v", err)
+	}
+	if _, err := m.RLockContext(ctx, key); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("RLockContext: %v", err)
+	}
+	m.Unlock(key)
+
+	if len(m.mutexes) != 0 {
+		t.Fatal("mutex should be deleted after cancelled waiters give up")
+	}
+}
+
+func TestCancelledWriterUnblocksReaders(t *testing.T) {
+	m := New()
+	key := "writer-cancel"
+
+	m.RLock(key)
+	ctx, cancel := context.WithCancel(context.Background())
+	writerDone := make(chan error, 1)
+	go func() {
+		_, err := m.LockContext(ctx, key)
+		writerDone <- err
+	}()
+	waitPending(t, m, key, func(mu *Mutex) bool { return mu.waitingWriters > 0 })
+
+	// 有写锁在等待时新的读锁需要等待
+	if m.TryRLock(key) {
+		t.Fatal("TryRLock should fail while a writer is waiting")
+	}
+	readerDone := make(chan struct{})
+	go func() {
+		h, err := m.RLockContext(context.Background(), key)
+		if err == nil {
+			h.Unlock()
+		}
+		close(readerDone)
+	}()
+	cancel()
+	if err := <-writerDone; !errors.Is(err, context.Canceled) {
+		t.Fatalf("writer: %v", err)
+	}
+	select {
+	case <-readerDone:
+	case <-time.After(time.Second):
+		t.Fatal("reader not unblocked after writer gave up")
+	}
+	m.RUnlock(key)
+}
+
+func TestTryLockFor(t *testing.T) {
+	m := New()
+	key := "try-lock-for"
+
+	h, ok := m.TryLockFor(key, 10*time.Millisecond)
+	if !ok {
+		t.Fatal("TryLockFor should succeed on unlocked mutex")
+	}
+	if _, ok := m.TryLockFor(key, 20*time.Millisecond); ok {
+		t.Fatal("TryLockFor should time out while locked")
+	}
+	if _, ok := m.TryRLockFor(key, 20*time.Millisecond); ok {
+		t.Fatal("TryRLockFor should time out while locked")
+	}
+	time.AfterFunc(20*time.Millisecond, h.Unlock)
+	h2, ok := m.TryLockFor(key, time.Second)
+	if !ok {
+		t.Fatal("TryLockFor should succeed after unlock")
+	}
+	h2.Unlock()
+
+	if len(m.mutexes) != 0 {
+		t.Fatal("all mutexes should be deleted")
+	}
+}
+
+func TestHandleUnlock(t *testing.T) {
+	m := New()
+	key := "handle"
+
+	h, err := m.LockContext(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Key() != key {
+		t.Fatalf("key %q", h.Key())
+	}
+	h.Unlock()
+	// 重复 Unlock 无效，不会释放其他调用方持有的锁
+	m.Lock(key)
+	h.Unlock()
+	if m.TryLock(key) {
+		t.Fatal("lock held by another caller was released")
+	}
+	m.Unlock(key)
+
+	// 句柄对应的锁已被其他方式释放并重新获取时 panic
+	h, _ = m.RLockContext(context.Background(), key)
+	m.RUnlock(key)
+	m.RLock(key)
+	defer m.RUnlock(key)
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic when unlocking a lock the handle does not hold")
+		}
+	}()
+	h.Unlock()
+}
+
+func waitPending(t *testing.T, m *MutexManager, key string, cond func(*Mutex) bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		m.mutex.Lock()
+		mu, ok := m.mutexes[key]
+		pending := ok && cond(mu)
+		m.mutex.Unlock()
+		if pending {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("waiter did not enter pending state in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAcquire(t *testing.T) {
+	var locker Locker = New()
+	ctx := context.Background()
+	key := "acquire"
+
+	l1, err := locker.Acquire(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l1.Key() != key || l1.Lost() != nil {
+		t.Fatalf("key %q, lost %v", l1.Key(), l1.Lost())
+	}
+	if _, err := locker.TryAcquire(ctx, key); !errors.Is(err, ErrLocked) {
+		t.Fatalf("TryAcquire: %v", err)
+	}
+	l1.Unlock(ctx)
+	l1.Unlock(ctx)
+
+	l2, err := locker.TryAcquire(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l2.Token() <= l1.Token() {
+		t.Fatalf("token %d not greater than %d", l2.Token(), l1.Token())
+	}
+	l2.Unlock(ctx)
+}
@@ -0,0 +1,180 @@
+package etcdlocker
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/puper/leo/pkg/mutexmanager"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+var ErrClosed = errors.New("etcdlocker: closed")
+
+type Config struct {
+	// Prefix 锁的 key 前缀，默认 /leo/locks/
+	Prefix string `json:"prefix"`
+	// TTL 租约时长，进程退出或与 etcd 失联后锁最多保留该时长，默认 10s，最小 1s
+	TTL time.Duration `json:"ttl"`
+}
+
+func (c *Config) GetPrefix() string {
+	prefix := cmp.Or(c.Prefix, "/leo/locks/")
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix
+}
+
+func (c *Config) GetTTL() int {
+	return max(int(cmp.Or(c.TTL, 10*time.Second)/time.Second), 1)
+}
+
+// Locker 使用 etcd 实现 mutexmanager.Locker。所有锁共享一个租约 (concurrency.Session)，
+// 租约过期后已持有的锁全部失效 (Lock.Lost 关闭)，下一次 Acquire 使用新的租约。
+// 同一租约下的 etcd 锁不能区分持有者，因此同一进程内先用 MutexManager 互斥
+type Locker struct {
+	client *clientv3.Client
+	config *Config
+	local  *mutexmanager.MutexManager
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	session *concurrency.Session
+	closed  bool
+}
+
+func New(client *clientv3.Client, config *Config) *Locker {
+	if config == nil {
+		config = &Config{}
+	}
+	me := &Locker{
+		client: client,
+		config: config,
+		local:  mutexmanager.New(),
+	}
+	me.ctx, me.cancel = context.WithCancel(context.Background())
+	return me
+}
+
+func (me *Locker) Acquire(ctx context.Context, key string) (mutexmanager.Lock, error) {
+	local, err := me.local.Acquire(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	session, err := me.getSession(ctx)
+	if err != nil {
+		local.Unlock(ctx)
+		return nil, err
+	}
+	m := concurrency.NewMutex(session, me.config.GetPrefix()+key)
+	if err := m.Lock(ctx); err != nil {
+		local.Unlock(ctx)
+		return nil, err
+	}
+	return &lock{local: local, mutex: m, session: session}, nil
+}
+
+func (me *Locker) TryAcquire(ctx context.Context, key string) (mutexmanager.Lock, error) {
+	local, err := me.local.TryAcquire(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	session, err := me.getSession(ctx)
+	if err != nil {
+		local.Unlock(ctx)
+		return nil, err
+	}
+	m := concurrency.NewMutex(session, me.config.GetPrefix()+key)
+	if err := m.TryLock(ctx); err != nil {
+		local.Unlock(ctx)
+		if errors.Is(err, concurrency.ErrLocked) {
+			return nil, mutexmanager.ErrLocked
+		}
+		return nil, err
+	}
+	return &lock{local: local, mutex: m, session: session}, nil
+}
+
+// Close 撤销租约，释放所有持有的锁
+func (me *Locker) Close() error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if me.closed {
+		return nil
+	}
+	me.closed = true
+	var err error
+	if me.session != nil {
+		err = me.session.Close()
+	}
+	me.cancel()
+	return err
+}
+
+// getSession 返回当前租约，租约已过期时重新申请
+func (me *Locker) getSession(ctx context.Context) (*concurrency.Session, error) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if me.closed {
+		return nil, ErrClosed
+	}
+	if me.session != nil {
+		select {
+		case <-me.session.Done():
+		default:
+			return me.session, nil
+		}
+	}
+	// 使用调用方的 ctx 申请租约，续约使用 Locker 的 ctx，不随单次调用结束
+	ttl := me.config.GetTTL()
+	grant, err := me.client.Grant(ctx, int64(ttl))
+	if err != nil {
+		return nil, err
+	}
+	session, err := concurrency.NewSession(me.client,
+		concurrency.WithLease(grant.ID),
+		concurrency.WithTTL(ttl),
+		concurrency.WithContext(me.ctx),
+	)
+	if err != nil {
+		return nil, err
+	}
+	me.session = session
+	return session, nil
+}
+
+type lock struct {
+	local   mutexmanager.Lock
+	mutex   *concurrency.Mutex
+	session *concurrency.Session
+	once    sync.Once
+	err     error
+}
+
+func (l *lock) Key() string {
+	return l.local.Key()
+}
+
+// Token 是获得锁时 etcd 的 revision，随同一 key 获得锁的先后单调递增
+func (l *lock) Token() int64 {
+	return l.mutex.Header().Revision
+}
+
+func (l *lock) Lost() <-chan struct{} {
+	return l.session.Done()
+}
+
+func (l *lock) Unlock(ctx context.Context) error {
+	l.once.Do(func() {
+		l.err = l.mutex.Unlock(ctx)
+		l.local.Unlock(ctx)
+	})
+	return l.err
+}
@@ -0,0 +1,105 @@
+package etcdlocker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/puper/leo/pkg/mutexmanager"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func TestConfig(t *testing.T) {
+	cfg := &Config{}
+	if cfg.GetPrefix() != "/leo/locks/" || cfg.GetTTL() != 10 {
+		t.Fatalf("defaults: %q %d", cfg.GetPrefix(), cfg.GetTTL())
+	}
+	cfg = &Config{Prefix: "/app/locks", TTL: 500 * time.Millisecond}
+	if cfg.GetPrefix() != "/app/locks/" || cfg.GetTTL() != 1 {
+		t.Fatalf("custom: %q %d", cfg.GetPrefix(), cfg.GetTTL())
+	}
+}
+
+// newClient 连接 LEO_ETCD_ENDPOINTS (逗号分隔) 指定的 etcd，未设置时跳过
+func newClient(t *testing.T) *clientv3.Client {
+	t.Helper()
+	endpoints := os.Getenv("LEO_ETCD_ENDPOINTS")
+	if endpoints == "" {
+		t.Skip("LEO_ETCD_ENDPOINTS not set")
+	}
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   strings.Split(endpoints, ","),
+		DialTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestLocker(t *testing.T) {
+	client := newClient(t)
+	cfg := &Config{Prefix: "/leo/test/locks/" + t.Name(), TTL: 2 * time.Second}
+	// 两个 Locker 模拟两个副本
+	a, b := New(client, cfg), New(client, cfg)
+	defer a.Close()
+	defer b.Close()
+	ctx := context.Background()
+
+	la, err := a.Acquire(ctx, "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.TryAcquire(ctx, "job"); !errors.Is(err, mutexmanager.ErrLocked) {
+		t.Fatalf("TryAcquire: %v", err)
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	if _, err := b.Acquire(timeoutCtx, "job"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	if err := la.Unlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	lb, err := b.Acquire(ctx, "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lb.Token() <= la.Token() {
+		t.Fatalf("token %d not greater than %d", lb.Token(), la.Token())
+	}
+	lb.Unlock(ctx)
+}
+
+func TestLocker_Lost(t *testing.T) {
+	client := newClient(t)
+	locker := New(client, &Config{Prefix: "/leo/test/locks/" + t.Name(), TTL: 2 * time.Second})
+	defer locker.Close()
+	ctx := context.Background()
+
+	l, err := locker.Acquire(ctx, "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Revoke(ctx, locker.session.Lease()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-l.Lost():
+	case <-time.After(5 * time.Second):
+		t.Fatal("lock not lost after lease revoked")
+	}
+	l.Unlock(ctx)
+
+	// 租约失效后重新申请
+	l, err = locker.Acquire(ctx, "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Unlock(ctx)
+}
@@ -0,0 +1,63 @@
+package mutexmanager
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrLocked TryAcquire 时锁已被占用
+var ErrLocked = errors.New("mutexmanager: locked")
+
+// Locker 提供按 key 互斥的写锁。MutexManager 只在进程内互斥，
+// etcdlocker.Locker 在多个进程之间互斥
+type Locker interface {
+	// Acquire 等待获取锁，ctx 结束前未获取到时返回 ctx.Err()
+	Acquire(ctx context.Context, key string) (Lock, error)
+	// TryAcquire 不等待，锁被占用时返回 ErrLocked
+	TryAcquire(ctx context.Context, key string) (Lock, error)
+}
+
+// Lock 是一次成功的加锁
+type Lock interface {
+	Key() string
+	// Token 是 fencing token，同一 key 后获得的锁 Token 更大，可交给下游拒绝过期持有者的写入
+	Token() int64
+	// Lost 在锁失效 (如 etcd 租约过期) 时关闭，之后不应再认为持有锁。进程内的锁不会失效，返回 nil
+	Lost() <-chan struct{}
+	// Unlock 释放锁，重复调用无效
+	Unlock(ctx context.Context) error
+}
+
+type localLock struct {
+	*Handle
+	token int64
+}
+
+func (l *localLock) Token() int64 {
+	return l.token
+}
+
+func (l *localLock) Lost() <-chan struct{} {
+	return nil
+}
+
+func (l *localLock) Unlock(ctx context.Context) error {
+	l.Handle.Unlock()
+	return nil
+}
+
+func (me *MutexManager) Acquire(ctx context.Context, key string) (Lock, error) {
+	h, err := me.LockContext(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return &localLock{Handle: h, token: me.tokens.Add(1)}, nil
+}
+
+func (me *MutexManager) TryAcquire(ctx context.Context, key string) (Lock, error) {
+	m, ok := me.tryLock(key)
+	if !ok {
+		return nil, ErrLocked
+	}
+	return &localLock{Handle: &Handle{manager: me, key: key, mutex: m}, token: me.tokens.Add(1)}, nil
+}
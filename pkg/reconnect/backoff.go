@@ -1,6 +1,61 @@
 package reconnect
 
-import "time"
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// BackoffStrategy 计算每次重连前的等待时长，只在重连协程中使用，无需并发安全
+type BackoffStrategy interface {
+	NextDelay() time.Duration
+	Reset()
+	Attempt() int
+}
+
+type BackoffType string
+
+const (
+	// BackoffExponential initial * multiplier^n，无抖动
+	BackoffExponential BackoffType = "exponential"
+	// BackoffFullJitter 在 [0, min(max, initial * multiplier^n)] 中均匀取值
+	BackoffFullJitter BackoffType = "full_jitter"
+	// BackoffDecorrelatedJitter 在 [initial, 上一次等待 * 3] 中均匀取值，不超过 max
+	BackoffDecorrelatedJitter BackoffType = "decorrelated_jitter"
+	// BackoffConstant 固定为 initial
+	BackoffConstant BackoffType = "constant"
+	// BackoffLinear initial * (n + 1)
+	BackoffLinear BackoffType = "linear"
+	// BackoffFibonacci initial * fib(n + 1)，即 1, 1, 2, 3, 5 ... 倍
+	BackoffFibonacci BackoffType = "fibonacci"
+)
+
+// NewBackoffStrategy 按 typ 创建退避策略，src 为 nil 时使用随机种子
+func NewBackoffStrategy(typ BackoffType, cfg ReconnectConfig, src rand.Source) BackoffStrategy {
+	switch typ {
+	case BackoffFullJitter:
+		return &FullJitterBackoff{Backoff: *NewBackoff(cfg), rand: newRand(src)}
+	case BackoffDecorrelatedJitter:
+		return &DecorrelatedJitterBackoff{
+			initialInterval: cfg.GetInitialInterval(),
+			maxInterval:     cfg.GetMaxInterval(),
+			rand:            newRand(src),
+		}
+	case BackoffConstant:
+		return &stepBackoff{initialInterval: cfg.GetInitialInterval(), maxInterval: cfg.GetMaxInterval(), factor: constantFactor}
+	case BackoffLinear:
+		return &stepBackoff{initialInterval: cfg.GetInitialInterval(), maxInterval: cfg.GetMaxInterval(), factor: linearFactor}
+	case BackoffFibonacci:
+		return &stepBackoff{initialInterval: cfg.GetInitialInterval(), maxInterval: cfg.GetMaxInterval(), factor: fibonacciFactor}
+	}
+	return NewBackoff(cfg)
+}
+
+func newRand(src rand.Source) *rand.Rand {
+	if src == nil {
+		src = rand.NewPCG(rand.Uint64(), rand.Uint64())
+	}
+	return rand.New(src)
+}
 
 type Backoff struct {
 	initialInterval time.Duration
@@ -25,20 +80,102 @@ func (b *Backoff) Reset() {
 func (b *Backoff) NextDelay() time.Duration {
 	if b.attempt == 0 {
 		b.attempt = 1
-		return b.initialInterval
+		return min(b.initialInterval, b.maxInterval)
 	}
-	delay := time.Duration(float64(b.initialInterval) * pow(b.multiplier, float64(b.attempt)))
+	delay := clampDelay(float64(b.initialInterval)*pow(b.multiplier, float64(b.attempt)), b.maxInterval)
 	b.attempt++
-	if delay > b.maxInterval {
-		return b.maxInterval
-	}
 	return delay
 }
 
+// clampDelay 在浮点数上与 max 比较后再转换，避免 attempt 较大时转换为 time.Duration 溢出
+func clampDelay(f float64, max time.Duration) time.Duration {
+	if f >= float64(max) {
+		return max
+	}
+	if f <= 0 {
+		return 0
+	}
+	return time.Duration(f)
+}
+
 func (b *Backoff) Attempt() int {
 	return b.attempt
 }
 
+// FullJitterBackoff 以指数退避的结果为上限均匀取值，避免大量客户端在服务恢复后同时重连
+type FullJitterBackoff struct {
+	Backoff
+	rand *rand.Rand
+}
+
+func (b *FullJitterBackoff) NextDelay() time.Duration {
+	ceiling := b.Backoff.NextDelay()
+	return time.Duration(b.rand.Uint64N(uint64(ceiling) + 1))
+}
+
+type DecorrelatedJitterBackoff struct {
+	initialInterval time.Duration
+	maxInterval     time.Duration
+	rand            *rand.Rand
+	prev            time.Duration
+	attempt         int
+}
+
+func (b *DecorrelatedJitterBackoff) NextDelay() time.Duration {
+	b.attempt++
+	upper := max(clampDelay(float64(b.prev)*3, b.maxInterval), b.initialInterval)
+	delay := b.initialInterval + time.Duration(b.rand.Uint64N(uint64(upper-b.initialInterval)+1))
+	b.prev = min(delay, b.maxInterval)
+	return b.prev
+}
+
+func (b *DecorrelatedJitterBackoff) Reset() {
+	b.attempt = 0
+	b.prev = 0
+}
+
+func (b *DecorrelatedJitterBackoff) Attempt() int {
+	return b.attempt
+}
+
+// stepBackoff 等待 initial * factor(n)，n 从 0 开始
+type stepBackoff struct {
+	initialInterval time.Duration
+	maxInterval     time.Duration
+	factor          func(n int) float64
+	attempt         int
+}
+
+func (b *stepBackoff) NextDelay() time.Duration {
+	delay := clampDelay(float64(b.initialInterval)*b.factor(b.attempt), b.maxInterval)
+	b.attempt++
+	return delay
+}
+
+func (b *stepBackoff) Reset() {
+	b.attempt = 0
+}
+
+func (b *stepBackoff) Attempt() int {
+	return b.attempt
+}
+
+func constantFactor(int) float64 {
+	return 1
+}
+
+func linearFactor(n int) float64 {
+	return float64(n + 1)
+}
+
+func fibonacciFactor(n int) float64 {
+	a, b := 1.0, 1.0
+	for range n {
+		a, b = b, a+b
+	}
+	return a
+}
+
 func pow(base, exp float64) float64 {
 	result := 1.0
 	for i := 0; i < int(exp); i++ {
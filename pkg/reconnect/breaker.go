@@ -0,0 +1,218 @@
+package reconnect
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 熔断器打开期间 Client.Do 直接返回此错误，不再等待重连
+var ErrCircuitOpen = errors.New("reconnect: circuit open")
+
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+// CircuitEventHandler 由 EventHandler 选择实现，接收熔断器的状态变化
+type CircuitEventHandler interface {
+	OnCircuitStateChange(from, to CircuitState)
+}
+
+// CircuitBreakerConfig 熔断配置，连接失败与 Do 中的连接错误都计为失败
+type CircuitBreakerConfig struct {
+	// FailureThreshold Window 内失败达到该次数时打开，默认 5
+	FailureThreshold int
+	// Window 统计失败次数的时间窗口，默认 1m
+	Window time.Duration
+	// OpenTimeout 打开后经过该时长进入半开，默认 30s
+	OpenTimeout time.Duration
+	// HalfOpenMaxCalls 半开时允许同时进行的探测调用数，默认 1
+	HalfOpenMaxCalls int
+	// SuccessThreshold 半开时探测成功该次数后关闭，默认 1
+	SuccessThreshold int
+}
+
+func (c *CircuitBreakerConfig) GetFailureThreshold() int {
+	if c.FailureThreshold <= 0 {
+		return 5
+	}
+	return c.FailureThreshold
+}
+
+func (c *CircuitBreakerConfig) GetWindow() time.Duration {
+	if c.Window <= 0 {
+		return time.Minute
+	}
+	return c.Window
+}
+
+func (c *CircuitBreakerConfig) GetOpenTimeout() time.Duration {
+	if c.OpenTimeout <= 0 {
+		return 30 * time.Second
+	}
+	return c.OpenTimeout
+}
+
+func (c *CircuitBreakerConfig) GetHalfOpenMaxCalls() int {
+	if c.HalfOpenMaxCalls <= 0 {
+		return 1
+	}
+	return c.HalfOpenMaxCalls
+}
+
+func (c *CircuitBreakerConfig) GetSuccessThreshold() int {
+	if c.SuccessThreshold <= 0 {
+		return 1
+	}
+	return c.SuccessThreshold
+}
+
+// circuitBreaker 的方法在 nil 上调用时表示未启用熔断
+type circuitBreaker struct {
+	cfg      *CircuitBreakerConfig
+	onChange func(from, to CircuitState)
+
+	mu        sync.Mutex
+	state     CircuitState
+	failures  []time.Time
+	openedAt  time.Time
+	inFlight  int
+	successes int
+}
+
+func newCircuitBreaker(cfg *CircuitBreakerConfig, onChange func(from, to CircuitState)) *circuitBreaker {
+	if cfg == nil {
+		return nil
+	}
+	return &circuitBreaker{cfg: cfg, onChange: onChange}
+}
+
+func (b *circuitBreaker) State() CircuitState {
+	if b == nil {
+		return CircuitClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitOpen && b.expired() {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// allow 判断能否发起一次调用，允许时返回的 done 必须以调用结果调用一次
+func (b *circuitBreaker) allow() (done func(success bool), err error) {
+	if b == nil {
+		return func(bool) {}, nil
+	}
+	b.mu.Lock()
+	from := b.state
+	b.expireLocked()
+	switch {
+	case b.state == CircuitOpen:
+		err = ErrCircuitOpen
+	case b.state == CircuitHalfOpen && b.inFlight >= b.cfg.GetHalfOpenMaxCalls():
+		err = ErrCircuitOpen
+	case b.state == CircuitHalfOpen:
+		b.inFlight++
+		done = func(success bool) {
+			b.mu.Lock()
+			b.inFlight--
+			b.mu.Unlock()
+			b.record(success)
+		}
+	default:
+		done = b.record
+	}
+	to := b.state
+	b.mu.Unlock()
+	b.notify(from, to)
+	return done, err
+}
+
+// record 记录一次连接或调用的结果
+func (b *circuitBreaker) record(success bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	from := b.state
+	now := time.Now()
+	switch {
+	case success && b.state == CircuitHalfOpen:
+		b.successes++
+		if b.successes >= b.cfg.GetSuccessThreshold() {
+			b.state = CircuitClosed
+			b.failures = nil
+		}
+	case !success && b.state == CircuitHalfOpen:
+		b.openLocked(now)
+	case !success && b.state == CircuitClosed:
+		cutoff := now.Add(-b.cfg.GetWindow())
+		kept := b.failures[:0]
+		for _, t := range b.failures {
+			if t.After(cutoff) {
+				kept = append(kept, t)
+			}
+		}
+		b.failures = append(kept, now)
+		if len(b.failures) >= b.cfg.GetFailureThreshold() {
+			b.openLocked(now)
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+	b.notify(from, to)
+}
+
+// halfOpen 在连接恢复时提前结束打开状态，让探测调用通过
+func (b *circuitBreaker) halfOpen() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	from := b.state
+	if b.state == CircuitOpen {
+		b.state = CircuitHalfOpen
+		b.successes = 0
+	}
+	to := b.state
+	b.mu.Unlock()
+	b.notify(from, to)
+}
+
+func (b *circuitBreaker) openLocked(now time.Time) {
+	b.state = CircuitOpen
+	b.openedAt = now
+	b.failures = nil
+}
+
+func (b *circuitBreaker) expired() bool {
+	return time.Since(b.openedAt) >= b.cfg.GetOpenTimeout()
+}
+
+func (b *circuitBreaker) expireLocked() {
+	if b.state == CircuitOpen && b.expired() {
+		b.state = CircuitHalfOpen
+		b.successes = 0
+	}
+}
+
+func (b *circuitBreaker) notify(from, to CircuitState) {
+	if from != to && b.onChange != nil {
+		b.onChange(from, to)
+	}
+}
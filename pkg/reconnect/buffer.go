@@ -0,0 +1,459 @@
+package reconnect
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrBufferFull OverflowDropNewest 时由 Send 返回，OverflowDropOldest 时传给 OnDrop
+	ErrBufferFull = errors.New("reconnect: buffer full")
+	// ErrBufferExpired 超过有效期未发送的数据传给 OnDrop
+	ErrBufferExpired = errors.New("reconnect: buffered item expired")
+	ErrBufferClosed  = errors.New("reconnect: buffer closed")
+)
+
+type OverflowPolicy string
+
+const (
+	OverflowDropOldest OverflowPolicy = "drop_oldest"
+	OverflowDropNewest OverflowPolicy = "drop_newest"
+	// OverflowBlock Send 阻塞直到有空间或 ctx 结束
+	OverflowBlock OverflowPolicy = "block"
+)
+
+type BufferConfig struct {
+	// Capacity 内存中最多缓存的条数，发送协程正在发送的一条不计入，默认 1024
+	Capacity int
+	// Overflow 内存 (及磁盘) 满时的处理方式，默认 OverflowDropOldest
+	Overflow OverflowPolicy
+	// TTL Send 写入数据的有效期，0 表示不过期
+	TTL time.Duration
+	// SpillDir 非空时内存满后写入该目录下的临时文件，文件只用于扩展容量，Close 时删除
+	SpillDir string
+	// MaxSpillBytes 临时文件的最大字节数，默认 64MB
+	MaxSpillBytes int64
+	// OnDrop 数据被丢弃时调用：溢出 (ErrBufferFull)、过期 (ErrBufferExpired)、Component 已关闭 (ErrClosed)
+	// 或 send 返回非连接错误，
+	// 读取临时文件失败时 payload 为 nil。调用时持有 Buffer 的锁，不能再调用 Buffer 的方法
+	OnDrop func(payload []byte, err error)
+}
+
+func (c *BufferConfig) GetCapacity() int {
+	if c.Capacity <= 0 {
+		return 1024
+	}
+	return c.Capacity
+}
+
+func (c *BufferConfig) GetOverflow() OverflowPolicy {
+	if c.Overflow == "" {
+		return OverflowDropOldest
+	}
+	return c.Overflow
+}
+
+func (c *BufferConfig) GetMaxSpillBytes() int64 {
+	if c.MaxSpillBytes <= 0 {
+		return 64 << 20
+	}
+	return c.MaxSpillBytes
+}
+
+type BufferStats struct {
+	// Queued 等待发送的条数，包括磁盘中的
+	Queued  int
+	Spilled int
+	Sent    int64
+	Dropped int64
+	Expired int64
+}
+
+type bufferItem struct {
+	payload  []byte
+	expireAt time.Time
+}
+
+func (it bufferItem) expired(now time.Time) bool {
+	return !it.expireAt.IsZero() && now.After(it.expireAt)
+}
+
+// Buffer 缓存断线期间 (以及连接正常时) 提交的数据，由单个协程在连接可用时按提交顺序调用 send 发送。
+// send 返回连接错误时数据保留并在重连后重发，返回其他错误时丢弃并调用 OnDrop。
+type Buffer struct {
+	comp   *Component
+	send   func(raw interface{}, payload []byte) error
+	config *BufferConfig
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	memory []bufferItem
+	spill  *spillFile
+	// sending 发送协程持有的数据，已从队列中取出
+	sending bool
+	closed  bool
+	stats   BufferStats
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewBuffer(comp *Component, send func(raw interface{}, payload []byte) error, config *BufferConfig) (*Buffer, error) {
+	if config == nil {
+		config = &BufferConfig{}
+	}
+	b := &Buffer{
+		comp:   comp,
+		send:   send,
+		config: config,
+		done:   make(chan struct{}),
+	}
+	if config.SpillDir != "" {
+		spill, err := newSpillFile(config.SpillDir, config.GetMaxSpillBytes())
+		if err != nil {
+			return nil, err
+		}
+		b.spill = spill
+	}
+	b.cond = sync.NewCond(&b.mu)
+	b.ctx, b.cancel = context.WithCancel(context.Background())
+	go b.run()
+	return b, nil
+}
+
+func (b *Buffer) Send(ctx context.Context, payload []byte) error {
+	return b.SendTTL(ctx, payload, b.config.TTL)
+}
+
+// SendTTL 与 Send 相同，使用 ttl 作为有效期，0 表示不过期
+func (b *Buffer) SendTTL(ctx context.Context, payload []byte, ttl time.Duration) error {
+	item := bufferItem{payload: payload}
+	if ttl > 0 {
+		item.expireAt = time.Now().Add(ttl)
+	}
+	stop := context.AfterFunc(ctx, b.broadcast)
+	defer stop()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for {
+		if b.closed {
+			return ErrBufferClosed
+		}
+		err := b.pushLocked(item)
+		if !errors.Is(err, ErrBufferFull) {
+			return err
+		}
+		switch b.config.GetOverflow() {
+		case OverflowDropNewest:
+			b.stats.Dropped++
+			return err
+		case OverflowBlock:
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			b.cond.Wait()
+		default:
+			if oldest, ok := b.popLocked(); ok {
+				b.stats.Dropped++
+				b.dropLocked(oldest, ErrBufferFull)
+			}
+		}
+	}
+}
+
+// Flush 等待已提交的数据全部发送或丢弃
+func (b *Buffer) Flush(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, b.broadcast)
+	defer stop()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.lenLocked() > 0 || b.sending {
+		if b.closed {
+			return ErrBufferClosed
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		b.cond.Wait()
+	}
+	return nil
+}
+
+// Close 停止发送，未发送的数据被丢弃，不调用 OnDrop
+func (b *Buffer) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.mu.Unlock()
+	b.cond.Broadcast()
+	b.cancel()
+	<-b.done
+	if b.spill != nil {
+		return b.spill.close()
+	}
+	return nil
+}
+
+func (b *Buffer) Stats() BufferStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats := b.stats
+	stats.Queued = b.lenLocked()
+	if b.spill != nil {
+		stats.Spilled = b.spill.count
+	}
+	return stats
+}
+
+func (b *Buffer) run() {
+	defer close(b.done)
+	for {
+		item, ok := b.next()
+		if !ok {
+			return
+		}
+		for {
+			// 未连接时 Client 持有的可能是已失效的连接，先等待连接
+			if err := b.comp.WaitConnected(b.ctx); err != nil {
+				if b.ctx.Err() != nil {
+					return
+				}
+				// Component 已关闭，不会再建立连接，丢弃数据以免空转
+				if errors.Is(err, ErrClosed) {
+					b.finish(func() {
+						b.stats.Dropped++
+						b.dropLocked(item, ErrClosed)
+					})
+					break
+				}
+			}
+			if item.expired(time.Now()) {
+				b.finish(func() {
+					b.stats.Expired++
+					b.dropLocked(item, ErrBufferExpired)
+				})
+				break
+			}
+			err := b.comp.GetClient().DoContext(b.ctx, func(raw interface{}) error {
+				return b.send(raw, item.payload)
+			})
+			if err == nil {
+				b.finish(func() { b.stats.Sent++ })
+				break
+			}
+			if b.ctx.Err() != nil {
+				return
+			}
+			open := errors.Is(err, ErrCircuitOpen)
+			if !open && !b.comp.isConnectionError(err) {
+				b.finish(func() {
+					b.stats.Dropped++
+					b.dropLocked(item, err)
+				})
+				break
+			}
+			// 熔断或重连已停止时 WaitReconnect 不会等待，稍后重发
+			if state := b.comp.State(); open || state == StateStopped || state == StateClosed {
+				select {
+				case <-b.ctx.Done():
+					return
+				case <-time.After(b.comp.config.GetInitialInterval()):
+				}
+			}
+		}
+	}
+}
+
+// next 取出队首数据，队列为空时等待，Buffer 关闭时返回 false
+func (b *Buffer) next() (bufferItem, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for !b.closed {
+		if item, ok := b.popLocked(); ok {
+			b.sending = true
+			b.cond.Broadcast()
+			return item, true
+		}
+		b.cond.Wait()
+	}
+	return bufferItem{}, false
+}
+
+func (b *Buffer) finish(fn func()) {
+	b.mu.Lock()
+	b.sending = false
+	fn()
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+func (b *Buffer) pushLocked(item bufferItem) error {
+	spilled := b.spill != nil && b.spill.count > 0
+	if !spilled && len(b.memory) < b.config.GetCapacity() {
+		b.memory = append(b.memory, item)
+		b.cond.Broadcast()
+		return nil
+	}
+	if b.spill == nil || b.spill.size+spillRecordSize(item) > b.config.GetMaxSpillBytes() {
+		return ErrBufferFull
+	}
+	if err := b.spill.push(item); err != nil {
+		return err
+	}
+	b.cond.Broadcast()
+	return nil
+}
+
+// popLocked 取出最早的数据。磁盘中的数据总是晚于内存中的，磁盘非空时内存总是满的，
+// 内存取出一条后从磁盘补充一条
+func (b *Buffer) popLocked() (bufferItem, bool) {
+	if len(b.memory) == 0 {
+		return bufferItem{}, false
+	}
+	item := b.memory[0]
+	b.memory[0] = bufferItem{}
+	b.memory = b.memory[1:]
+	if b.spill != nil && b.spill.count > 0 {
+		next, err := b.spill.pop()
+		if err != nil {
+			b.dropLocked(bufferItem{}, err)
+		} else {
+			b.memory = append(b.memory, next)
+		}
+	}
+	return item, true
+}
+
+func (b *Buffer) lenLocked() int {
+	n := len(b.memory)
+	if b.spill != nil {
+		n += b.spill.count
+	}
+	return n
+}
+
+func (b *Buffer) dropLocked(item bufferItem, err error) {
+	if b.config.OnDrop != nil {
+		b.config.OnDrop(item.payload, err)
+	}
+}
+
+func (b *Buffer) broadcast() {
+	b.mu.Lock()
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+// spillFile 以 [过期时间 8 字节][长度 4 字节][数据] 的格式顺序追加记录，读完后截断复用。
+// 一直未读完时，已读部分超过 compactAt 后把未读记录移到文件开头，文件大小不超过约两倍 MaxSpillBytes
+type spillFile struct {
+	f         *os.File
+	readOff   int64
+	writeOff  int64
+	size      int64
+	count     int
+	compactAt int64
+}
+
+const spillHeaderSize = 12
+
+func spillRecordSize(item bufferItem) int64 {
+	return spillHeaderSize + int64(len(item.payload))
+}
+
+func newSpillFile(dir string, compactAt int64) (*spillFile, error) {
+	f, err := os.CreateTemp(dir, "reconnect-buffer-*")
+	if err != nil {
+		return nil, fmt.Errorf("reconnect: create spill file: %w", err)
+	}
+	return &spillFile{f: f, compactAt: compactAt}, nil
+}
+
+func (s *spillFile) push(item bufferItem) error {
+	buf := make([]byte, spillRecordSize(item))
+	var expireAt int64
+	if !item.expireAt.IsZero() {
+		expireAt = item.expireAt.UnixNano()
+	}
+	binary.BigEndian.PutUint64(buf, uint64(expireAt))
+	binary.BigEndian.PutUint32(buf[8:], uint32(len(item.payload)))
+	copy(buf[spillHeaderSize:], item.payload)
+	if _, err := s.f.WriteAt(buf, s.writeOff); err != nil {
+		return fmt.Errorf("reconnect: write spill file: %w", err)
+	}
+	s.writeOff += int64(len(buf))
+	s.size += int64(len(buf))
+	s.count++
+	return nil
+}
+
+func (s *spillFile) pop() (bufferItem, error) {
+	var header [spillHeaderSize]byte
+	if _, err := s.f.ReadAt(header[:], s.readOff); err != nil {
+		return bufferItem{}, s.fail(err)
+	}
+	item := bufferItem{payload: make([]byte, binary.BigEndian.Uint32(header[8:]))}
+	if expireAt := int64(binary.BigEndian.Uint64(header[:])); expireAt != 0 {
+		item.expireAt = time.Unix(0, expireAt)
+	}
+	if _, err := io.ReadFull(io.NewSectionReader(s.f, s.readOff+spillHeaderSize, int64(len(item.payload))), item.payload); err != nil {
+		return bufferItem{}, s.fail(err)
+	}
+	n := spillRecordSize(item)
+	s.readOff += n
+	s.size -= n
+	s.count--
+	if s.count == 0 {
+		s.reset()
+	} else if s.readOff >= s.compactAt && s.size <= s.readOff {
+		s.compact()
+	}
+	return item, nil
+}
+
+// compact 把未读记录复制到文件开头。调用时未读部分不长于已读部分，两段不重叠，
+// 中途失败时原数据仍然完整，下次 pop 时重试
+func (s *spillFile) compact() {
+	buf := make([]byte, min(s.size, 64<<10))
+	for off := int64(0); off < s.size; {
+		n := min(int64(len(buf)), s.size-off)
+		if _, err := s.f.ReadAt(buf[:n], s.readOff+off); err != nil {
+			return
+		}
+		if _, err := s.f.WriteAt(buf[:n], off); err != nil {
+			return
+		}
+		off += n
+	}
+	s.readOff, s.writeOff = 0, s.size
+	s.f.Truncate(s.size)
+}
+
+// fail 读取出错时丢弃磁盘中剩余的数据
+func (s *spillFile) fail(err error) error {
+	s.count = 0
+	s.size = 0
+	s.reset()
+	return fmt.Errorf("reconnect: read spill file: %w", err)
+}
+
+func (s *spillFile) reset() {
+	s.readOff, s.writeOff = 0, 0
+	s.f.Truncate(0)
+}
+
+func (s *spillFile) close() error {
+	s.f.Close()
+	return os.Remove(s.f.Name())
+}
@@ -2,22 +2,34 @@ package reconnect
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
+var (
+	// ErrStopped 超过 MaxRetries 或 MaxElapsedTime 后不再重连
+	ErrStopped = errors.New("reconnect: stopped")
+	ErrClosed  = errors.New("reconnect: closed")
+)
+
 type Component struct {
 	connector    Connector
+	getClient    func() interface{}
 	eventHandler EventHandler
 	config       ReconnectConfig
-	backoff      *Backoff
+	backoff      BackoffStrategy
+	breaker      *circuitBreaker
 
 	lifecycleMu sync.RWMutex
 	ctx         context.Context
-	cancel      context.CancelFunc
+	cancel      context.CancelCauseFunc
 	wg          sync.WaitGroup
 
+	retryStart time.Time
+
 	mu        sync.Mutex
 	cond      *sync.Cond
 	connected bool
@@ -25,6 +37,12 @@ type Component struct {
 	stopped   bool
 	clientSeq int64
 
+	state         State
+	stats         Stats
+	connectedOnce bool
+	downSince     time.Time
+	subscribers   map[chan StateChange]struct{}
+
 	doneCh chan struct{}
 }
 
@@ -49,17 +67,22 @@ func New(connector Connector, eventHandler EventHandler, config ReconnectConfig)
 		config = &DefaultReconnectConfig{}
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancelCause(context.Background())
 	c := &Component{
 		connector:    connector,
 		eventHandler: eventHandler,
 		config:       config,
-		backoff:      NewBackoff(config),
+		backoff:      backoffStrategy(config),
 		ctx:          ctx,
 		cancel:       cancel,
 		clientSeq:    0,
 		doneCh:       make(chan struct{}),
+		subscribers:  make(map[chan StateChange]struct{}),
+	}
+	if cg, ok := connector.(ClientGetter); ok {
+		c.getClient = cg.GetClient
 	}
+	c.breaker = newCircuitBreaker(circuitBreakerConfig(config), c.onCircuitStateChange)
 	c.cond = sync.NewCond(&c.mu)
 	return c
 }
@@ -74,10 +97,25 @@ func (c *Component) Start() error {
 	return nil
 }
 
+// StartAndWait 启动并等待首次连接成功，ctx 结束或重连停止时关闭 Component 并返回错误，
+// 用于在 Builder 中让依赖不可用时构建失败
+func (c *Component) StartAndWait(ctx context.Context) error {
+	if err := c.Start(); err != nil {
+		return err
+	}
+	if err := c.WaitConnected(ctx); err != nil {
+		lastErr := c.Stats().LastError
+		c.Close()
+		return fmt.Errorf("reconnect: first connect: %w", errors.Join(err, lastErr))
+	}
+	return nil
+}
+
 func (c *Component) Close() error {
 	c.mu.Lock()
 	c.closing = true
 	c.connected = false
+	c.setStateLocked(StateClosed, nil)
 	c.mu.Unlock()
 	c.cond.Broadcast()
 	c.cancelContext()
@@ -97,8 +135,8 @@ func (c *Component) GetClient() *Client {
 
 	seq := atomic.LoadInt64(&c.clientSeq)
 	var raw interface{}
-	if cg, ok := c.connector.(ClientGetter); ok {
-		raw = cg.GetClient()
+	if c.getClient != nil {
+		raw = c.getClient()
 	}
 
 	return &Client{
@@ -116,14 +154,67 @@ func (c *Component) WaitReconnect() {
 	}
 }
 
-func (c *Component) WaitRefresh() {
-	for {
-		seq := atomic.LoadInt64(&c.clientSeq)
-		if seq >= 0 {
-			return
+// WaitReconnectContext 与 WaitReconnect 相同，ctx 结束时返回 ctx.Err()
+func (c *Component) WaitReconnectContext(ctx context.Context) error {
+	return c.waitConnected(ctx, false)
+}
+
+// WaitConnected 等待连接可用，ctx 结束时返回 ctx.Err()，不再重连时返回 ErrStopped 或 ErrClosed
+func (c *Component) WaitConnected(ctx context.Context) error {
+	if err := c.waitConnected(ctx, false); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case c.connected:
+		return nil
+	case c.closing:
+		return ErrClosed
+	}
+	return ErrStopped
+}
+
+// CircuitState 返回熔断器的状态，未启用熔断时总是 CircuitClosed
+func (c *Component) CircuitState() CircuitState {
+	return c.breaker.State()
+}
+
+// waitConnected 等待连接可用，failFast 为 true 时熔断器打开后返回 ErrCircuitOpen
+func (c *Component) waitConnected(ctx context.Context, failFast bool) error {
+	stop := context.AfterFunc(ctx, func() {
+		// 持锁后广播，确保等待方已进入 Wait 或能观察到 ctx 已结束
+		c.mu.Lock()
+		c.mu.Unlock()
+		c.cond.Broadcast()
+	})
+	defer stop()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for !c.connected && !c.stopped && !c.closing {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if failFast && c.breaker.State() == CircuitOpen {
+			return ErrCircuitOpen
 		}
-		time.Sleep(10 * time.Millisecond)
+		c.cond.Wait()
 	}
+	return nil
+}
+
+func (c *Component) onCircuitStateChange(from, to CircuitState) {
+	if ch, ok := c.eventHandler.(CircuitEventHandler); ok {
+		ch.OnCircuitStateChange(from, to)
+	}
+	c.mu.Lock()
+	c.mu.Unlock()
+	c.cond.Broadcast()
+}
+
+// Deprecated: 使用 WaitConnected
+func (c *Component) WaitRefresh() {
+	c.WaitReconnect()
 }
 
 func (c *Component) run() {
@@ -134,6 +225,11 @@ func (c *Component) run() {
 		c.mu.Lock()
 		c.stopped = true
 		c.connected = false
+		if c.closing {
+			c.setStateLocked(StateClosed, nil)
+		} else {
+			c.setStateLocked(StateStopped, nil)
+		}
 		c.mu.Unlock()
 		c.cond.Broadcast()
 	}()
@@ -143,19 +239,27 @@ func (c *Component) run() {
 
 func (c *Component) connectLoop() {
 	attempt := 0
+	c.retryStart = time.Now()
 	for {
 		if !c.waitRetry(attempt) {
 			return
 		}
 		ctx := c.getContext()
+		c.setState(StateConnecting, nil)
 		err := c.connector.Connect(ctx)
 		if err == nil {
+			c.breaker.halfOpen()
 			c.backoff.Reset()
 			attempt = 0
-			c.setConnected(true)
+			c.mu.Lock()
+			c.connected = true
+			c.setStateLocked(StateConnected, nil)
+			c.mu.Unlock()
+			c.cond.Broadcast()
+			c.updateEndpoint()
 			c.eventHandler.OnConnected()
 
-			c.waitForDisconnect(ctx)
+			cause := c.waitForDisconnect(ctx)
 
 			c.setConnected(false)
 
@@ -163,37 +267,80 @@ func (c *Component) connectLoop() {
 				c.connector.Disconnect()
 				return
 			}
-			c.eventHandler.OnDisconnected(nil)
+			if ctx.Err() == nil {
+				// 健康检查失败，ctx 未被 tryRefresh 取消，需要自行断开并让 Client 刷新
+				atomic.AddInt64(&c.clientSeq, 1)
+				c.connector.Disconnect()
+			}
+			c.setState(StateBackoff, cause)
+			c.eventHandler.OnDisconnected(cause)
 			attempt = 1
+			c.retryStart = time.Now()
 		} else {
+			c.breaker.record(false)
+			c.recordError(err)
 			c.eventHandler.OnError(err)
 			attempt++
 		}
 	}
 }
 
-func (c *Component) waitForDisconnect(ctx context.Context) {
+// waitForDisconnect 等待连接断开并返回原因：健康检查失败、DisconnectNotifier 给出的错误或 tryRefresh 传入的错误
+func (c *Component) waitForDisconnect(ctx context.Context) error {
+	var lost <-chan error
+	if notifier, ok := c.connector.(DisconnectNotifier); ok {
+		lost = notifier.NotifyDisconnect()
+	}
 	healthCheck, hasHealthCheck := c.connector.(HealthCheckConnector)
 	if hasHealthCheck && c.config.GetHealthCheckInterval() > 0 {
-		c.healthCheckLoop(ctx, healthCheck)
+		if err := c.healthCheckLoop(ctx, healthCheck, lost); err != nil {
+			return err
+		}
 	} else {
-		<-ctx.Done()
+		select {
+		case <-ctx.Done():
+		case err := <-lost:
+			return connectionLost(err)
+		}
+	}
+	return context.Cause(ctx)
+}
+
+func connectionLost(err error) error {
+	if err == nil {
+		return ErrConnectionLost
 	}
+	return fmt.Errorf("%w: %w", ErrConnectionLost, err)
 }
 
-func (c *Component) healthCheckLoop(ctx context.Context, hc HealthCheckConnector) {
+// healthCheckLoop 定期 SendPing，连续失败 HealthCheckFailureThreshold 次时返回最后一次的错误
+func (c *Component) healthCheckLoop(ctx context.Context, hc HealthCheckConnector, lost <-chan error) error {
 	ticker := time.NewTicker(c.config.GetHealthCheckInterval())
 	defer ticker.Stop()
+	threshold := healthCheckFailureThreshold(c.config)
+	failures := 0
 	for {
 		select {
 		case <-ctx.Done():
-			return
+			return nil
+		case err := <-lost:
+			return connectionLost(err)
 		case <-ticker.C:
-			ctx, cancel := context.WithTimeout(context.Background(), c.config.GetHealthCheckInterval()/2)
-			err := hc.SendPing(ctx)
+			pingCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout(c.config))
+			err := hc.SendPing(pingCtx)
 			cancel()
-			if err != nil {
-				return
+			if err == nil {
+				failures = 0
+				continue
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+			failures++
+			c.recordError(err)
+			c.eventHandler.OnError(err)
+			if failures >= threshold {
+				return fmt.Errorf("reconnect: health check failed %d times: %w", failures, err)
 			}
 		}
 	}
@@ -212,6 +359,13 @@ func (c *Component) waitRetry(attempt int) bool {
 		return false
 	}
 	delay := c.backoff.NextDelay()
+	if limit := maxElapsedTime(c.config); limit > 0 && time.Since(c.retryStart)+delay > limit {
+		return false
+	}
+	c.mu.Lock()
+	c.stats.Attempt = attempt
+	c.setStateLocked(StateBackoff, nil)
+	c.mu.Unlock()
 	c.eventHandler.OnReconnecting(attempt, delay)
 	select {
 	case <-ctx.Done():
@@ -225,7 +379,8 @@ func (c *Component) waitRetry(attempt int) bool {
 	return true
 }
 
-func (c *Component) tryRefresh(currentSeq int64) bool {
+// tryRefresh 断开 currentSeq 对应的连接并触发重连，cause 作为断开原因传给 OnDisconnected
+func (c *Component) tryRefresh(currentSeq int64, cause error) bool {
 	if c.isTerminating() {
 		return false
 	}
@@ -237,7 +392,7 @@ func (c *Component) tryRefresh(currentSeq int64) bool {
 		if atomic.CompareAndSwapInt64(&c.clientSeq, seq, seq+1) {
 			c.setConnected(false)
 			c.connector.Disconnect()
-			c.resetContext()
+			c.resetContext(cause)
 			return true
 		}
 	}
@@ -260,10 +415,10 @@ func (c *Component) cancelContext() {
 	c.lifecycleMu.RLock()
 	cancel := c.cancel
 	c.lifecycleMu.RUnlock()
-	cancel()
+	cancel(nil)
 }
 
-func (c *Component) resetContext() {
+func (c *Component) resetContext(cause error) {
 	if c.isTerminating() {
 		return
 	}
@@ -272,8 +427,8 @@ func (c *Component) resetContext() {
 		c.lifecycleMu.Unlock()
 		return
 	}
-	c.cancel()
-	c.ctx, c.cancel = context.WithCancel(context.Background())
+	c.cancel(cause)
+	c.ctx, c.cancel = context.WithCancelCause(context.Background())
 	c.lifecycleMu.Unlock()
 }
 
@@ -288,24 +443,46 @@ func (c *Client) Raw() interface{} {
 }
 
 func (c *Client) Do(fn func(interface{}) error) error {
-	for {
+	return c.DoContext(context.Background(), fn)
+}
+
+// DoContext 执行 fn，fn 返回连接错误时刷新连接并在新连接上重试，
+// 非连接错误 (见 ErrorClassifier、Permanent) 直接返回。
+// 等待重连期间 ctx 结束时返回 fn 的错误与 ctx.Err()，超过 MaxDoAttempts 时返回最后一次的错误。
+func (c *Client) DoContext(ctx context.Context, fn func(interface{}) error) error {
+	maxAttempts := maxDoAttempts(c.comp.config)
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		done, err := c.comp.breaker.allow()
+		if err != nil {
+			return errors.Join(lastErr, err)
+		}
 		c.comp.mu.Lock()
 		if c.comp.clientSeq != c.seq {
 			c.seq = c.comp.clientSeq
-			if cg, ok := c.comp.connector.(ClientGetter); ok {
-				c.raw = cg.GetClient()
+			if c.comp.getClient != nil {
+				c.raw = c.comp.getClient()
 			}
 		}
 		raw := c.raw
 		c.comp.mu.Unlock()
 
-		if err := fn(raw); err != nil {
-			if c.comp.tryRefresh(c.seq) {
-				c.comp.WaitReconnect()
-				continue
-			}
+		err = fn(raw)
+		isConnErr := err != nil && c.comp.isConnectionError(err)
+		done(!isConnErr)
+		if !isConnErr {
 			return err
 		}
-		return nil
+		lastErr = err
+		// 其他协程已经刷新过时同样等待重连后重试
+		if !c.comp.tryRefresh(c.seq, err) && c.comp.isTerminating() {
+			return err
+		}
+		if maxAttempts > 0 && attempt >= maxAttempts {
+			return err
+		}
+		if waitErr := c.comp.waitConnected(ctx, true); waitErr != nil {
+			return errors.Join(err, waitErr)
+		}
 	}
 }
@@ -2,7 +2,12 @@ package reconnect
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
+	"math"
+	"math/rand/v2"
+	"os"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -64,8 +69,9 @@ type mockEventHandler struct {
 		attempt int
 		delay   time.Duration
 	}
-	errorCount int
-	lastError  error
+	errorCount        int
+	lastError         error
+	disconnectedCause error
 }
 
 func (h *mockEventHandler) OnConnected() {
@@ -78,6 +84,7 @@ func (h *mockEventHandler) OnDisconnected(err error) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	h.disconnectedCount++
+	h.disconnectedCause = err
 }
 
 func (h *mockEventHandler) OnReconnecting(attempt int, delay time.Duration) {
@@ -225,6 +232,143 @@ func TestBackoff_Reset(t *testing.T) {
 	}
 }
 
+func TestBackoffStrategy_Steps(t *testing.T) {
+	cfg := &DefaultReconnectConfig{
+		InitialInterval: time.Second,
+		MaxInterval:     6 * time.Second,
+	}
+	cases := map[BackoffType][]time.Duration{
+		BackoffConstant:  {time.Second, time.Second, time.Second},
+		BackoffLinear:    {time.Second, 2 * time.Second, 3 * time.Second, 4 * time.Second},
+		BackoffFibonacci: {time.Second, time.Second, 2 * time.Second, 3 * time.Second, 5 * time.Second, 6 * time.Second},
+	}
+	for typ, want := range cases {
+		b := NewBackoffStrategy(typ, cfg, nil)
+		for i, w := range want {
+			if delay := b.NextDelay(); delay != w {
+				t.Errorf("%s: delay %d = %v, want %v", typ, i, delay, w)
+			}
+		}
+		b.Reset()
+		if delay := b.NextDelay(); delay != want[0] {
+			t.Errorf("%s: delay after reset = %v, want %v", typ, delay, want[0])
+		}
+	}
+}
+
+func TestBackoffStrategy_Jitter(t *testing.T) {
+	for _, typ := range []BackoffType{BackoffFullJitter, BackoffDecorrelatedJitter} {
+		newCfg := func() *DefaultReconnectConfig {
+			return &DefaultReconnectConfig{
+				InitialInterval: 100 * time.Millisecond,
+				MaxInterval:     time.Second,
+				Backoff:         typ,
+				RandSource:      rand.NewPCG(1, 2),
+			}
+		}
+		b1, b2 := newCfg().GetBackoffStrategy(), newCfg().GetBackoffStrategy()
+		distinct := map[time.Duration]bool{}
+		for i := 0; i < 20; i++ {
+			d1, d2 := b1.NextDelay(), b2.NextDelay()
+			if d1 != d2 {
+				t.Fatalf("%s: same seed produced %v and %v", typ, d1, d2)
+			}
+			if d1 < 0 || d1 > time.Second {
+				t.Fatalf("%s: delay %v out of range", typ, d1)
+			}
+			if typ == BackoffDecorrelatedJitter && d1 < 100*time.Millisecond {
+				t.Fatalf("%s: delay %v below initial interval", typ, d1)
+			}
+			distinct[d1] = true
+		}
+		if len(distinct) < 2 {
+			t.Fatalf("%s: delays are not jittered", typ)
+		}
+	}
+}
+
+func TestBackoffStrategy_Bounded(t *testing.T) {
+	types := []BackoffType{
+		BackoffExponential, BackoffFullJitter, BackoffDecorrelatedJitter,
+		BackoffConstant, BackoffLinear, BackoffFibonacci,
+	}
+	configs := []*DefaultReconnectConfig{
+		{InitialInterval: 100 * time.Millisecond, MaxInterval: 30 * time.Second, Multiplier: 2},
+		{InitialInterval: time.Second, MaxInterval: time.Hour, Multiplier: 10},
+		{InitialInterval: time.Minute, MaxInterval: time.Second, Multiplier: 3},
+		{InitialInterval: time.Second, MaxInterval: math.MaxInt64, Multiplier: 2},
+	}
+	for _, typ := range types {
+		for _, cfg := range configs {
+			b := NewBackoffStrategy(typ, cfg, rand.NewPCG(1, 2))
+			for i := 0; i < 100; i++ {
+				if d := b.NextDelay(); d < 0 || d > cfg.MaxInterval {
+					t.Fatalf("%s initial %v max %v: delay %d = %v out of range", typ, cfg.InitialInterval, cfg.MaxInterval, i, d)
+				}
+			}
+		}
+	}
+}
+
+// baselineConfig 只实现 ReconnectConfig 本身，不实现任何可选接口
+type baselineConfig struct{}
+
+func (baselineConfig) GetMaxRetries() int                    { return 2 }
+func (baselineConfig) GetInitialInterval() time.Duration     { return 10 * time.Millisecond }
+func (baselineConfig) GetMaxInterval() time.Duration         { return 50 * time.Millisecond }
+func (baselineConfig) GetMultiplier() float64                { return 2 }
+func (baselineConfig) GetCloseTimeout() time.Duration        { return time.Second }
+func (baselineConfig) GetHealthCheckInterval() time.Duration { return 40 * time.Millisecond }
+
+func TestComponent_BaselineConfig(t *testing.T) {
+	var cfg ReconnectConfig = baselineConfig{}
+	if _, ok := backoffStrategy(cfg).(*Backoff); !ok {
+		t.Error("backoff should default to exponential")
+	}
+	if maxElapsedTime(cfg) != 0 || maxDoAttempts(cfg) != 0 || circuitBreakerConfig(cfg) != nil {
+		t.Error("optional limits should be disabled by default")
+	}
+	if healthCheckTimeout(cfg) != 20*time.Millisecond || healthCheckFailureThreshold(cfg) != 1 {
+		t.Error("unexpected health check defaults")
+	}
+
+	comp := New(&mockConnector{}, &mockEventHandler{}, cfg)
+	if err := comp.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer comp.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := comp.WaitConnected(ctx); err != nil {
+		t.Fatalf("baseline config should connect: %v", err)
+	}
+}
+
+func TestComponent_MaxElapsedTime(t *testing.T) {
+	conn := &mockConnector{connectErr: errors.New("always fail")}
+	handler := &mockEventHandler{}
+	cfg := &DefaultReconnectConfig{
+		MaxRetries:      -1,
+		InitialInterval: 20 * time.Millisecond,
+		Backoff:         BackoffConstant,
+		MaxElapsedTime:  100 * time.Millisecond,
+	}
+	comp := New(conn, handler, cfg)
+	comp.Start()
+	defer comp.Close()
+
+	select {
+	case <-comp.doneCh:
+	case <-time.After(time.Second):
+		t.Fatal("component should stop after MaxElapsedTime")
+	}
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	if n := len(handler.reconnectingCalls); n < 3 || n > 5 {
+		t.Fatalf("got %d reconnect attempts within 100ms", n)
+	}
+}
+
 func TestEventHandlers_Multiple(t *testing.T) {
 	h1 := &mockEventHandler{}
 	h2 := &mockEventHandler{}
@@ -381,6 +525,116 @@ func TestClient_Do_RefreshOnError(t *testing.T) {
 	}
 }
 
+func TestClient_Do_ErrorClassification(t *testing.T) {
+	var connectCount int64
+	conn := &mockConnector{}
+	conn.connectFunc = func(context.Context) error {
+		atomic.AddInt64(&connectCount, 1)
+		conn.connected = true
+		return nil
+	}
+	errNotFound := errors.New("not found")
+	cfg := &DefaultReconnectConfig{
+		MaxRetries:      -1,
+		InitialInterval: time.Millisecond,
+		IsConnectionErrorFunc: func(err error) bool {
+			return !errors.Is(err, errNotFound)
+		},
+	}
+	comp := New(conn, nil, cfg)
+	comp.Start()
+	defer comp.Close()
+	comp.WaitReconnect()
+	client := comp.GetClient()
+
+	calls := 0
+	err := client.Do(func(raw interface{}) error {
+		calls++
+		return errNotFound
+	})
+	if !errors.Is(err, errNotFound) || calls != 1 {
+		t.Fatalf("classified error: err=%v calls=%d", err, calls)
+	}
+	calls = 0
+	err = client.Do(func(raw interface{}) error {
+		calls++
+		return Permanent(errors.New("validation failed"))
+	})
+	var permanent *PermanentError
+	if !errors.As(err, &permanent) || calls != 1 {
+		t.Fatalf("permanent error: err=%v calls=%d", err, calls)
+	}
+	if n := atomic.LoadInt64(&connectCount); n != 1 {
+		t.Fatalf("application errors should not reconnect, connectCount=%d", n)
+	}
+
+	calls = 0
+	err = client.Do(func(raw interface{}) error {
+		calls++
+		if calls == 1 {
+			return Retryable(errNotFound)
+		}
+		return nil
+	})
+	if err != nil || calls != 2 || atomic.LoadInt64(&connectCount) != 2 {
+		t.Fatalf("retryable error: err=%v calls=%d connectCount=%d", err, calls, atomic.LoadInt64(&connectCount))
+	}
+}
+
+func TestClient_Do_MaxAttempts(t *testing.T) {
+	conn := &mockConnector{}
+	conn.connectFunc = func(context.Context) error {
+		conn.connected = true
+		return nil
+	}
+	cfg := &DefaultReconnectConfig{MaxRetries: -1, InitialInterval: time.Millisecond, MaxDoAttempts: 3}
+	comp := New(conn, nil, cfg)
+	comp.Start()
+	defer comp.Close()
+	comp.WaitReconnect()
+
+	calls := 0
+	errBroken := errors.New("broken pipe")
+	err := comp.GetClient().Do(func(raw interface{}) error {
+		calls++
+		return errBroken
+	})
+	if !errors.Is(err, errBroken) || calls != 3 {
+		t.Fatalf("err=%v calls=%d, want 3 calls", err, calls)
+	}
+}
+
+func TestClient_DoContext_Cancel(t *testing.T) {
+	var connectCount int64
+	conn := &mockConnector{}
+	conn.connectFunc = func(context.Context) error {
+		if atomic.AddInt64(&connectCount, 1) > 1 {
+			return errors.New("connection refused")
+		}
+		conn.connected = true
+		return nil
+	}
+	cfg := &DefaultReconnectConfig{MaxRetries: -1, InitialInterval: 50 * time.Millisecond}
+	comp := New(conn, nil, cfg)
+	comp.Start()
+	defer comp.Close()
+	comp.WaitReconnect()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	errBroken := errors.New("broken pipe")
+	start := time.Now()
+	err := comp.GetClient().DoContext(ctx, func(raw interface{}) error {
+		return errBroken
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, errBroken) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("DoContext returned after %v", elapsed)
+	}
+}
+
 func TestComponent_ReconnectOnlyConnectsOncePerAttempt(t *testing.T) {
 	var connectCount int64
 	conn := &mockHealthConnector{}
@@ -455,3 +709,996 @@ func TestWaitReconnectReturnsOnClose(t *testing.T) {
 		t.Fatal("WaitReconnect() should return after Close()")
 	}
 }
+
+type typedSession struct {
+	id int
+}
+
+type typedConnector struct {
+	mockConnector
+	session *typedSession
+}
+
+func (c *typedConnector) Connect(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := 1
+	if c.session != nil {
+		id = c.session.id + 1
+	}
+	c.session = &typedSession{id: id}
+	c.connected = true
+	return nil
+}
+
+func (c *typedConnector) GetClient() *typedSession {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.session
+}
+
+func TestTyped_Do(t *testing.T) {
+	conn := &typedConnector{}
+	comp := NewTyped[*typedSession](conn, nil, &DefaultReconnectConfig{InitialInterval: time.Millisecond})
+	comp.Start()
+	defer comp.Close()
+	comp.WaitReconnect()
+
+	client := comp.GetClient()
+	var ids []int
+	err := client.Do(func(s *typedSession) error {
+		ids = append(ids, s.id)
+		if s.id == 1 {
+			return errors.New("broken pipe")
+		}
+		return nil
+	})
+	if err != nil || len(ids) != 2 || ids[1] != 2 {
+		t.Fatalf("err=%v ids=%v", err, ids)
+	}
+	if client.Raw().id != 2 {
+		t.Fatalf("Raw() = %+v", client.Raw())
+	}
+}
+
+func TestClientOf_WrongType(t *testing.T) {
+	conn := &mockConnector{}
+	conn.connectFunc = func(context.Context) error {
+		conn.connected = true
+		return nil
+	}
+	comp := New(conn, nil, &DefaultReconnectConfig{InitialInterval: time.Millisecond})
+	comp.Start()
+	defer comp.Close()
+	comp.WaitReconnect()
+
+	called := false
+	err := ClientOf[*typedSession](comp).Do(func(*typedSession) error {
+		called = true
+		return nil
+	})
+	var permanent *PermanentError
+	if !errors.As(err, &permanent) || called {
+		t.Fatalf("expected PermanentError for wrong client type, got %v", err)
+	}
+	if err := ClientOf[*mockConnector](comp).Do(func(c *mockConnector) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type circuitRecorder struct {
+	NopEventHandler
+	mu          sync.Mutex
+	transitions []CircuitState
+}
+
+func (h *circuitRecorder) OnCircuitStateChange(from, to CircuitState) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.transitions = append(h.transitions, to)
+}
+
+func TestComponent_CircuitBreaker(t *testing.T) {
+	var down atomic.Bool
+	down.Store(true)
+	conn := &mockConnector{}
+	conn.connectFunc = func(context.Context) error {
+		if down.Load() {
+			return errors.New("connection refused")
+		}
+		conn.connected = true
+		return nil
+	}
+	handler := &circuitRecorder{}
+	cfg := &DefaultReconnectConfig{
+		MaxRetries:      -1,
+		InitialInterval: 5 * time.Millisecond,
+		Backoff:         BackoffConstant,
+		CircuitBreaker: &CircuitBreakerConfig{
+			FailureThreshold: 3,
+			Window:           time.Second,
+			OpenTimeout:      time.Hour,
+		},
+	}
+	comp := New(conn, handler, cfg)
+	comp.Start()
+	defer comp.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for comp.CircuitState() != CircuitOpen {
+		if time.Now().After(deadline) {
+			t.Fatal("circuit should open after repeated connect failures")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	start := time.Now()
+	err := comp.GetClient().Do(func(raw interface{}) error { return nil })
+	if !errors.Is(err, ErrCircuitOpen) || time.Since(start) > 100*time.Millisecond {
+		t.Fatalf("Do should fail fast, got %v after %v", err, time.Since(start))
+	}
+
+	down.Store(false)
+	comp.WaitReconnect()
+	if state := comp.CircuitState(); state != CircuitHalfOpen {
+		t.Fatalf("state after reconnect = %v, want half-open", state)
+	}
+	if err := comp.GetClient().Do(func(raw interface{}) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if state := comp.CircuitState(); state != CircuitClosed {
+		t.Fatalf("state after probe = %v, want closed", state)
+	}
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	want := []CircuitState{CircuitOpen, CircuitHalfOpen, CircuitClosed}
+	if len(handler.transitions) != len(want) {
+		t.Fatalf("transitions = %v, want %v", handler.transitions, want)
+	}
+	for i := range want {
+		if handler.transitions[i] != want[i] {
+			t.Fatalf("transitions = %v, want %v", handler.transitions, want)
+		}
+	}
+}
+
+func TestCircuitBreaker_HalfOpenLimit(t *testing.T) {
+	b := newCircuitBreaker(&CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: 10 * time.Millisecond, HalfOpenMaxCalls: 1}, nil)
+	b.record(false)
+	if _, err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow while open: %v", err)
+	}
+	time.Sleep(15 * time.Millisecond)
+	done, err := b.allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second half-open probe should be rejected, got %v", err)
+	}
+	done(false)
+	if b.State() != CircuitOpen {
+		t.Fatalf("failed probe should reopen, got %v", b.State())
+	}
+}
+
+func TestComponent_StateTransitions(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	conn := &mockConnector{}
+	conn.connectFunc = func(context.Context) error {
+		if fail.Swap(false) {
+			return errors.New("connection refused")
+		}
+		conn.connected = true
+		return nil
+	}
+	cfg := &DefaultReconnectConfig{MaxRetries: -1, InitialInterval: 5 * time.Millisecond}
+	comp := New(conn, nil, cfg)
+	if state := comp.State(); state != StateIdle {
+		t.Fatalf("initial state = %v, want idle", state)
+	}
+	changes, cancel := comp.SubscribeState()
+	defer cancel()
+
+	comp.Start()
+	comp.WaitReconnect()
+	comp.Close()
+
+	want := []State{StateConnecting, StateBackoff, StateConnecting, StateConnected, StateClosed}
+	for i, to := range want {
+		select {
+		case change := <-changes:
+			if change.To != to {
+				t.Fatalf("change %d = %v -> %v, want -> %v", i, change.From, change.To, to)
+			}
+			if to == StateBackoff && change.From != StateConnecting {
+				t.Fatalf("backoff from %v, want connecting", change.From)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("missing change %d to %v", i, to)
+		}
+	}
+	if state := comp.State(); state != StateClosed {
+		t.Fatalf("state after Close = %v, want closed", state)
+	}
+}
+
+func TestComponent_StoppedAfterMaxRetries(t *testing.T) {
+	conn := &mockConnector{connectErr: errors.New("connection refused")}
+	cfg := &DefaultReconnectConfig{MaxRetries: 2, InitialInterval: time.Millisecond}
+	comp := New(conn, nil, cfg)
+	comp.Start()
+	comp.WaitReconnect()
+	defer comp.Close()
+
+	stats := comp.Stats()
+	if stats.State != StateStopped {
+		t.Fatalf("state = %v, want stopped", stats.State)
+	}
+	if stats.Attempt != 2 || stats.LastError == nil || stats.LastErrorAt.IsZero() {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestComponent_StatsAndDisconnectCause(t *testing.T) {
+	conn := &mockConnector{}
+	conn.connectFunc = func(context.Context) error {
+		conn.connected = true
+		return nil
+	}
+	handler := &mockEventHandler{}
+	cfg := &DefaultReconnectConfig{MaxRetries: -1, InitialInterval: 20 * time.Millisecond}
+	comp := New(conn, handler, cfg)
+	comp.Start()
+	defer comp.Close()
+	comp.WaitReconnect()
+
+	opErr := errors.New("broken pipe")
+	calls := 0
+	err := comp.GetClient().Do(func(raw interface{}) error {
+		calls++
+		if calls == 1 {
+			return opErr
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := comp.Stats()
+	if stats.State != StateConnected || stats.Reconnects != 1 || stats.Disconnects != 1 || stats.Attempt != 0 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if !errors.Is(stats.LastError, opErr) || stats.ConnectedSince.IsZero() {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if stats.TotalDowntime < 20*time.Millisecond {
+		t.Fatalf("TotalDowntime = %v, want at least the backoff delay", stats.TotalDowntime)
+	}
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	if !errors.Is(handler.disconnectedCause, opErr) {
+		t.Fatalf("OnDisconnected cause = %v, want %v", handler.disconnectedCause, opErr)
+	}
+}
+
+func TestComponent_HealthCheckDisconnectCause(t *testing.T) {
+	pingErr := errors.New("ping timeout")
+	var failing atomic.Bool
+	conn := &mockHealthConnector{}
+	conn.connectFunc = func(context.Context) error {
+		conn.connected = true
+		return nil
+	}
+	conn.pingFunc = func(context.Context) error {
+		if failing.Swap(false) {
+			return pingErr
+		}
+		return nil
+	}
+	handler := &mockEventHandler{}
+	cfg := &DefaultReconnectConfig{
+		MaxRetries:          -1,
+		InitialInterval:     5 * time.Millisecond,
+		HealthCheckInterval: 5 * time.Millisecond,
+	}
+	comp := New(conn, handler, cfg)
+	comp.Start()
+	defer comp.Close()
+	comp.WaitReconnect()
+	seq := comp.GetClient().seq
+
+	failing.Store(true)
+	deadline := time.Now().Add(time.Second)
+	for comp.Stats().Reconnects == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("should reconnect after health check failure")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if comp.GetClient().seq == seq {
+		t.Fatal("clients should refresh after health check failure")
+	}
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	if !errors.Is(handler.disconnectedCause, pingErr) {
+		t.Fatalf("OnDisconnected cause = %v, want %v", handler.disconnectedCause, pingErr)
+	}
+}
+
+type endpointRecorder struct {
+	mockEventHandler
+	changes []Endpoint
+}
+
+func (h *endpointRecorder) OnEndpointChange(from, to Endpoint) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.changes = append(h.changes, to)
+}
+
+func newFailoverFixture(cfg *FailoverConfig, endpoints ...Endpoint) (*FailoverConnector, map[string]*mockConnector) {
+	conns := make(map[string]*mockConnector)
+	for _, ep := range endpoints {
+		conns[ep.Address] = &mockConnector{}
+	}
+	return NewFailoverConnector(endpoints, func(ep Endpoint) Connector {
+		return conns[ep.Address]
+	}, cfg), conns
+}
+
+func setConnectErr(c *mockConnector, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connectErr = err
+}
+
+func TestFailoverConnector_Rotate(t *testing.T) {
+	primary := Endpoint{Address: "a", Priority: 0}
+	fc, conns := newFailoverFixture(nil, primary,
+		Endpoint{Address: "b", Priority: 1},
+		Endpoint{Address: "c", Priority: 1},
+	)
+	setConnectErr(conns["a"], errors.New("connection refused"))
+	handler := &endpointRecorder{}
+	comp := New(fc, handler, &DefaultReconnectConfig{MaxRetries: -1, InitialInterval: 5 * time.Millisecond})
+	comp.Start()
+	defer comp.Close()
+	comp.WaitReconnect()
+
+	first := comp.Stats().Endpoint
+	if first.Priority != 1 {
+		t.Fatalf("connected to %v, want a secondary endpoint", first)
+	}
+	if active, ok := fc.ActiveEndpoint(); !ok || active != first {
+		t.Fatalf("ActiveEndpoint = %v, %v, want %v", active, ok, first)
+	}
+	if comp.GetClient().Raw() != conns[first.Address] {
+		t.Fatal("client should come from the active endpoint connector")
+	}
+
+	calls := 0
+	err := comp.GetClient().Do(func(raw interface{}) error {
+		calls++
+		if calls == 1 {
+			return errors.New("broken pipe")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := comp.Stats().Endpoint
+	if second.Priority != 1 || second == first {
+		t.Fatalf("reconnected to %v, want the other secondary endpoint", second)
+	}
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	if len(handler.changes) != 2 || handler.changes[0] != first || handler.changes[1] != second {
+		t.Fatalf("endpoint changes = %v", handler.changes)
+	}
+}
+
+func TestFailoverConnector_Failback(t *testing.T) {
+	primary := Endpoint{Address: "a", Priority: 0}
+	secondary := Endpoint{Address: "b", Priority: 1}
+	fc, conns := newFailoverFixture(&FailoverConfig{StabilityWindow: 20 * time.Millisecond}, primary, secondary)
+	setConnectErr(conns["a"], errors.New("connection refused"))
+	handler := &endpointRecorder{}
+	comp := New(fc, handler, &DefaultReconnectConfig{
+		MaxRetries:          -1,
+		InitialInterval:     5 * time.Millisecond,
+		HealthCheckInterval: 5 * time.Millisecond,
+	})
+	comp.Start()
+	defer comp.Close()
+	comp.WaitReconnect()
+	if ep := comp.Stats().Endpoint; ep != secondary {
+		t.Fatalf("connected to %v, want %v", ep, secondary)
+	}
+
+	// 主节点不可用时探测不应触发重连
+	time.Sleep(60 * time.Millisecond)
+	if stats := comp.Stats(); stats.Reconnects != 0 {
+		t.Fatalf("reconnected %d times while primary was down", stats.Reconnects)
+	}
+
+	setConnectErr(conns["a"], nil)
+	deadline := time.Now().Add(time.Second)
+	for comp.Stats().Endpoint != primary {
+		if time.Now().After(deadline) {
+			t.Fatal("should fail back to the primary endpoint")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if conns["b"].IsConnected() {
+		t.Fatal("secondary should be disconnected after failback")
+	}
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	if !errors.Is(handler.disconnectedCause, ErrFailback) {
+		t.Fatalf("OnDisconnected cause = %v, want ErrFailback", handler.disconnectedCause)
+	}
+}
+
+func TestFailoverConnector_Weight(t *testing.T) {
+	fc, _ := newFailoverFixture(&FailoverConfig{RandSource: rand.NewPCG(1, 2)},
+		Endpoint{Address: "light", Weight: 1},
+		Endpoint{Address: "heavy", Weight: 9},
+		Endpoint{Address: "backup", Priority: 1, Weight: 100},
+	)
+	heavyFirst := 0
+	for range 1000 {
+		order := fc.orderByPriorityLocked()
+		if order[2].Address != "backup" {
+			t.Fatalf("lower priority endpoint ordered before %v", order[2])
+		}
+		if order[0].Address == "heavy" {
+			heavyFirst++
+		}
+	}
+	if heavyFirst < 850 || heavyFirst > 950 {
+		t.Fatalf("heavy endpoint first %d/1000 times, want about 900", heavyFirst)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool, msg string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPool_RoundRobin(t *testing.T) {
+	pool := NewPool(func() Connector { return &mockConnector{} }, nil,
+		&DefaultReconnectConfig{InitialInterval: 5 * time.Millisecond}, &PoolConfig{MinSize: 3})
+	if _, err := pool.Acquire(); !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("Acquire before Start = %v, want ErrPoolClosed", err)
+	}
+	pool.Start()
+	defer pool.Close()
+	waitFor(t, func() bool { return pool.Stats().Connected == 3 }, "all members should connect")
+
+	seen := make(map[interface{}]bool)
+	for range 3 {
+		client, err := pool.Acquire()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Release()
+		seen[client.Raw()] = true
+	}
+	if len(seen) != 3 {
+		t.Fatalf("round robin used %d members, want 3", len(seen))
+	}
+	if stats := pool.Stats(); stats.Size != 3 || stats.InFlight != 3 || len(stats.Members) != 3 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestPool_LeastInFlightGrow(t *testing.T) {
+	pool := NewPool(func() Connector { return &mockConnector{} }, nil,
+		&DefaultReconnectConfig{InitialInterval: 5 * time.Millisecond},
+		&PoolConfig{MinSize: 1, MaxSize: 2, Strategy: PoolLeastInFlight})
+	pool.Start()
+	defer pool.Close()
+	waitFor(t, func() bool { return pool.Stats().Connected == 1 }, "member should connect")
+
+	first, _ := pool.Acquire()
+	defer first.Release()
+	// 唯一的成员忙碌时扩容，本次仍使用已连接的成员
+	second, _ := pool.Acquire()
+	if second.Raw() != first.Raw() {
+		t.Fatal("should use the connected member while the new one connects")
+	}
+	second.Release()
+	waitFor(t, func() bool { return pool.Stats().Connected == 2 }, "pool should grow to MaxSize")
+
+	third, _ := pool.Acquire()
+	defer third.Release()
+	if third.Raw() == first.Raw() {
+		t.Fatal("least in-flight should pick the idle member")
+	}
+	fourth, _ := pool.Acquire()
+	fourth.Release()
+	if stats := pool.Stats(); stats.Size != 2 {
+		t.Fatalf("size = %d, want at most MaxSize", stats.Size)
+	}
+}
+
+func TestPool_ReplaceStoppedMember(t *testing.T) {
+	var created atomic.Int32
+	pool := NewPool(func() Connector {
+		if created.Add(1) == 1 {
+			return &mockConnector{connectErr: errors.New("connection refused")}
+		}
+		return &mockConnector{}
+	}, nil, &DefaultReconnectConfig{MaxRetries: 1, InitialInterval: time.Millisecond}, &PoolConfig{MinSize: 2})
+	pool.Start()
+	defer pool.Close()
+
+	waitFor(t, func() bool {
+		stats := pool.Stats()
+		return stats.Replaced == 1 && stats.Connected == 2
+	}, "stopped member should be replaced")
+	if err := pool.Do(func(raw interface{}) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPool_ReplaceStoppedMemberWithoutNotification(t *testing.T) {
+	for _, name := range []string{"acquire", "shrink tick"} {
+		t.Run(name, func(t *testing.T) {
+			var created atomic.Int32
+			poolConfig := &PoolConfig{MinSize: 2}
+			if name == "shrink tick" {
+				poolConfig.IdleTimeout = 20 * time.Millisecond
+			}
+			pool := NewPool(func() Connector {
+				if created.Add(1) == 1 {
+					return &mockConnector{connectErr: errors.New("connection refused")}
+				}
+				return &mockConnector{}
+			}, nil, &DefaultReconnectConfig{MaxRetries: 1, InitialInterval: 50 * time.Millisecond}, poolConfig)
+			pool.Start()
+			defer pool.Close()
+
+			// 模拟 SubscribeState 缓冲区满丢弃了 StateStopped：watch 退出，不再替换该成员
+			pool.mu.Lock()
+			stopped := pool.members[0]
+			pool.mu.Unlock()
+			stopped.unsubscribe()
+
+			if name == "acquire" {
+				waitFor(t, func() bool { return stopped.comp.State() == StateStopped }, "member should stop reconnecting")
+				client, err := pool.Acquire()
+				if err != nil {
+					t.Fatal(err)
+				}
+				client.Release()
+			}
+			waitFor(t, func() bool {
+				stats := pool.Stats()
+				return stats.Replaced == 1 && stats.Connected == 2
+			}, "stopped member should be replaced")
+		})
+	}
+}
+
+func TestPool_IdleShrink(t *testing.T) {
+	pool := NewPool(func() Connector { return &mockConnector{} }, nil,
+		&DefaultReconnectConfig{InitialInterval: 5 * time.Millisecond},
+		&PoolConfig{MinSize: 1, MaxSize: 3, IdleTimeout: 20 * time.Millisecond})
+	pool.Start()
+	defer pool.Close()
+	waitFor(t, func() bool { return pool.Stats().Connected == 1 }, "member should connect")
+
+	first, _ := pool.Acquire()
+	second, _ := pool.Acquire()
+	second.Release()
+	waitFor(t, func() bool { return pool.Stats().Connected == 2 }, "pool should grow")
+	first.Release()
+
+	waitFor(t, func() bool {
+		stats := pool.Stats()
+		return stats.Size == 1 && stats.Shrunk == 1
+	}, "idle member should be closed")
+}
+
+type bufferFixture struct {
+	comp *Component
+	up   atomic.Bool
+	mu   sync.Mutex
+	sent []string
+	drop []error
+}
+
+func newBufferFixture(t *testing.T) *bufferFixture {
+	f := &bufferFixture{}
+	conn := &mockConnector{}
+	conn.connectFunc = func(context.Context) error {
+		if !f.up.Load() {
+			return errors.New("connection refused")
+		}
+		conn.connected = true
+		return nil
+	}
+	f.comp = New(conn, nil, &DefaultReconnectConfig{
+		MaxRetries:      -1,
+		InitialInterval: 2 * time.Millisecond,
+		Backoff:         BackoffConstant,
+	})
+	f.comp.Start()
+	t.Cleanup(func() { f.comp.Close() })
+	return f
+}
+
+func (f *bufferFixture) newBuffer(t *testing.T, cfg *BufferConfig) *Buffer {
+	cfg.OnDrop = func(payload []byte, err error) {
+		f.drop = append(f.drop, err)
+	}
+	b, err := NewBuffer(f.comp, func(raw interface{}, payload []byte) error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if string(payload) == "bad" {
+			return Permanent(errors.New("rejected"))
+		}
+		f.sent = append(f.sent, string(payload))
+		return nil
+	}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return b
+}
+
+func (f *bufferFixture) sentPayloads() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.sent)
+}
+
+func TestBuffer_ReplayInOrder(t *testing.T) {
+	f := newBufferFixture(t)
+	b := f.newBuffer(t, &BufferConfig{})
+	want := []string{"a", "b", "bad", "c", "d"}
+	for _, p := range want {
+		if err := b.Send(context.Background(), []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	if sent := f.sentPayloads(); len(sent) != 0 {
+		t.Fatalf("sent %v while disconnected", sent)
+	}
+
+	f.up.Store(true)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := b.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if sent := f.sentPayloads(); !slices.Equal(sent, []string{"a", "b", "c", "d"}) {
+		t.Fatalf("sent %v", sent)
+	}
+	if stats := b.Stats(); stats.Sent != 4 || stats.Dropped != 1 || stats.Queued != 0 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+// fillBuffer 写入 n 条数据，第一条由发送协程取出等待重连，不占用 Capacity
+func fillBuffer(t *testing.T, b *Buffer, n int) {
+	t.Helper()
+	b.Send(context.Background(), []byte("0"))
+	waitFor(t, func() bool { return b.Stats().Queued == 0 }, "sender should take the first item")
+	for i := 1; i < n; i++ {
+		if err := b.Send(context.Background(), []byte{byte('0' + i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBuffer_Overflow(t *testing.T) {
+	t.Run("drop oldest", func(t *testing.T) {
+		f := newBufferFixture(t)
+		b := f.newBuffer(t, &BufferConfig{Capacity: 2})
+		fillBuffer(t, b, 4)
+		f.up.Store(true)
+		b.Flush(context.Background())
+		if sent := f.sentPayloads(); !slices.Equal(sent, []string{"0", "2", "3"}) {
+			t.Fatalf("sent %v", sent)
+		}
+		if len(f.drop) != 1 || !errors.Is(f.drop[0], ErrBufferFull) {
+			t.Fatalf("dropped %v", f.drop)
+		}
+	})
+	t.Run("drop newest", func(t *testing.T) {
+		f := newBufferFixture(t)
+		b := f.newBuffer(t, &BufferConfig{Capacity: 2, Overflow: OverflowDropNewest})
+		fillBuffer(t, b, 3)
+		if err := b.Send(context.Background(), []byte("3")); !errors.Is(err, ErrBufferFull) {
+			t.Fatalf("Send = %v, want ErrBufferFull", err)
+		}
+		f.up.Store(true)
+		b.Flush(context.Background())
+		if sent := f.sentPayloads(); !slices.Equal(sent, []string{"0", "1", "2"}) {
+			t.Fatalf("sent %v", sent)
+		}
+	})
+	t.Run("block", func(t *testing.T) {
+		f := newBufferFixture(t)
+		b := f.newBuffer(t, &BufferConfig{Capacity: 2, Overflow: OverflowBlock})
+		fillBuffer(t, b, 3)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if err := b.Send(ctx, []byte("3")); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Send = %v, want DeadlineExceeded", err)
+		}
+		done := make(chan error)
+		go func() { done <- b.Send(context.Background(), []byte("3")) }()
+		f.up.Store(true)
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+		b.Flush(context.Background())
+		if sent := f.sentPayloads(); !slices.Equal(sent, []string{"0", "1", "2", "3"}) {
+			t.Fatalf("sent %v", sent)
+		}
+	})
+}
+
+func TestBuffer_Expiry(t *testing.T) {
+	f := newBufferFixture(t)
+	b := f.newBuffer(t, &BufferConfig{TTL: 10 * time.Millisecond})
+	b.Send(context.Background(), []byte("stale"))
+	b.SendTTL(context.Background(), []byte("fresh"), 0)
+	time.Sleep(20 * time.Millisecond)
+	f.up.Store(true)
+	b.Flush(context.Background())
+	if sent := f.sentPayloads(); !slices.Equal(sent, []string{"fresh"}) {
+		t.Fatalf("sent %v", sent)
+	}
+	if stats := b.Stats(); stats.Expired != 1 || len(f.drop) != 1 || !errors.Is(f.drop[0], ErrBufferExpired) {
+		t.Fatalf("stats = %+v, dropped %v", stats, f.drop)
+	}
+}
+
+func TestBuffer_ComponentClosed(t *testing.T) {
+	f := newBufferFixture(t)
+	b := f.newBuffer(t, &BufferConfig{})
+	b.Send(context.Background(), []byte("a"))
+	f.comp.Close()
+	b.Send(context.Background(), []byte("b"))
+	// Component 关闭后不会再连接，剩余数据被丢弃而不是反复重试
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := b.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if stats := b.Stats(); stats.Dropped != 2 || stats.Queued != 0 || len(f.drop) != 2 || !errors.Is(f.drop[1], ErrClosed) {
+		t.Fatalf("stats = %+v, dropped %v", stats, f.drop)
+	}
+}
+
+func TestBuffer_Spill(t *testing.T) {
+	dir := t.TempDir()
+	f := newBufferFixture(t)
+	b := f.newBuffer(t, &BufferConfig{Capacity: 2, SpillDir: dir, MaxSpillBytes: 5 * (spillHeaderSize + 1)})
+	fillBuffer(t, b, 8)
+	if stats := b.Stats(); stats.Queued != 7 || stats.Spilled != 5 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	// 磁盘也满时丢弃最早的数据
+	b.Send(context.Background(), []byte("8"))
+	f.up.Store(true)
+	b.Flush(context.Background())
+	if sent := f.sentPayloads(); !slices.Equal(sent, []string{"0", "2", "3", "4", "5", "6", "7", "8"}) {
+		t.Fatalf("sent %v", sent)
+	}
+	b.Close()
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("spill file should be removed, got %v", entries)
+	}
+}
+
+func TestBuffer_SpillCompacts(t *testing.T) {
+	const record = spillHeaderSize + 8
+	maxBytes := int64(10 * record)
+	s, err := newSpillFile(t.TempDir(), maxBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.close()
+	push := func(i int) {
+		payload := make([]byte, 8)
+		binary.BigEndian.PutUint64(payload, uint64(i))
+		if err := s.push(bufferItem{payload: payload}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		push(i)
+	}
+	// 磁盘中始终有数据，不会因读完而截断
+	for i := 0; i < 1000; i++ {
+		push(i + 5)
+		item, err := s.pop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := binary.BigEndian.Uint64(item.payload); got != uint64(i) {
+			t.Fatalf("pop %d got record %d", i, got)
+		}
+		info, err := s.f.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > 2*maxBytes+record {
+			t.Fatalf("spill file grew to %d bytes after %d cycles", info.Size(), i)
+		}
+	}
+	if s.count != 5 {
+		t.Fatalf("count = %d, want 5", s.count)
+	}
+}
+
+func TestComponent_HealthCheckFailureThreshold(t *testing.T) {
+	pingErr := errors.New("ping failed")
+	var pings, failing atomic.Int32
+	conn := &mockHealthConnector{}
+	conn.connectFunc = func(context.Context) error {
+		conn.connected = true
+		return nil
+	}
+	conn.pingFunc = func(context.Context) error {
+		n := pings.Add(1)
+		// 每两次失败后成功一次，不应达到阈值
+		if failing.Load() == 1 || n%3 != 0 {
+			return pingErr
+		}
+		return nil
+	}
+	handler := &mockEventHandler{}
+	comp := New(conn, handler, &DefaultReconnectConfig{
+		MaxRetries:                  -1,
+		InitialInterval:             5 * time.Millisecond,
+		HealthCheckInterval:         2 * time.Millisecond,
+		HealthCheckFailureThreshold: 3,
+	})
+	comp.Start()
+	defer comp.Close()
+	comp.WaitReconnect()
+
+	waitFor(t, func() bool { return pings.Load() >= 12 }, "health check should keep pinging")
+	if stats := comp.Stats(); stats.Disconnects != 0 || !errors.Is(stats.LastError, pingErr) {
+		t.Fatalf("stats = %+v, want no disconnect below threshold", stats)
+	}
+
+	failing.Store(1)
+	waitFor(t, func() bool { return comp.Stats().Disconnects == 1 }, "should disconnect after consecutive failures")
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	if !errors.Is(handler.disconnectedCause, pingErr) {
+		t.Fatalf("OnDisconnected cause = %v", handler.disconnectedCause)
+	}
+}
+
+func TestComponent_HealthCheckTimeout(t *testing.T) {
+	conn := &mockHealthConnector{}
+	conn.connectFunc = func(context.Context) error {
+		conn.connected = true
+		return nil
+	}
+	conn.pingFunc = func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	handler := &mockEventHandler{}
+	comp := New(conn, handler, &DefaultReconnectConfig{
+		MaxRetries:          -1,
+		InitialInterval:     time.Hour,
+		HealthCheckInterval: 5 * time.Millisecond,
+		HealthCheckTimeout:  time.Millisecond,
+	})
+	comp.Start()
+	defer comp.Close()
+	comp.WaitReconnect()
+
+	waitFor(t, func() bool { return comp.Stats().Disconnects == 1 }, "ping timeout should disconnect")
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	if !errors.Is(handler.disconnectedCause, context.DeadlineExceeded) {
+		t.Fatalf("OnDisconnected cause = %v, want DeadlineExceeded", handler.disconnectedCause)
+	}
+}
+
+func TestComponent_WaitConnected(t *testing.T) {
+	comp := New(&mockConnector{connectErr: errors.New("connection refused")}, nil,
+		&DefaultReconnectConfig{MaxRetries: 1, InitialInterval: time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if err := comp.WaitConnected(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitConnected before Start = %v, want DeadlineExceeded", err)
+	}
+	comp.Start()
+	if err := comp.WaitConnected(context.Background()); !errors.Is(err, ErrStopped) {
+		t.Fatalf("WaitConnected = %v, want ErrStopped", err)
+	}
+	comp.Close()
+	if err := comp.WaitConnected(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Fatalf("WaitConnected after Close = %v, want ErrClosed", err)
+	}
+
+	ok := New(&mockConnector{}, nil, nil)
+	ok.Start()
+	defer ok.Close()
+	if err := ok.WaitConnected(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestComponent_StartAndWait(t *testing.T) {
+	comp := New(&mockConnector{}, nil, nil)
+	if err := comp.StartAndWait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	comp.Close()
+
+	connErr := errors.New("connection refused")
+	comp = New(&mockConnector{connectErr: connErr}, nil,
+		&DefaultReconnectConfig{MaxRetries: -1, InitialInterval: time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := comp.StartAndWait(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, connErr) {
+		t.Fatalf("StartAndWait = %v, want deadline and last connect error", err)
+	}
+	if state := comp.State(); state != StateClosed {
+		t.Fatalf("state = %v, want closed after failed StartAndWait", state)
+	}
+}
+
+type notifierConnector struct {
+	mockConnector
+	lost chan error
+}
+
+func (c *notifierConnector) NotifyDisconnect() <-chan error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lost = make(chan error, 1)
+	return c.lost
+}
+
+func TestComponent_DisconnectNotifier(t *testing.T) {
+	conn := &notifierConnector{}
+	handler := &mockEventHandler{}
+	comp := New(conn, handler, &DefaultReconnectConfig{MaxRetries: -1, InitialInterval: time.Millisecond})
+	comp.Start()
+	defer comp.Close()
+	comp.WaitReconnect()
+
+	serverErr := errors.New("connection reset by peer")
+	conn.mu.Lock()
+	conn.lost <- serverErr
+	conn.mu.Unlock()
+	waitFor(t, func() bool { return comp.Stats().Reconnects == 1 }, "should reconnect after lost notification")
+
+	// channel 关闭视为断开
+	conn.mu.Lock()
+	close(conn.lost)
+	conn.mu.Unlock()
+	waitFor(t, func() bool { return comp.Stats().Reconnects == 2 }, "should reconnect after channel close")
+
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	if handler.disconnectedCount != 2 || !errors.Is(handler.disconnectedCause, ErrConnectionLost) {
+		t.Fatalf("disconnected %d times, last cause %v", handler.disconnectedCount, handler.disconnectedCause)
+	}
+}
@@ -1,6 +1,9 @@
 package reconnect
 
-import "time"
+import (
+	"math/rand/v2"
+	"time"
+)
 
 type ReconnectConfig interface {
 	GetMaxRetries() int
@@ -11,6 +14,79 @@ type ReconnectConfig interface {
 	GetHealthCheckInterval() time.Duration
 }
 
+// BackoffConfig 由 ReconnectConfig 可选实现，未实现时使用 NewBackoff 的指数退避。
+// GetBackoffStrategy 每个 Component 调用一次，返回的实例由该 Component 独占
+type BackoffConfig interface {
+	GetBackoffStrategy() BackoffStrategy
+}
+
+// MaxElapsedTimeConfig 由 ReconnectConfig 可选实现，限制一次断线后重连的总时长，
+// 超过后与超过 MaxRetries 一样停止重连；未实现或返回 0 表示不限制
+type MaxElapsedTimeConfig interface {
+	GetMaxElapsedTime() time.Duration
+}
+
+// MaxDoAttemptsConfig 由 ReconnectConfig 可选实现，限制单次 Client.Do 执行回调的次数；
+// 未实现或返回 0 表示不限制
+type MaxDoAttemptsConfig interface {
+	GetMaxDoAttempts() int
+}
+
+// BreakerConfig 由 ReconnectConfig 可选实现，未实现或返回 nil 时不启用熔断
+type BreakerConfig interface {
+	GetCircuitBreaker() *CircuitBreakerConfig
+}
+
+// HealthCheckConfig 由 ReconnectConfig 可选实现。GetHealthCheckTimeout 为单次 SendPing 的超时时间，
+// GetHealthCheckFailureThreshold 为断开重连前允许的连续失败次数；
+// 未实现时分别为 HealthCheckInterval / 2 与 1
+type HealthCheckConfig interface {
+	GetHealthCheckTimeout() time.Duration
+	GetHealthCheckFailureThreshold() int
+}
+
+func backoffStrategy(cfg ReconnectConfig) BackoffStrategy {
+	if c, ok := cfg.(BackoffConfig); ok {
+		return c.GetBackoffStrategy()
+	}
+	return NewBackoff(cfg)
+}
+
+func maxElapsedTime(cfg ReconnectConfig) time.Duration {
+	if c, ok := cfg.(MaxElapsedTimeConfig); ok {
+		return c.GetMaxElapsedTime()
+	}
+	return 0
+}
+
+func maxDoAttempts(cfg ReconnectConfig) int {
+	if c, ok := cfg.(MaxDoAttemptsConfig); ok {
+		return c.GetMaxDoAttempts()
+	}
+	return 0
+}
+
+func circuitBreakerConfig(cfg ReconnectConfig) *CircuitBreakerConfig {
+	if c, ok := cfg.(BreakerConfig); ok {
+		return c.GetCircuitBreaker()
+	}
+	return nil
+}
+
+func healthCheckTimeout(cfg ReconnectConfig) time.Duration {
+	if c, ok := cfg.(HealthCheckConfig); ok {
+		return c.GetHealthCheckTimeout()
+	}
+	return cfg.GetHealthCheckInterval() / 2
+}
+
+func healthCheckFailureThreshold(cfg ReconnectConfig) int {
+	if c, ok := cfg.(HealthCheckConfig); ok {
+		return c.GetHealthCheckFailureThreshold()
+	}
+	return 1
+}
+
 type DefaultReconnectConfig struct {
 	MaxRetries          int
 	InitialInterval     time.Duration
@@ -18,6 +94,19 @@ type DefaultReconnectConfig struct {
 	Multiplier          float64
 	CloseTimeout        time.Duration
 	HealthCheckInterval time.Duration
+	// HealthCheckTimeout 默认 HealthCheckInterval / 2
+	HealthCheckTimeout time.Duration
+	// HealthCheckFailureThreshold 默认 1，即第一次失败就断开
+	HealthCheckFailureThreshold int
+	// Backoff 退避策略，默认 BackoffExponential
+	Backoff BackoffType
+	// RandSource 抖动使用的随机源，测试中可传入固定种子的 rand.NewPCG 得到确定的序列，默认随机种子
+	RandSource     rand.Source
+	MaxElapsedTime time.Duration
+	MaxDoAttempts  int
+	// IsConnectionErrorFunc 见 ErrorClassifier，为 nil 时所有错误都视为连接错误
+	IsConnectionErrorFunc func(err error) bool
+	CircuitBreaker        *CircuitBreakerConfig
 }
 
 func (c *DefaultReconnectConfig) GetMaxRetries() int {
@@ -58,3 +147,40 @@ func (c *DefaultReconnectConfig) GetCloseTimeout() time.Duration {
 func (c *DefaultReconnectConfig) GetHealthCheckInterval() time.Duration {
 	return c.HealthCheckInterval
 }
+
+func (c *DefaultReconnectConfig) GetHealthCheckTimeout() time.Duration {
+	if c.HealthCheckTimeout <= 0 {
+		return c.HealthCheckInterval / 2
+	}
+	return c.HealthCheckTimeout
+}
+
+func (c *DefaultReconnectConfig) GetHealthCheckFailureThreshold() int {
+	if c.HealthCheckFailureThreshold <= 0 {
+		return 1
+	}
+	return c.HealthCheckFailureThreshold
+}
+
+func (c *DefaultReconnectConfig) GetBackoffStrategy() BackoffStrategy {
+	return NewBackoffStrategy(c.Backoff, c, c.RandSource)
+}
+
+func (c *DefaultReconnectConfig) GetMaxElapsedTime() time.Duration {
+	return c.MaxElapsedTime
+}
+
+func (c *DefaultReconnectConfig) GetMaxDoAttempts() int {
+	return c.MaxDoAttempts
+}
+
+func (c *DefaultReconnectConfig) IsConnectionError(err error) bool {
+	if c.IsConnectionErrorFunc == nil {
+		return true
+	}
+	return c.IsConnectionErrorFunc(err)
+}
+
+func (c *DefaultReconnectConfig) GetCircuitBreaker() *CircuitBreakerConfig {
+	return c.CircuitBreaker
+}
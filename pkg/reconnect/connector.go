@@ -1,6 +1,9 @@
 package reconnect
 
-import "context"
+import (
+	"context"
+	"errors"
+)
 
 type Connector interface {
 	Connect(ctx context.Context) error
@@ -21,6 +24,15 @@ type ClientGetter interface {
 	GetClient() interface{}
 }
 
+// ErrConnectionLost DisconnectNotifier 的 channel 被关闭而没有给出错误时作为断开原因
+var ErrConnectionLost = errors.New("reconnect: connection lost")
+
+// DisconnectNotifier 由能感知连接断开的 Connector 实现 (如 AMQP 的 NotifyClose、gRPC 的连接状态)。
+// 每次 Connect 成功后 Component 调用一次 NotifyDisconnect，收到错误或 channel 被关闭时立即断开重连，不必等待健康检查
+type DisconnectNotifier interface {
+	NotifyDisconnect() <-chan error
+}
+
 type ConnectFunc func(ctx context.Context) error
 type DisconnectFunc func() error
 
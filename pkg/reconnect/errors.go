@@ -0,0 +1,75 @@
+package reconnect
+
+import "errors"
+
+// ErrorClassifier 判断 Client.Do 回调返回的错误是否说明连接已损坏。
+// Connector 或 ReconnectConfig 实现此接口时生效，Connector 优先；都未实现时所有错误都视为连接错误。
+type ErrorClassifier interface {
+	IsConnectionError(err error) bool
+}
+
+type ErrorClassifierFunc func(err error) bool
+
+func (f ErrorClassifierFunc) IsConnectionError(err error) bool {
+	return f(err)
+}
+
+// PermanentError 表示与连接无关的错误，Do 直接返回，不会刷新连接
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// RetryableError 表示连接已损坏，Do 刷新连接后在新连接上重试
+type RetryableError struct {
+	Err error
+}
+
+func (e *RetryableError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryableError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent 包装 err 使其不触发重连，err 为 nil 时返回 nil
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// Retryable 包装 err 使其刷新连接并重试，优先于 ErrorClassifier，err 为 nil 时返回 nil
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &RetryableError{Err: err}
+}
+
+func (c *Component) isConnectionError(err error) bool {
+	var permanent *PermanentError
+	if errors.As(err, &permanent) {
+		return false
+	}
+	var retryable *RetryableError
+	if errors.As(err, &retryable) {
+		return true
+	}
+	if classifier, ok := c.connector.(ErrorClassifier); ok {
+		return classifier.IsConnectionError(err)
+	}
+	if classifier, ok := c.config.(ErrorClassifier); ok {
+		return classifier.IsConnectionError(err)
+	}
+	return true
+}
@@ -15,6 +15,8 @@ func (h *NopEventHandler) OnConnected()                                    {}
 func (h *NopEventHandler) OnDisconnected(err error)                        {}
 func (h *NopEventHandler) OnReconnecting(attempt int, delay time.Duration) {}
 func (h *NopEventHandler) OnError(err error)                               {}
+func (h *NopEventHandler) OnCircuitStateChange(from, to CircuitState)      {}
+func (h *NopEventHandler) OnEndpointChange(from, to Endpoint)              {}
 
 type EventHandlers []EventHandler
 
@@ -41,3 +43,19 @@ func (ehs EventHandlers) OnError(err error) {
 		h.OnError(err)
 	}
 }
+
+func (ehs EventHandlers) OnCircuitStateChange(from, to CircuitState) {
+	for _, h := range ehs {
+		if ch, ok := h.(CircuitEventHandler); ok {
+			ch.OnCircuitStateChange(from, to)
+		}
+	}
+}
+
+func (ehs EventHandlers) OnEndpointChange(from, to Endpoint) {
+	for _, h := range ehs {
+		if eh, ok := h.(EndpointEventHandler); ok {
+			eh.OnEndpointChange(from, to)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package reconnect
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrFailback 由 FailoverConnector 的健康检查返回，表示更高优先级的 endpoint 已恢复，需要切回
+var ErrFailback = errors.New("reconnect: failback to preferred endpoint")
+
+type Endpoint struct {
+	Address string
+	// Priority 越小越优先，只有同优先级的 endpoint 都失败后才会使用下一优先级
+	Priority int
+	// Weight 同优先级内按权重随机选择，<= 0 视为 1
+	Weight int
+}
+
+// EndpointProvider 由连接多个地址的 Connector 实现，Component 在每次连接成功后读取当前 endpoint
+type EndpointProvider interface {
+	ActiveEndpoint() (Endpoint, bool)
+}
+
+// EndpointEventHandler 由 EventHandler 选择实现，接收连接成功后 endpoint 的变化，首次连接时 from 为零值
+type EndpointEventHandler interface {
+	OnEndpointChange(from, to Endpoint)
+}
+
+type FailoverConfig struct {
+	// StabilityWindow 连接在低优先级 endpoint 上稳定该时长后，每隔该时长在健康检查中探测更高优先级的 endpoint，
+	// 可用时返回 ErrFailback 触发重连并切回。需要 HealthCheckInterval > 0，默认 5m，< 0 表示不切回
+	StabilityWindow time.Duration
+	// RandSource 按权重选择使用的随机源，默认随机种子
+	RandSource rand.Source
+}
+
+func (c *FailoverConfig) GetStabilityWindow() time.Duration {
+	if c.StabilityWindow == 0 {
+		return 5 * time.Minute
+	}
+	return c.StabilityWindow
+}
+
+type endpointState struct {
+	Endpoint
+	connector Connector
+	failedAt  time.Time
+}
+
+// FailoverConnector 在多个 endpoint 之间故障转移，每个 endpoint 由 factory 创建一个 Connector 并复用。
+// Connect 依次尝试各 endpoint 直到成功：未失败过的按优先级与权重排在前面，失败过的按失败时间排在后面，
+// 因此当前 endpoint 断开后会先尝试下一个 endpoint。Disconnect 会把当前 endpoint 记为失败。
+type FailoverConnector struct {
+	endpoints []*endpointState
+	factory   func(Endpoint) Connector
+	config    *FailoverConfig
+
+	mu          sync.Mutex
+	rand        *rand.Rand
+	active      *endpointState
+	activeSince time.Time
+	lastProbe   time.Time
+	// failingBack 探测到更高优先级的 endpoint 可用，接下来的 Disconnect 不把当前 endpoint 记为失败
+	failingBack bool
+}
+
+func NewFailoverConnector(endpoints []Endpoint, factory func(Endpoint) Connector, config *FailoverConfig) *FailoverConnector {
+	if config == nil {
+		config = &FailoverConfig{}
+	}
+	f := &FailoverConnector{
+		factory: factory,
+		config:  config,
+		rand:    newRand(config.RandSource),
+	}
+	for _, ep := range endpoints {
+		f.endpoints = append(f.endpoints, &endpointState{Endpoint: ep})
+	}
+	return f
+}
+
+func (f *FailoverConnector) Connect(ctx context.Context) error {
+	f.mu.Lock()
+	candidates := f.orderLocked()
+	f.mu.Unlock()
+
+	if len(candidates) == 0 {
+		return errors.New("reconnect: no endpoints")
+	}
+	var errs []error
+	for _, ep := range candidates {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if err := f.connectorOf(ep).Connect(ctx); err != nil {
+			f.mu.Lock()
+			ep.failedAt = time.Now()
+			f.mu.Unlock()
+			errs = append(errs, fmt.Errorf("%s: %w", ep.Address, err))
+			continue
+		}
+		f.mu.Lock()
+		f.setActiveLocked(ep)
+		f.mu.Unlock()
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+func (f *FailoverConnector) Disconnect() error {
+	f.mu.Lock()
+	active := f.active
+	f.active = nil
+	if active != nil && !f.failingBack {
+		active.failedAt = time.Now()
+	}
+	f.failingBack = false
+	f.mu.Unlock()
+	if active == nil {
+		return nil
+	}
+	return active.connector.Disconnect()
+}
+
+func (f *FailoverConnector) IsConnected() bool {
+	f.mu.Lock()
+	active := f.active
+	f.mu.Unlock()
+	return active != nil && active.connector.IsConnected()
+}
+
+// GetClient 返回当前 endpoint 的 Connector 提供的客户端，未连接或其未实现 ClientGetter 时返回 nil
+func (f *FailoverConnector) GetClient() interface{} {
+	f.mu.Lock()
+	active := f.active
+	f.mu.Unlock()
+	if active == nil {
+		return nil
+	}
+	if cg, ok := active.connector.(ClientGetter); ok {
+		return cg.GetClient()
+	}
+	return nil
+}
+
+// SendPing 检查当前 endpoint，并在满足 StabilityWindow 时探测更高优先级的 endpoint
+func (f *FailoverConnector) SendPing(ctx context.Context) error {
+	f.mu.Lock()
+	active := f.active
+	f.mu.Unlock()
+	if active == nil {
+		return errors.New("reconnect: not connected")
+	}
+	if hc, ok := active.connector.(HealthCheckConnector); ok {
+		if err := hc.SendPing(ctx); err != nil {
+			return err
+		}
+	}
+	return f.probe(ctx, active)
+}
+
+// NotifyDisconnect 转发当前 endpoint 的 Connector 的 DisconnectNotifier，未实现时返回 nil
+func (f *FailoverConnector) NotifyDisconnect() <-chan error {
+	f.mu.Lock()
+	active := f.active
+	f.mu.Unlock()
+	if active == nil {
+		return nil
+	}
+	if n, ok := active.connector.(DisconnectNotifier); ok {
+		return n.NotifyDisconnect()
+	}
+	return nil
+}
+
+func (f *FailoverConnector) ActiveEndpoint() (Endpoint, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.active == nil {
+		return Endpoint{}, false
+	}
+	return f.active.Endpoint, true
+}
+
+func (f *FailoverConnector) probe(ctx context.Context, active *endpointState) error {
+	window := f.config.GetStabilityWindow()
+	if window < 0 {
+		return nil
+	}
+	f.mu.Lock()
+	now := time.Now()
+	if now.Sub(f.activeSince) < window || now.Sub(f.lastProbe) < window {
+		f.mu.Unlock()
+		return nil
+	}
+	f.lastProbe = now
+	var preferred *endpointState
+	for _, ep := range f.orderByPriorityLocked() {
+		if ep.Priority < active.Priority {
+			preferred = ep
+			break
+		}
+	}
+	f.mu.Unlock()
+	if preferred == nil {
+		return nil
+	}
+
+	conn := f.connectorOf(preferred)
+	if err := conn.Connect(ctx); err != nil {
+		f.mu.Lock()
+		preferred.failedAt = time.Now()
+		f.mu.Unlock()
+		return nil
+	}
+	// 探测连接立即断开，由接下来的 Connect 按优先级重新连接
+	conn.Disconnect()
+	f.mu.Lock()
+	preferred.failedAt = time.Time{}
+	f.failingBack = true
+	f.mu.Unlock()
+	return fmt.Errorf("%w: %s", ErrFailback, preferred.Address)
+}
+
+func (f *FailoverConnector) connectorOf(ep *endpointState) Connector {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if ep.connector == nil {
+		ep.connector = f.factory(ep.Endpoint)
+	}
+	return ep.connector
+}
+
+func (f *FailoverConnector) setActiveLocked(ep *endpointState) {
+	ep.failedAt = time.Time{}
+	f.active = ep
+	f.activeSince = time.Now()
+}
+
+// orderLocked 返回本次 Connect 尝试的顺序：未失败的按 orderByPriorityLocked 排列，失败过的按失败时间先后排在最后
+func (f *FailoverConnector) orderLocked() []*endpointState {
+	var healthy, failed []*endpointState
+	for _, ep := range f.orderByPriorityLocked() {
+		if ep.failedAt.IsZero() {
+			healthy = append(healthy, ep)
+		} else {
+			failed = append(failed, ep)
+		}
+	}
+	slices.SortStableFunc(failed, func(a, b *endpointState) int {
+		return a.failedAt.Compare(b.failedAt)
+	})
+	return append(healthy, failed...)
+}
+
+// orderByPriorityLocked 按优先级排序，同优先级内按权重随机排列
+func (f *FailoverConnector) orderByPriorityLocked() []*endpointState {
+	eps := slices.Clone(f.endpoints)
+	slices.SortStableFunc(eps, func(a, b *endpointState) int {
+		return cmp.Compare(a.Priority, b.Priority)
+	})
+	for start := 0; start < len(eps); {
+		end := start + 1
+		for end < len(eps) && eps[end].Priority == eps[start].Priority {
+			end++
+		}
+		f.shuffleByWeight(eps[start:end])
+		start = end
+	}
+	return eps
+}
+
+func (f *FailoverConnector) shuffleByWeight(eps []*endpointState) {
+	for i := range eps {
+		total := 0
+		for _, ep := range eps[i:] {
+			total += max(ep.Weight, 1)
+		}
+		n := f.rand.IntN(total)
+		for j := i; j < len(eps); j++ {
+			n -= max(eps[j].Weight, 1)
+			if n < 0 {
+				eps[i], eps[j] = eps[j], eps[i]
+				break
+			}
+		}
+	}
+}
+
+func (c *Component) updateEndpoint() {
+	provider, ok := c.connector.(EndpointProvider)
+	if !ok {
+		return
+	}
+	to, _ := provider.ActiveEndpoint()
+	c.mu.Lock()
+	from := c.stats.Endpoint
+	c.stats.Endpoint = to
+	c.mu.Unlock()
+	if from == to {
+		return
+	}
+	if h, ok := c.eventHandler.(EndpointEventHandler); ok {
+		h.OnEndpointChange(from, to)
+	}
+}
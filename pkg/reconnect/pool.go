@@ -0,0 +1,338 @@
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrPoolClosed Pool 未 Start 或已 Close 时返回
+var ErrPoolClosed = errors.New("reconnect: pool closed")
+
+type PoolStrategy string
+
+const (
+	// PoolRoundRobin 在已连接的成员间轮流选择
+	PoolRoundRobin PoolStrategy = "round_robin"
+	// PoolLeastInFlight 选择进行中调用最少的已连接成员
+	PoolLeastInFlight PoolStrategy = "least_in_flight"
+)
+
+type PoolConfig struct {
+	// MinSize 始终保持的成员数，默认 1
+	MinSize int
+	// MaxSize 所有成员都已连接且都有进行中的调用时扩容，最多到该数量，默认等于 MinSize
+	MaxSize int
+	// Strategy 默认 PoolRoundRobin
+	Strategy PoolStrategy
+	// IdleTimeout 超出 MinSize 的成员空闲该时长后关闭，0 表示不收缩
+	IdleTimeout time.Duration
+}
+
+func (c *PoolConfig) GetMinSize() int {
+	if c.MinSize <= 0 {
+		return 1
+	}
+	return c.MinSize
+}
+
+func (c *PoolConfig) GetMaxSize() int {
+	return max(c.MaxSize, c.GetMinSize())
+}
+
+func (c *PoolConfig) GetStrategy() PoolStrategy {
+	if c.Strategy == "" {
+		return PoolRoundRobin
+	}
+	return c.Strategy
+}
+
+type PoolStats struct {
+	Size      int
+	Connected int
+	InFlight  int
+	// Replaced 因停止重连 (StateStopped) 被替换的成员数
+	Replaced int64
+	// Shrunk 因空闲被关闭的成员数
+	Shrunk  int64
+	Members []Stats
+}
+
+type poolMember struct {
+	comp        *Component
+	unsubscribe func()
+	inFlight    int
+	lastUsed    time.Time
+}
+
+// Pool 管理多个独立重连的 Component，每个成员使用 newConnector 创建的 Connector。
+// 成员停止重连后由新成员替换，不影响其他成员。
+type Pool struct {
+	newConnector func() Connector
+	eventHandler EventHandler
+	config       ReconnectConfig
+	poolConfig   *PoolConfig
+
+	mu       sync.Mutex
+	members  []*poolMember
+	next     int
+	started  bool
+	closed   bool
+	replaced int64
+	shrunk   int64
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// PooledClient 是从 Pool 中取出的 Client，使用完后必须调用 Release
+type PooledClient struct {
+	*Client
+	pool   *Pool
+	member *poolMember
+	once   sync.Once
+}
+
+func NewPool(newConnector func() Connector, eventHandler EventHandler, config ReconnectConfig, poolConfig *PoolConfig) *Pool {
+	if poolConfig == nil {
+		poolConfig = &PoolConfig{}
+	}
+	return &Pool{
+		newConnector: newConnector,
+		eventHandler: eventHandler,
+		config:       config,
+		poolConfig:   poolConfig,
+		stopCh:       make(chan struct{}),
+	}
+}
+
+func (p *Pool) Start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return ErrPoolClosed
+	}
+	if p.started {
+		return nil
+	}
+	p.started = true
+	for range p.poolConfig.GetMinSize() {
+		p.members = append(p.members, p.newMemberLocked())
+	}
+	if p.poolConfig.IdleTimeout > 0 {
+		p.wg.Add(1)
+		go p.shrinkLoop()
+	}
+	return nil
+}
+
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	members := p.members
+	p.members = nil
+	p.mu.Unlock()
+
+	close(p.stopCh)
+	for _, m := range members {
+		p.closeMember(m)
+	}
+	p.wg.Wait()
+	return nil
+}
+
+// Acquire 取出一个 Client，优先选择已连接的成员；没有已连接的成员时选择进行中调用最少的成员，
+// 其 Client.Do 会等待重连。
+func (p *Pool) Acquire() (*PooledClient, error) {
+	var stopped []*poolMember
+	defer func() {
+		for _, m := range stopped {
+			p.closeMember(m)
+		}
+	}()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.started || p.closed {
+		return nil, ErrPoolClosed
+	}
+	stopped = p.replaceStoppedLocked()
+
+	var connected []*poolMember
+	busy := true
+	for _, m := range p.members {
+		if m.comp.IsConnected() {
+			connected = append(connected, m)
+			busy = busy && m.inFlight > 0
+		}
+	}
+	// 正在连接的成员不计入，避免上游不可用时不断扩容
+	if busy && len(connected) == len(p.members) && len(p.members) < p.poolConfig.GetMaxSize() {
+		p.members = append(p.members, p.newMemberLocked())
+	}
+
+	var m *poolMember
+	switch {
+	case len(connected) == 0:
+		m = leastInFlight(p.members)
+	case p.poolConfig.GetStrategy() == PoolLeastInFlight:
+		m = leastInFlight(connected)
+	default:
+		m = connected[p.next%len(connected)]
+		p.next++
+	}
+	m.inFlight++
+	m.lastUsed = time.Now()
+	return &PooledClient{Client: m.comp.GetClient(), pool: p, member: m}, nil
+}
+
+// Release 归还 Client，可重复调用
+func (c *PooledClient) Release() {
+	c.once.Do(func() {
+		c.pool.mu.Lock()
+		c.member.inFlight--
+		c.member.lastUsed = time.Now()
+		c.pool.mu.Unlock()
+	})
+}
+
+func (p *Pool) Do(fn func(interface{}) error) error {
+	return p.DoContext(context.Background(), fn)
+}
+
+// DoContext 取出一个 Client 执行 Client.DoContext 后归还
+func (p *Pool) DoContext(ctx context.Context, fn func(interface{}) error) error {
+	client, err := p.Acquire()
+	if err != nil {
+		return err
+	}
+	defer client.Release()
+	return client.DoContext(ctx, fn)
+}
+
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := PoolStats{
+		Size:     len(p.members),
+		Replaced: p.replaced,
+		Shrunk:   p.shrunk,
+	}
+	for _, m := range p.members {
+		ms := m.comp.Stats()
+		if ms.State == StateConnected {
+			stats.Connected++
+		}
+		stats.InFlight += m.inFlight
+		stats.Members = append(stats.Members, ms)
+	}
+	return stats
+}
+
+func (p *Pool) newMemberLocked() *poolMember {
+	comp := New(p.newConnector(), p.eventHandler, p.config)
+	changes, unsubscribe := comp.SubscribeState()
+	m := &poolMember{comp: comp, unsubscribe: unsubscribe, lastUsed: time.Now()}
+	p.wg.Add(1)
+	go p.watch(m, changes)
+	comp.Start()
+	return m
+}
+
+// watch 在成员停止重连时替换它，unsubscribe 后退出
+func (p *Pool) watch(m *poolMember, changes <-chan StateChange) {
+	defer p.wg.Done()
+	for change := range changes {
+		if change.To == StateStopped {
+			p.replace(m)
+			return
+		}
+	}
+}
+
+func (p *Pool) replace(old *poolMember) {
+	p.mu.Lock()
+	i := slices.Index(p.members, old)
+	if p.closed || i < 0 {
+		p.mu.Unlock()
+		return
+	}
+	p.replaceLocked(i)
+	p.mu.Unlock()
+	p.closeMember(old)
+}
+
+// replaceLocked 用新成员替换 members[i]，返回的旧成员需在释放锁后关闭
+func (p *Pool) replaceLocked(i int) *poolMember {
+	old := p.members[i]
+	p.members[i] = p.newMemberLocked()
+	p.replaced++
+	return old
+}
+
+// replaceStoppedLocked 替换已停止重连的成员。SubscribeState 在缓冲区满时会丢弃状态变化，
+// watch 可能错过 StateStopped，因此 Acquire 与收缩时直接检查成员的状态
+func (p *Pool) replaceStoppedLocked() []*poolMember {
+	var stopped []*poolMember
+	for i, m := range p.members {
+		if m.comp.State() == StateStopped {
+			stopped = append(stopped, p.replaceLocked(i))
+		}
+	}
+	return stopped
+}
+
+func (p *Pool) shrinkLoop() {
+	defer p.wg.Done()
+	timeout := p.poolConfig.IdleTimeout
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stopCh:
+			return
+		case <-ticker.C:
+			p.shrink(timeout)
+		}
+	}
+}
+
+func (p *Pool) shrink(timeout time.Duration) {
+	var idle []*poolMember
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	stopped := p.replaceStoppedLocked()
+	now := time.Now()
+	minSize := p.poolConfig.GetMinSize()
+	p.members = slices.DeleteFunc(p.members, func(m *poolMember) bool {
+		if len(p.members)-len(idle) <= minSize || m.inFlight > 0 || now.Sub(m.lastUsed) < timeout {
+			return false
+		}
+		idle = append(idle, m)
+		return true
+	})
+	p.shrunk += int64(len(idle))
+	p.mu.Unlock()
+	for _, m := range append(stopped, idle...) {
+		p.closeMember(m)
+	}
+}
+
+func (p *Pool) closeMember(m *poolMember) {
+	m.comp.Close()
+	m.unsubscribe()
+}
+
+func leastInFlight(members []*poolMember) *poolMember {
+	return slices.MinFunc(members, func(a, b *poolMember) int {
+		return a.inFlight - b.inFlight
+	})
+}
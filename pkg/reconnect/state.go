@@ -0,0 +1,154 @@
+package reconnect
+
+import "time"
+
+type State int
+
+const (
+	// StateIdle 尚未 Start
+	StateIdle State = iota
+	StateConnecting
+	StateConnected
+	// StateBackoff 连接失败或断开后等待下一次重连
+	StateBackoff
+	// StateStopped 超过 MaxRetries 或 MaxElapsedTime 后不再重连
+	StateStopped
+	StateClosed
+)
+
+func (s State) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateBackoff:
+		return "backoff"
+	case StateStopped:
+		return "stopped"
+	case StateClosed:
+		return "closed"
+	}
+	return "idle"
+}
+
+// StateChange 描述一次状态变化，Err 为导致变化的错误 (连接失败、断开原因)，没有时为 nil
+type StateChange struct {
+	From State
+	To   State
+	Err  error
+	At   time.Time
+}
+
+type Stats struct {
+	State State
+	// Attempt 当前这轮重连的次数，与 OnReconnecting 的 attempt 一致，连接成功后归零
+	Attempt int
+	// Reconnects 首次连接成功之后再次连接成功的次数
+	Reconnects  int64
+	Disconnects int64
+	LastError   error
+	LastErrorAt time.Time
+	// ConnectedSince 当前连接建立的时间，未连接时为零值
+	ConnectedSince time.Time
+	// TotalDowntime 首次连接成功后处于断开状态的累计时长，包括正在进行的这次断开
+	TotalDowntime time.Duration
+	// Endpoint Connector 实现 EndpointProvider (如 FailoverConnector) 时为最近一次连接成功的 endpoint
+	Endpoint Endpoint
+}
+
+const stateChangeBuffer = 16
+
+// State 返回当前状态
+func (c *Component) State() State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+func (c *Component) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.State = c.state
+	if !c.downSince.IsZero() {
+		stats.TotalDowntime += time.Since(c.downSince)
+	}
+	return stats
+}
+
+// SubscribeState 返回接收状态变化的 channel 与取消订阅的函数。
+// channel 有缓冲，接收方处理不及时导致缓冲区满时丢弃新的变化，可通过 State() 获取最新状态。
+func (c *Component) SubscribeState() (<-chan StateChange, func()) {
+	ch := make(chan StateChange, stateChangeBuffer)
+	c.mu.Lock()
+	c.subscribers[ch] = struct{}{}
+	c.mu.Unlock()
+	return ch, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if _, ok := c.subscribers[ch]; ok {
+			delete(c.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (c *Component) setState(to State, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setStateLocked(to, err)
+}
+
+func (c *Component) recordError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.recordErrorLocked(err, time.Now())
+}
+
+func (c *Component) recordErrorLocked(err error, now time.Time) {
+	if err != nil {
+		c.stats.LastError = err
+		c.stats.LastErrorAt = now
+	}
+}
+
+func (c *Component) setStateLocked(to State, err error) {
+	now := time.Now()
+	c.recordErrorLocked(err, now)
+	from := c.state
+	// Close 之后重连协程退出前可能还会尝试切换状态
+	if from == to || (c.closing && to != StateClosed) {
+		return
+	}
+	c.state = to
+	switch {
+	case to == StateConnected:
+		c.stats.Attempt = 0
+		c.stats.ConnectedSince = now
+		if c.connectedOnce {
+			c.stats.Reconnects++
+		}
+		if !c.downSince.IsZero() {
+			c.stats.TotalDowntime += now.Sub(c.downSince)
+			c.downSince = time.Time{}
+		}
+		c.connectedOnce = true
+	case from == StateConnected:
+		c.stats.Disconnects++
+		c.stats.ConnectedSince = time.Time{}
+		if to != StateClosed {
+			c.downSince = now
+		}
+	case to == StateClosed && !c.downSince.IsZero():
+		c.stats.TotalDowntime += now.Sub(c.downSince)
+		c.downSince = time.Time{}
+	}
+	change := StateChange{From: from, To: to, Err: err, At: now}
+	for ch := range c.subscribers {
+		select {
+		case ch <- change:
+		default:
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package reconnect
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// TypedConnector 是以具体类型提供客户端的 Connector
+type TypedConnector[T any] interface {
+	Connector
+	GetClient() T
+}
+
+// Typed 是客户端类型为 T 的 Component，其余方法与 Component 相同
+type Typed[T any] struct {
+	*Component
+}
+
+func NewTyped[T any](connector TypedConnector[T], eventHandler EventHandler, config ReconnectConfig) *Typed[T] {
+	c := New(connector, eventHandler, config)
+	c.getClient = func() interface{} {
+		return connector.GetClient()
+	}
+	return &Typed[T]{Component: c}
+}
+
+func (c *Typed[T]) GetClient() *TypedClient[T] {
+	return ClientOf[T](c.Component)
+}
+
+// TypedClient 在 Client 的基础上把客户端断言为 T
+type TypedClient[T any] struct {
+	client *Client
+}
+
+// ClientOf 为未类型化的 Component 创建 TypedClient，用于实现 ClientGetter 的旧 Connector
+func ClientOf[T any](comp *Component) *TypedClient[T] {
+	return &TypedClient[T]{client: comp.GetClient()}
+}
+
+// Untyped 返回底层的 Client
+func (c *TypedClient[T]) Untyped() *Client {
+	return c.client
+}
+
+// Raw 返回当前的客户端，未连接或类型不符时返回零值
+func (c *TypedClient[T]) Raw() T {
+	raw, _ := c.client.Raw().(T)
+	return raw
+}
+
+func (c *TypedClient[T]) Do(fn func(T) error) error {
+	return c.DoContext(context.Background(), fn)
+}
+
+// DoContext 见 Client.DoContext，客户端类型不符时返回 PermanentError 而不是 panic
+func (c *TypedClient[T]) DoContext(ctx context.Context, fn func(T) error) error {
+	return c.client.DoContext(ctx, func(raw interface{}) error {
+		client, ok := raw.(T)
+		if !ok {
+			return Permanent(fmt.Errorf("reconnect: client is %T, not %v", raw, reflect.TypeFor[T]()))
+		}
+		return fn(client)
+	})
+}
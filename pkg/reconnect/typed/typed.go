@@ -0,0 +1,23 @@
+// Package typed 以 typed.New[T]、Component[T]、Client[T]、Connector[T] 的形式提供类型化的 reconnect API，
+// 它们是 reconnect.NewTyped、Typed、TypedClient、TypedConnector 的别名，两种写法可以混用。
+package typed
+
+import "github.com/puper/leo/pkg/reconnect"
+
+// Connector 是以具体类型提供客户端的 Connector
+type Connector[T any] = reconnect.TypedConnector[T]
+
+// Component 是客户端类型为 T 的 reconnect.Component
+type Component[T any] = reconnect.Typed[T]
+
+// Client 的 Do 回调直接收到 T
+type Client[T any] = reconnect.TypedClient[T]
+
+func New[T any](connector Connector[T], eventHandler reconnect.EventHandler, config reconnect.ReconnectConfig) *Component[T] {
+	return reconnect.NewTyped(connector, eventHandler, config)
+}
+
+// ClientOf 为未类型化的 Component 创建 Client，用于 GetClient 返回 interface{} 的旧 Connector
+func ClientOf[T any](comp *reconnect.Component) *Client[T] {
+	return reconnect.ClientOf[T](comp)
+}
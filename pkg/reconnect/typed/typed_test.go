@@ -0,0 +1,68 @@
+package typed
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/puper/leo/pkg/reconnect"
+)
+
+type conn struct {
+	id int
+}
+
+type connector struct {
+	connected atomic.Bool
+	client    *conn
+}
+
+func (c *connector) Connect(ctx context.Context) error {
+	c.client = &conn{id: 1}
+	c.connected.Store(true)
+	return nil
+}
+
+func (c *connector) Disconnect() error {
+	c.connected.Store(false)
+	return nil
+}
+
+func (c *connector) IsConnected() bool {
+	return c.connected.Load()
+}
+
+func (c *connector) GetClient() *conn {
+	return c.client
+}
+
+func TestNew(t *testing.T) {
+	comp := New[*conn](&connector{}, nil, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := comp.StartAndWait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer comp.Close()
+
+	var client *Client[*conn] = comp.GetClient()
+	err := client.Do(func(c *conn) error {
+		if c.id != 1 {
+			t.Errorf("unexpected client %+v", c)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ClientOf 的类型不符时返回 PermanentError
+	err = ClientOf[string](comp.Component).Do(func(string) error { return nil })
+	var permanent *reconnect.PermanentError
+	if !errors.As(err, &permanent) || !strings.Contains(err.Error(), "*typed.conn") {
+		t.Fatalf("expected PermanentError, got %v", err)
+	}
+}